@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ---------- codec footer and CRC32 verification (CodecUtil.checkFooter) ----------
+
+const (
+	FOOTER_MAGIC  = ^uint32(CODEC_MAGIC) // 0xc02893e8
+	FOOTER_LENGTH = 16                   // magic(4) + algorithmID(4) + checksum(8)
+
+	INTEGRITY_OK      = "ok"
+	INTEGRITY_CORRUPT = "corrupt"
+)
+
+// FileChecksum is the result of checking one index file against its codec footer.
+type FileChecksum struct {
+	File       string `json:"file"`
+	Size       int64  `json:"size"`
+	ChecksumOK bool   `json:"checksum_ok"`
+	Expected   string `json:"expected,omitempty"` // hex, as stored in the footer
+	Actual     string `json:"actual,omitempty"`   // hex, computed over the file
+	Error      string `json:"error,omitempty"`
+}
+
+// verifyFileChecksum reads the 16-byte Lucene footer of indexDir/name and
+// recomputes the CRC32 over everything that precedes the stored checksum.
+func verifyFileChecksum(indexDir, name string) FileChecksum {
+	res := FileChecksum{File: name}
+	f, err := os.Open(filepath.Join(indexDir, name))
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	res.Size = fi.Size()
+	if res.Size < FOOTER_LENGTH {
+		res.Error = fmt.Sprintf("file too short (%d bytes) to contain a codec footer", res.Size)
+		return res
+	}
+
+	footer := make([]byte, FOOTER_LENGTH)
+	if _, err := f.ReadAt(footer, res.Size-FOOTER_LENGTH); err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	magic := binary.BigEndian.Uint32(footer[0:4])
+	algorithmID := int32(binary.BigEndian.Uint32(footer[4:8]))
+	expected := binary.BigEndian.Uint64(footer[8:16])
+	if magic != FOOTER_MAGIC {
+		res.Error = fmt.Sprintf("codec footer mismatch: actual footer=%#x vs expected footer=%#x", magic, FOOTER_MAGIC)
+		return res
+	}
+	if algorithmID != 0 {
+		res.Error = fmt.Sprintf("unknown checksum algorithm ID %d", algorithmID)
+		return res
+	}
+	res.Expected = fmt.Sprintf("%08x", expected)
+	if expected&0xFFFFFFFF00000000 != 0 {
+		res.Error = "illegal CRC-32 checksum (high 32 bits set)"
+		return res
+	}
+
+	h := crc32.NewIEEE()
+	if _, err := io.Copy(h, io.NewSectionReader(f, 0, res.Size-8)); err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	actual := h.Sum32()
+	res.Actual = fmt.Sprintf("%08x", actual)
+	res.ChecksumOK = uint64(actual) == expected
+	if !res.ChecksumOK {
+		res.Error = "checksum failed (hardware problem or truncated copy?)"
+	}
+	return res
+}
+
+// verifyIndexIntegrity checks segments_N and every file the commit needs for
+// every segment (see commitFiles), including the generation-updated .fnm/DV
+// files and the current .liv, filling in the per-file results and the
+// overall verdict on rep.
+func verifyIndexIntegrity(rep *Report) {
+	rep.Integrity = INTEGRITY_OK
+	record := func(c FileChecksum) {
+		if !c.ChecksumOK {
+			rep.Integrity = INTEGRITY_CORRUPT
+			rep.CorruptFiles = append(rep.CorruptFiles, c.File)
+		}
+	}
+
+	c := verifyFileChecksum(rep.IndexPath, rep.SegmentsFile)
+	rep.SegmentsChecksum = &c
+	record(c)
+
+	for i := range rep.Segments {
+		s := &rep.Segments[i]
+		files := commitFiles(*s)
+		s.Checksums = make([]FileChecksum, 0, len(files))
+		for _, name := range files {
+			c := verifyFileChecksum(rep.IndexPath, name)
+			s.Checksums = append(s.Checksums, c)
+			record(c)
+		}
+	}
+}
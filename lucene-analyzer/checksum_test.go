@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// withFooter appends a valid Lucene codec footer to body
+func withFooter(body []byte) []byte {
+	out := append([]byte{}, body...)
+	out = binary.BigEndian.AppendUint32(out, FOOTER_MAGIC)
+	out = binary.BigEndian.AppendUint32(out, 0)
+	crc := crc32.ChecksumIEEE(out)
+	return binary.BigEndian.AppendUint64(out, uint64(crc))
+}
+
+// TestVerifyFileChecksum tests footer parsing and CRC32 verification
+func TestVerifyFileChecksum(t *testing.T) {
+	tempDir := t.TempDir()
+
+	good := withFooter([]byte("some lucene file content"))
+	corrupt := append([]byte{}, good...)
+	corrupt[3] ^= 0xFF
+	noFooter := []byte("this file has no codec footer at all")
+
+	files := map[string][]byte{
+		"good.si":    good,
+		"corrupt.si": corrupt,
+		"nofoot.si":  noFooter,
+		"short.si":   []byte{1, 2, 3},
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tempDir, name), content, 0644); err != nil {
+			t.Fatalf("Failed to create test file %s: %v", name, err)
+		}
+	}
+
+	tests := []struct {
+		file    string
+		wantOK  bool
+		wantErr bool
+	}{
+		{"good.si", true, false},
+		{"corrupt.si", false, true},
+		{"nofoot.si", false, true},
+		{"short.si", false, true},
+		{"missing.si", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			got := verifyFileChecksum(tempDir, tt.file)
+			if got.ChecksumOK != tt.wantOK {
+				t.Errorf("verifyFileChecksum() ChecksumOK = %v, want %v (%+v)", got.ChecksumOK, tt.wantOK, got)
+			}
+			if (got.Error != "") != tt.wantErr {
+				t.Errorf("verifyFileChecksum() Error = %q, wantErr %v", got.Error, tt.wantErr)
+			}
+			if tt.wantOK && got.Expected != got.Actual {
+				t.Errorf("verifyFileChecksum() expected %s != actual %s", got.Expected, got.Actual)
+			}
+		})
+	}
+
+	if got := verifyFileChecksum(tempDir, "corrupt.si"); got.Expected == "" || got.Actual == "" || got.Expected == got.Actual {
+		t.Errorf("verifyFileChecksum() corrupt file should report differing expected/actual, got %+v", got)
+	}
+}
+
+// TestVerifyIndexIntegrity tests that the update files and the .liv of a segment are checked along with its .si file set
+func TestVerifyIndexIntegrity(t *testing.T) {
+	tempDir := t.TempDir()
+	corrupt := withFooter([]byte("live docs"))
+	corrupt[0] ^= 0xFF
+	files := map[string][]byte{
+		"segments_2":          withFooter([]byte("segments")),
+		"_0.si":               withFooter([]byte("segment info")),
+		"_0_1.fnm":            withFooter([]byte("field infos update")),
+		"_0_1_Lucene90_0.dvm": withFooter([]byte("doc values update")),
+		"_0_2.liv":            corrupt,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tempDir, name), content, 0644); err != nil {
+			t.Fatalf("Failed to create test file %s: %v", name, err)
+		}
+	}
+
+	rep := &Report{IndexPath: tempDir, SegmentsFile: "segments_2", Segments: []SegInfoSummary{{
+		SegName: "_0", DelGen: 2,
+		Files:        []string{"_0.si", "_0_1.fnm", "_0_1_Lucene90_0.dvm"},
+		UpdatesFiles: []string{"_0_1.fnm"},
+		DVUpdates:    []DocValuesUpdate{{FieldNumber: 3, Files: []string{"_0_1_Lucene90_0.dvm"}}},
+	}}}
+	verifyIndexIntegrity(rep)
+
+	var checked []string
+	for _, c := range rep.Segments[0].Checksums {
+		checked = append(checked, c.File)
+	}
+	if want := []string{"_0.si", "_0_1.fnm", "_0_1_Lucene90_0.dvm", "_0_2.liv"}; !reflect.DeepEqual(checked, want) {
+		t.Errorf("verifyIndexIntegrity() checked %v, want %v", checked, want)
+	}
+	if rep.Integrity != INTEGRITY_CORRUPT || !reflect.DeepEqual(rep.CorruptFiles, []string{"_0_2.liv"}) {
+		t.Errorf("verifyIndexIntegrity() = %s %v, want corrupt [_0_2.liv]", rep.Integrity, rep.CorruptFiles)
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+)
+
+// ---------- all commit points (segments_N) in the index directory ----------
+
+// CommitPoint summarizes one segments_N file. Older commits survive next to
+// the latest one under snapshot deletion policies or after a crash.
+type CommitPoint struct {
+	SegmentsFile string            `json:"segments_file"`
+	Generation   int64             `json:"generation"`
+	Latest       bool              `json:"latest"`
+	UserData     map[string]string `json:"user_data,omitempty"`
+	Segments     []string          `json:"segments"`
+	TotalDocs    int64             `json:"total_docs"`
+	Orphaned     bool              `json:"orphaned"`                // some referenced files are gone
+	MissingFiles []string          `json:"missing_files,omitempty"` // referenced by this commit but not on disk
+	Error        string            `json:"error,omitempty"`         // the commit could not be fully decoded
+
+	files      []string // every file the commit references, including itself
+	incomplete bool     // segments_N or some .si could not be decoded, so files may lack some
+}
+
+// analyzeCommitPoints reads every segments_N in indexDir, oldest first.
+// latest is the commit buildReport already parsed from latestFile, with the
+// error of that parse; only older commits are read here, and of those only
+// the commit metadata and .si files, not the per-segment files.
+func analyzeCommitPoints(indexDir, latestFile string, latest *SegmentInfos, latestErr error) ([]CommitPoint, error) {
+	names, err := listSegmentsFiles(indexDir)
+	if err != nil {
+		return nil, err
+	}
+	commits := make([]CommitPoint, 0, len(names))
+	for i, name := range names {
+		cp := CommitPoint{SegmentsFile: name, Latest: i == len(names)-1, Segments: []string{}, files: []string{name}}
+		cp.Generation, _ = generationFromSegmentsFileName(name)
+
+		infos, err := latest, latestErr
+		if name != latestFile {
+			infos, err = parseSegmentsFile(indexDir, name)
+		}
+		if err != nil {
+			cp.Error = err.Error()
+			cp.incomplete = true
+		}
+		cp.UserData = infos.UserData
+		for _, s := range infos.Segments {
+			if s.siErr != nil {
+				cp.incomplete = true
+			}
+			cp.Segments = append(cp.Segments, s.SegName)
+			cp.TotalDocs += int64(s.MaxDoc)
+			files := commitFiles(s)
+			cp.files = append(cp.files, files...)
+			cp.MissingFiles = append(cp.MissingFiles, missingFiles(indexDir, files)...)
+		}
+		sort.Strings(cp.MissingFiles)
+		cp.Orphaned = len(cp.MissingFiles) > 0
+		commits = append(commits, cp)
+	}
+	return commits, nil
+}
+
+// commitFiles lists the files a commit needs for one segment: the .si file
+// set plus generation-updated .fnm/DV files and the current .liv.
+func commitFiles(s SegInfoSummary) []string {
+	files := s.Files
+	if s.DelGen > 0 {
+		files = append(append([]string{}, files...), liveDocsFileName(s.SegName, s.DelGen))
+	}
+	return files
+}
+
+func missingFiles(indexDir string, files []string) []string {
+	var missing []string
+	for _, name := range files {
+		if _, err := os.Stat(filepath.Join(indexDir, name)); errors.Is(err, fs.ErrNotExist) {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// ---------- files referenced by no commit / referenced but absent ----------
+
+// indexFilePattern matches the names IndexFileDeleter manages: per-segment
+// codec files and commit points. Other files (write.lock, ...) are ignored.
+var indexFilePattern = regexp.MustCompile(`^(_[a-z0-9]+(_.*)?\..*|(pending_)?segments(_[a-z0-9]+)?)$`)
+
+// FileRef is a file found on disk without any commit referencing it, or a
+// file that a commit references but that does not exist.
+type FileRef struct {
+	File         string   `json:"file"`
+	SizeBytes    int64    `json:"size_bytes,omitempty"`    // unreferenced files only
+	ReferencedBy []string `json:"referenced_by,omitempty"` // missing files only: the segments_N files that need it
+}
+
+// checkFileReferences compares the directory listing against the files
+// referenced by all commits. Unreferenced files are usually leftovers of
+// failed merges or flushes; missing files point at an incomplete copy.
+// Unreferenced files are only listed when every commit was fully decoded,
+// since the files of an undecoded segment would look unreferenced too.
+func checkFileReferences(rep *Report, commits []CommitPoint) error {
+	referenced := map[string]bool{}
+	missing := map[string][]string{}
+	rep.IncompleteCommits = nil
+	for _, cp := range commits {
+		for _, name := range cp.files {
+			referenced[name] = true
+		}
+		for _, name := range cp.MissingFiles {
+			missing[name] = append(missing[name], cp.SegmentsFile)
+		}
+		if cp.incomplete {
+			rep.IncompleteCommits = append(rep.IncompleteCommits, cp.SegmentsFile)
+		}
+	}
+
+	rep.UnreferencedFiles, rep.UnreferencedSizeBytes = nil, 0
+	if len(rep.IncompleteCommits) == 0 {
+		dir := FSDirectory{rep.IndexPath}
+		names, err := dir.ListAll()
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			if referenced[name] || !indexFilePattern.MatchString(name) {
+				continue
+			}
+			size, err := dir.FileLength(name)
+			if err != nil {
+				return err
+			}
+			rep.UnreferencedFiles = append(rep.UnreferencedFiles, FileRef{File: name, SizeBytes: size})
+			rep.UnreferencedSizeBytes += size
+		}
+	}
+
+	rep.MissingFiles = nil
+	for name, by := range missing {
+		rep.MissingFiles = append(rep.MissingFiles, FileRef{File: name, ReferencedBy: by})
+	}
+	sort.Slice(rep.MissingFiles, func(i, j int) bool { return rep.MissingFiles[i].File < rep.MissingFiles[j].File })
+	return nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestAnalyzeCommitPoints tests ordering, the latest flag and orphan detection
+func TestAnalyzeCommitPoints(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// segments_2 references _0, whose .si has since been deleted
+	var orphan bytes.Buffer
+	orphan.Write(segmentsHeader(1))
+	orphan.Write([]byte{9, 0, 0})
+	writeSegmentEntry(&orphan, SEGMENTS_VERSION_74, "_0", 0)
+	writeVIntBytes(&orphan, 0)
+
+	var empty bytes.Buffer
+	empty.Write(segmentsHeader(0))
+	writeVIntBytes(&empty, 1)
+	writeString(&empty, "translog_uuid")
+	writeString(&empty, "abc")
+
+	for name, content := range map[string][]byte{"segments_2": orphan.Bytes(), "segments_b": empty.Bytes(), "segments.gen": nil} {
+		if err := os.WriteFile(filepath.Join(tempDir, name), content, 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+
+	latest, err := parseSegmentsFile(tempDir, "segments_b")
+	if err != nil {
+		t.Fatalf("parseSegmentsFile() error = %v", err)
+	}
+	commits, err := analyzeCommitPoints(tempDir, "segments_b", latest, nil)
+	if err != nil {
+		t.Fatalf("analyzeCommitPoints() error = %v", err)
+	}
+	if len(commits) != 2 {
+		t.Fatalf("analyzeCommitPoints() returned %d commits, want 2", len(commits))
+	}
+	old, newest := commits[0], commits[1]
+	if old.SegmentsFile != "segments_2" || old.Generation != 2 || old.Latest {
+		t.Errorf("analyzeCommitPoints()[0] = %+v, want segments_2, not latest", old)
+	}
+	if !old.Orphaned || len(old.MissingFiles) != 1 || old.MissingFiles[0] != "_0.si" {
+		t.Errorf("analyzeCommitPoints()[0] missing = %v, orphaned = %v, want [_0.si]", old.MissingFiles, old.Orphaned)
+	}
+	if newest.SegmentsFile != "segments_b" || newest.Generation != 11 || !newest.Latest || newest.Orphaned {
+		t.Errorf("analyzeCommitPoints()[1] = %+v, want intact latest segments_b", newest)
+	}
+	if newest.UserData["translog_uuid"] != "abc" || len(newest.Segments) != 0 {
+		t.Errorf("analyzeCommitPoints()[1] user data = %v, segments = %v", newest.UserData, newest.Segments)
+	}
+
+	// 最新提交不重新读取，直接使用传入的解析结果和错误
+	latest.UserData = map[string]string{"translog_uuid": "reused"}
+	commits, err = analyzeCommitPoints(tempDir, "segments_b", latest, errors.New("truncated"))
+	if err != nil {
+		t.Fatalf("analyzeCommitPoints() error = %v", err)
+	}
+	if c := commits[1]; c.UserData["translog_uuid"] != "reused" || c.Error != "truncated" {
+		t.Errorf("analyzeCommitPoints()[1] = %+v, want the passed latest commit", c)
+	}
+}
+
+// TestCheckFileReferences tests that stray codec files and absent referenced files are both reported,
+// and that stray files are not listed while some commit could not be fully decoded
+func TestCheckFileReferences(t *testing.T) {
+	tempDir := t.TempDir()
+	var orphan bytes.Buffer
+	orphan.Write(segmentsHeader(1))
+	orphan.Write([]byte{9, 0, 0})
+	writeSegmentEntry(&orphan, SEGMENTS_VERSION_74, "_0", 0)
+	writeVIntBytes(&orphan, 0)
+
+	files := map[string][]byte{
+		"segments_2": orphan.Bytes(),
+		"_3.fdt":     make([]byte, 100), // left behind by a failed merge
+		"_3_1.fnm":   make([]byte, 20),
+		"write.lock": nil,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tempDir, name), content, 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+
+	check := func() *Report {
+		t.Helper()
+		latest, err := parseSegmentsFile(tempDir, "segments_2")
+		commits, err := analyzeCommitPoints(tempDir, "segments_2", latest, err)
+		if err != nil {
+			t.Fatalf("analyzeCommitPoints() error = %v", err)
+		}
+		rep := &Report{IndexPath: tempDir}
+		if err := checkFileReferences(rep, commits); err != nil {
+			t.Fatalf("checkFileReferences() error = %v", err)
+		}
+		return rep
+	}
+
+	// _0.si 缺失时 _0 的其他文件未知，不能判断哪些文件未被引用
+	rep := check()
+	if len(rep.MissingFiles) != 1 || rep.MissingFiles[0].File != "_0.si" || len(rep.MissingFiles[0].ReferencedBy) != 1 || rep.MissingFiles[0].ReferencedBy[0] != "segments_2" {
+		t.Errorf("checkFileReferences() missing = %+v, want _0.si referenced by segments_2", rep.MissingFiles)
+	}
+	if len(rep.UnreferencedFiles) != 0 || rep.UnreferencedSizeBytes != 0 || !reflect.DeepEqual(rep.IncompleteCommits, []string{"segments_2"}) {
+		t.Errorf("checkFileReferences() unreferenced = %+v, incomplete = %v, want none listed and segments_2 incomplete", rep.UnreferencedFiles, rep.IncompleteCommits)
+	}
+
+	writeCompoundSI(t, tempDir, "_0", 1)
+	for _, name := range []string{"_0.cfe", "_0.cfs"} {
+		if err := os.WriteFile(filepath.Join(tempDir, name), nil, 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+	rep = check()
+	if len(rep.UnreferencedFiles) != 2 || rep.UnreferencedFiles[0].File != "_3.fdt" || rep.UnreferencedFiles[0].SizeBytes != 100 || rep.UnreferencedSizeBytes != 120 {
+		t.Errorf("checkFileReferences() unreferenced = %+v (%d bytes), want _3.fdt and _3_1.fnm, 120 bytes", rep.UnreferencedFiles, rep.UnreferencedSizeBytes)
+	}
+	if len(rep.MissingFiles) != 0 || rep.IncompleteCommits != nil {
+		t.Errorf("checkFileReferences() missing = %+v, incomplete = %v, want none", rep.MissingFiles, rep.IncompleteCommits)
+	}
+}
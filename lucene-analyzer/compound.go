@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+// ---------- compound files (.cfe/.cfs) per Lucene90CompoundFormat ----------
+
+const (
+	CFS_ENTRIES_CODEC = "Lucene90CompoundEntries"
+	CFS_DATA_CODEC    = "Lucene90CompoundData"
+	CFS_VERSION       = 0
+
+	// Lucene50CompoundFormat (Lucene 5.0 - 8.x), big-endian
+	CFS_ENTRIES_CODEC_50 = "Lucene50CompoundEntries"
+	CFS_DATA_CODEC_50    = "Lucene50CompoundData"
+)
+
+// CompoundEntry locates one sub-file inside a .cfs file.
+type CompoundEntry struct {
+	Name   string `json:"name"`
+	Offset int64  `json:"offset"`
+	Length int64  `json:"length"`
+}
+
+// CompoundDirectory exposes the sub-files of a compound segment.
+type CompoundDirectory struct {
+	segName string
+	data    *DataInput
+	entries map[string]CompoundEntry
+}
+
+// openCompoundDirectory reads <seg>.cfe from dir and opens <seg>.cfs for reading.
+// The returned directory must be closed.
+func openCompoundDirectory(dir Directory, segName string) (*CompoundDirectory, error) {
+	entries, legacy, err := readCompoundEntries(dir, segName)
+	if err != nil {
+		return nil, err
+	}
+	data, err := dir.OpenInput(segName + ".cfs")
+	if err != nil {
+		return nil, err
+	}
+	dataCodec := CFS_DATA_CODEC
+	if legacy {
+		dataCodec = CFS_DATA_CODEC_50
+	}
+	if _, err := checkIndexHeader(data, dataCodec, CFS_VERSION, CFS_VERSION); err != nil {
+		data.Close()
+		return nil, err
+	}
+	// 所有子文件必须位于 header 之后、footer 之前
+	end := data.Length() - FOOTER_LENGTH
+	for _, e := range entries {
+		if e.Offset < data.Pos() || e.Offset+e.Length > end {
+			data.Close()
+			return nil, fmt.Errorf("%s.cfs: entry %s [%d, %d) is outside of the data section [%d, %d)", segName, e.Name, e.Offset, e.Offset+e.Length, data.Pos(), end)
+		}
+	}
+	return &CompoundDirectory{segName: segName, data: data, entries: entries}, nil
+}
+
+// CFE: Header, FileCount, <FileName, DataOffset, DataLength>FileCount, Footer
+// legacy reports a Lucene50 entry table, whose data file has its own codec name.
+func readCompoundEntries(dir Directory, segName string) (entries map[string]CompoundEntry, legacy bool, err error) {
+	in, err := dir.OpenInput(segName + ".cfe")
+	if err != nil {
+		return nil, false, err
+	}
+	defer in.Close()
+
+	hdr, err := checkIndexHeaderOf(in,
+		HeaderFormat{Codec: CFS_ENTRIES_CODEC, MinVersion: CFS_VERSION, MaxVersion: CFS_VERSION},
+		HeaderFormat{Codec: CFS_ENTRIES_CODEC_50, MinVersion: CFS_VERSION, MaxVersion: CFS_VERSION, BigEndian: true})
+	if err != nil {
+		return nil, false, err
+	}
+	legacy = hdr.Codec == CFS_ENTRIES_CODEC_50
+	n, err := in.ReadVInt()
+	if err != nil {
+		return nil, false, in.fail("numEntries", err)
+	}
+	if n < 0 {
+		return nil, false, in.fail("numEntries", fmt.Errorf("invalid entry count %d", n))
+	}
+	entries = make(map[string]CompoundEntry, n)
+	for i := 0; i < int(n); i++ {
+		field := func(name string) string { return fmt.Sprintf("entries[%d].%s", i, name) }
+		// 文件名只保存去掉段名后的部分，如 ".fnm"、"_Lucene90_0.dvd"
+		id, err := in.ReadString()
+		if err != nil {
+			return nil, false, in.fail(field("id"), err)
+		}
+		name := segName + id
+		if _, dup := entries[name]; dup {
+			return nil, false, in.fail(field("id"), fmt.Errorf("duplicate compound entry %s", name))
+		}
+		e := CompoundEntry{Name: name}
+		if e.Offset, err = in.ReadLong(); err != nil {
+			return nil, false, in.fail(field("offset"), err)
+		}
+		if e.Length, err = in.ReadLong(); err != nil {
+			return nil, false, in.fail(field("length"), err)
+		}
+		if e.Offset < 0 || e.Length < 0 {
+			return nil, false, in.fail(field("length"), fmt.Errorf("invalid entry %s offset %d length %d", name, e.Offset, e.Length))
+		}
+		entries[name] = e
+	}
+	return entries, legacy, nil
+}
+
+// Entries returns the sub-files ordered by their position in the .cfs.
+func (d *CompoundDirectory) Entries() []CompoundEntry {
+	out := make([]CompoundEntry, 0, len(d.entries))
+	for _, e := range d.entries {
+		out = append(out, e)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Offset < out[j].Offset })
+	return out
+}
+
+func (d *CompoundDirectory) ListAll() ([]string, error) {
+	names := make([]string, 0, len(d.entries))
+	for name := range d.entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (d *CompoundDirectory) FileLength(name string) (int64, error) {
+	e, ok := d.entries[name]
+	if !ok {
+		return 0, &os.PathError{Op: "open", Path: d.segName + ".cfs/" + name, Err: os.ErrNotExist}
+	}
+	return e.Length, nil
+}
+
+func (d *CompoundDirectory) OpenInput(name string) (*DataInput, error) {
+	e, ok := d.entries[name]
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: d.segName + ".cfs/" + name, Err: os.ErrNotExist}
+	}
+	return d.data.Slice(name, e.Offset, e.Length)
+}
+
+func (d *CompoundDirectory) Close() error {
+	return d.data.Close()
+}
+
+// openSegmentDirectory returns the directory holding the segment's codec
+// files: the compound directory for compound segments, dir itself otherwise.
+// It fails for segments whose .si could not be read.
+func openSegmentDirectory(dir Directory, s *SegInfoSummary) (Directory, error) {
+	if s.siErr != nil {
+		return nil, s.siErr
+	}
+	if !s.Compound {
+		return dir, nil
+	}
+	return openCompoundDirectory(dir, s.SegName)
+}
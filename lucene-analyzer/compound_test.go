@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeCompoundSegment writes <seg>.cfe/<seg>.cfs holding the given sub-files (keyed by suffix)
+func writeCompoundSegment(t *testing.T, dir, seg string, suffixes []string, contents [][]byte) {
+	t.Helper()
+	var data, entries bytes.Buffer
+	writeIndexHeader(&data, CFS_DATA_CODEC, CFS_VERSION, "")
+	writeIndexHeader(&entries, CFS_ENTRIES_CODEC, CFS_VERSION, "")
+	writeVIntBytes(&entries, len(suffixes))
+	for i, suffix := range suffixes {
+		writeString(&entries, suffix)
+		binary.Write(&entries, binary.LittleEndian, int64(data.Len()))
+		binary.Write(&entries, binary.LittleEndian, int64(len(contents[i])))
+		data.Write(contents[i])
+	}
+	if err := os.WriteFile(filepath.Join(dir, seg+".cfs"), withFooter(data.Bytes()), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, seg+".cfe"), withFooter(entries.Bytes()), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+}
+
+// TestCompoundDirectory tests that sub-files of a .cfs read like plain files
+func TestCompoundDirectory(t *testing.T) {
+	tempDir := t.TempDir()
+	writeCompoundSegment(t, tempDir, "_3", []string{".fnm", "_Lucene90_0.dvd"}, [][]byte{[]byte("fieldinfos"), {0xAC, 0x02, 0x07}})
+
+	cfs, err := openCompoundDirectory(FSDirectory{tempDir}, "_3")
+	if err != nil {
+		t.Fatalf("openCompoundDirectory() error = %v", err)
+	}
+	defer cfs.Close()
+
+	names, _ := cfs.ListAll()
+	if len(names) != 2 || names[0] != "_3.fnm" || names[1] != "_3_Lucene90_0.dvd" {
+		t.Errorf("ListAll() = %v", names)
+	}
+	if n, err := cfs.FileLength("_3.fnm"); err != nil || n != 10 {
+		t.Errorf("FileLength(_3.fnm) = %d, %v, want 10", n, err)
+	}
+	entries := cfs.Entries()
+	if len(entries) != 2 || entries[0].Name != "_3.fnm" || entries[1].Offset != entries[0].Offset+10 {
+		t.Errorf("Entries() = %+v", entries)
+	}
+
+	in, err := cfs.OpenInput("_3_Lucene90_0.dvd")
+	if err != nil {
+		t.Fatalf("OpenInput() error = %v", err)
+	}
+	if v, err := in.ReadVInt(); err != nil || v != 300 || in.Pos() != 2 {
+		t.Errorf("ReadVInt() from sub-file = %d, %v at pos %d", v, err, in.Pos())
+	}
+	if _, err := in.ReadShort(); err == nil {
+		t.Errorf("reading past the end of a sub-file should fail")
+	}
+	if _, err := cfs.OpenInput("_3.tim"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("OpenInput() of a missing entry error = %v, want ErrNotExist", err)
+	}
+}
+
+// TestCompoundDirectoryBadEntry tests that entries pointing outside of the .cfs are rejected
+func TestCompoundDirectoryBadEntry(t *testing.T) {
+	tempDir := t.TempDir()
+	writeCompoundSegment(t, tempDir, "_3", []string{".fnm"}, [][]byte{[]byte("fieldinfos")})
+	// 用一个更短的 .cfs 覆盖，使条目越界
+	var data bytes.Buffer
+	writeIndexHeader(&data, CFS_DATA_CODEC, CFS_VERSION, "")
+	if err := os.WriteFile(filepath.Join(tempDir, "_3.cfs"), withFooter(data.Bytes()), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if _, err := openCompoundDirectory(FSDirectory{tempDir}, "_3"); err == nil {
+		t.Errorf("openCompoundDirectory() should reject entries outside of the data section")
+	}
+}
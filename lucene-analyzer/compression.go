@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
+)
+
+// ---------- LZ4 / DEFLATE with preset dictionary (stored fields chunks) ----------
+
+const (
+	LZ4_MIN_MATCH = 4
+)
+
+// lz4Decompress decodes an LZ4 block into dest[dOff:dOff+n]; matches may
+// reach back into dest[:dOff], which holds the preset dictionary.
+func lz4Decompress(in *DataInput, n int, dest []byte, dOff int) error {
+	end := dOff + n
+	for dOff < end {
+		token, err := in.readByte()
+		if err != nil {
+			return err
+		}
+		literalLen := int(token >> 4)
+		if literalLen == 0x0F {
+			if literalLen, err = lz4ReadLength(in, literalLen); err != nil {
+				return err
+			}
+		}
+		if literalLen > end-dOff {
+			return fmt.Errorf("lz4 literals overflow the block: %d > %d", literalLen, end-dOff)
+		}
+		if err := in.ReadBytes(dest[dOff : dOff+literalLen]); err != nil {
+			return err
+		}
+		dOff += literalLen
+		if dOff >= end {
+			break
+		}
+
+		b, err := in.next(2)
+		if err != nil {
+			return err
+		}
+		matchDec := int(b[0]) | int(b[1])<<8
+		matchLen := int(token & 0x0F)
+		if matchLen == 0x0F {
+			if matchLen, err = lz4ReadLength(in, matchLen); err != nil {
+				return err
+			}
+		}
+		matchLen += LZ4_MIN_MATCH
+		if matchDec == 0 || matchDec > dOff {
+			return fmt.Errorf("lz4 match offset %d out of range at %d", matchDec, dOff)
+		}
+		if matchLen > end-dOff {
+			return fmt.Errorf("lz4 match overflows the block: %d > %d", matchLen, end-dOff)
+		}
+		// 可能重叠，逐字节复制
+		for i := 0; i < matchLen; i++ {
+			dest[dOff+i] = dest[dOff-matchDec+i]
+		}
+		dOff += matchLen
+	}
+	return nil
+}
+
+func lz4ReadLength(in *DataInput, length int) (int, error) {
+	for {
+		b, err := in.readByte()
+		if err != nil {
+			return 0, err
+		}
+		length += int(b)
+		if b != 0xFF {
+			return length, nil
+		}
+	}
+}
+
+// decompressLZ4WithPresetDict reverses LZ4WithPresetDictCompressionMode:
+// DictLength, BlockLength, <CompressedLength>1+NumBlocks, Dict, <Block>NumBlocks
+// Every block is compressed with the dictionary as preset history.
+func decompressLZ4WithPresetDict(in *DataInput, originalLength int) ([]byte, error) {
+	dictLength, blockLength, err := readPresetDictHeader(in, originalLength)
+	if err != nil {
+		return nil, err
+	}
+	// 所有压缩长度写在数据之前；字典的长度不需要
+	if _, err := in.ReadVInt(); err != nil {
+		return nil, in.fail("dictCompressedLength", err)
+	}
+	for total := dictLength; total < originalLength; total += blockLength {
+		if _, err := in.ReadVInt(); err != nil {
+			return nil, in.fail("blockCompressedLength", err)
+		}
+	}
+
+	out := make([]byte, originalLength)
+	buf := make([]byte, dictLength+blockLength)
+	in.mark = in.pos
+	if err := lz4Decompress(in, dictLength, buf, 0); err != nil {
+		return nil, in.fail("dict", err)
+	}
+	copy(out, buf[:dictLength])
+	for start := dictLength; start < originalLength; start += blockLength {
+		l := min(blockLength, originalLength-start)
+		in.mark = in.pos
+		if err := lz4Decompress(in, l, buf, dictLength); err != nil {
+			return nil, in.fail("block", err)
+		}
+		copy(out[start:], buf[dictLength:dictLength+l])
+	}
+	return out, nil
+}
+
+// decompressDeflateWithPresetDict reverses DeflateWithPresetDictCompressionMode:
+// DictLength, BlockLength, <CompressedLength, RawDeflate>1+NumBlocks
+func decompressDeflateWithPresetDict(in *DataInput, originalLength int) ([]byte, error) {
+	dictLength, blockLength, err := readPresetDictHeader(in, originalLength)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, originalLength)
+	if out, err = inflateBlock(in, out, dictLength, nil); err != nil {
+		return nil, err
+	}
+	dict := out[:dictLength:dictLength]
+	for start := dictLength; start < originalLength; start += blockLength {
+		if out, err = inflateBlock(in, out, min(blockLength, originalLength-start), dict); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func readPresetDictHeader(in *DataInput, originalLength int) (dictLength, blockLength int, err error) {
+	d, err := in.ReadVInt()
+	if err != nil {
+		return 0, 0, in.fail("dictLength", err)
+	}
+	b, err := in.ReadVInt()
+	if err != nil {
+		return 0, 0, in.fail("blockLength", err)
+	}
+	dictLength, blockLength = int(d), int(b)
+	if dictLength < 0 || dictLength > originalLength {
+		return 0, 0, in.fail("dictLength", fmt.Errorf("invalid dictionary length %d for %d bytes", dictLength, originalLength))
+	}
+	if (blockLength <= 0 && dictLength < originalLength) || blockLength > originalLength {
+		return 0, 0, in.fail("blockLength", fmt.Errorf("invalid block length %d", blockLength))
+	}
+	return dictLength, blockLength, nil
+}
+
+// inflateBlock appends n inflated bytes to out.
+func inflateBlock(in *DataInput, out []byte, n int, dict []byte) ([]byte, error) {
+	compressedLength, err := in.ReadVInt()
+	if err != nil {
+		return nil, in.fail("compressedLength", err)
+	}
+	if compressedLength == 0 {
+		if n != 0 {
+			return nil, in.fail("compressedLength", fmt.Errorf("empty block for %d bytes", n))
+		}
+		return out, nil
+	}
+	if compressedLength < 0 {
+		return nil, in.fail("compressedLength", fmt.Errorf("invalid compressed length %d", compressedLength))
+	}
+	compressed := make([]byte, compressedLength)
+	if err := in.ReadBytes(compressed); err != nil {
+		return nil, in.fail("compressedBlock", err)
+	}
+	r := flate.NewReaderDict(bytes.NewReader(compressed), dict)
+	defer r.Close()
+	start := len(out)
+	out = append(out, make([]byte, n)...)
+	if _, err := io.ReadFull(r, out[start:]); err != nil {
+		return nil, in.fail("compressedBlock", fmt.Errorf("inflate: %w", err))
+	}
+	return out, nil
+}
+
+// ---------- LowercaseAsciiCompression (terms dictionary suffixes) ----------
+
+// lowercaseAsciiDecompress fills out with bytes packed by Lucene's
+// LowercaseAsciiCompression: 6 bits per byte for [0-9a-z._-], the top two
+// bits of the first 3/4 of the bytes holding the last 1/4, then the bytes
+// that did not fit as (delta, byte) exceptions.
+func lowercaseAsciiDecompress(in *DataInput, out []byte) error {
+	saved := len(out) >> 2
+	packedLen := len(out) - saved
+	if err := in.ReadBytes(out[:packedLen]); err != nil {
+		return err
+	}
+	for i := 0; i < saved; i++ {
+		out[packedLen+i] = out[i]&0xC0>>2 | out[saved+i]&0xC0>>4 | out[2*saved+i]&0xC0>>6
+	}
+	for i, b := range out {
+		out[i] = (b&0x1F | 0x20 | (b&0x20)<<1) - 1
+	}
+
+	numExceptions, err := in.ReadVInt()
+	if err != nil {
+		return err
+	}
+	i := 0
+	for ; numExceptions > 0; numExceptions-- {
+		b, err := in.next(2)
+		if err != nil {
+			return err
+		}
+		i += int(b[0])
+		if i >= len(out) {
+			return fmt.Errorf("lowercase ascii exception at %d beyond %d bytes", i, len(out))
+		}
+		out[i] = b[1]
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"testing"
+)
+
+// TestDecompressLZ4WithPresetDict tests a block whose match overlaps itself and reaches into the dictionary
+func TestDecompressLZ4WithPresetDict(t *testing.T) {
+	var buf bytes.Buffer
+	writeVIntBytes(&buf, 4)                     // dictLength
+	writeVIntBytes(&buf, 8)                     // blockLength
+	writeVIntBytes(&buf, 5)                     // compressed dict
+	writeVIntBytes(&buf, 3)                     // compressed block
+	buf.Write([]byte{0x40, 'a', 'b', 'c', 'd'}) // 4 literals
+	buf.Write([]byte{0x04, 0x04, 0x00})         // match of 8 at distance 4
+	in := newTestInput(buf.Bytes())
+	got, err := decompressLZ4WithPresetDict(in, 12)
+	if err != nil {
+		t.Fatalf("decompressLZ4WithPresetDict() error = %v", err)
+	}
+	if string(got) != "abcdabcdabcd" {
+		t.Errorf("decompressLZ4WithPresetDict() = %q, want %q", got, "abcdabcdabcd")
+	}
+	if in.Pos() != int64(buf.Len()) {
+		t.Errorf("Pos() = %d, want %d", in.Pos(), buf.Len())
+	}
+
+	// 匹配距离超出已解码的数据
+	bad := []byte{4, 8, 5, 3, 0x40, 'a', 'b', 'c', 'd', 0x04, 0x09, 0x00}
+	if _, err := decompressLZ4WithPresetDict(newTestInput(bad), 12); err == nil {
+		t.Errorf("decompressLZ4WithPresetDict() with an out of range match succeeded")
+	}
+}
+
+// TestDecompressDeflateWithPresetDict tests inflating blocks compressed with the dictionary as history
+func TestDecompressDeflateWithPresetDict(t *testing.T) {
+	data := []byte(`{"message":"hello"}{"message":"hello"}{"message":"world"}`)
+	dictLength, blockLength := 19, 16
+	deflate := func(b, dict []byte) []byte {
+		var out bytes.Buffer
+		w, err := flate.NewWriterDict(&out, flate.BestCompression, dict)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(b)
+		w.Close()
+		return out.Bytes()
+	}
+
+	var buf bytes.Buffer
+	writeVIntBytes(&buf, dictLength)
+	writeVIntBytes(&buf, blockLength)
+	dict := data[:dictLength]
+	for start := 0; start < len(data); {
+		end := min(start+blockLength, len(data))
+		var c []byte
+		if start == 0 {
+			end, c = dictLength, deflate(dict, nil)
+		} else {
+			c = deflate(data[start:end], dict)
+		}
+		writeVIntBytes(&buf, len(c))
+		buf.Write(c)
+		start = end
+	}
+
+	got, err := decompressDeflateWithPresetDict(newTestInput(buf.Bytes()), len(data))
+	if err != nil {
+		t.Fatalf("decompressDeflateWithPresetDict() error = %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("decompressDeflateWithPresetDict() = %q, want %q", got, data)
+	}
+}
+
+// lowercaseAsciiCompress mirrors LowercaseAsciiCompression.compress, without the exception limit
+func lowercaseAsciiCompress(buf *bytes.Buffer, in []byte) {
+	compressible := func(b byte) bool {
+		high := (int(b) + 1) &^ 0x1F
+		return high == 0x20 || high == 0x60
+	}
+	packedLen := len(in) - len(in)>>2
+	tmp := make([]byte, len(in))
+	for i, b := range in {
+		v := int(b) + 1
+		tmp[i] = byte(v&0x1F | (v&0x40)>>1)
+	}
+	o := 0
+	for _, shift := range []uint{2, 4, 6} {
+		for i := packedLen; i < len(in); i++ {
+			tmp[o] |= (tmp[i] >> (6 - shift) & 0x03) << 6
+			o++
+		}
+	}
+	buf.Write(tmp[:packedLen])
+
+	var exceptions []byte
+	prev := 0
+	for i, b := range in {
+		if !compressible(b) {
+			exceptions = append(exceptions, byte(i-prev), b)
+			prev = i
+		}
+	}
+	writeVIntBytes(buf, len(exceptions)/2)
+	buf.Write(exceptions)
+}
+
+// TestLowercaseAsciiDecompress tests packed lowercase suffixes with exceptions
+func TestLowercaseAsciiDecompress(t *testing.T) {
+	for _, want := range []string{"abcdefgh", "http_200.status-ok", "mixed Case TERMS 42"} {
+		var buf bytes.Buffer
+		lowercaseAsciiCompress(&buf, []byte(want))
+		buf.WriteByte(0x7F) // 后续数据
+		in := newTestInput(buf.Bytes())
+		got := make([]byte, len(want))
+		if err := lowercaseAsciiDecompress(in, got); err != nil {
+			t.Fatalf("lowercaseAsciiDecompress(%q) error = %v", want, err)
+		}
+		if string(got) != want {
+			t.Errorf("lowercaseAsciiDecompress() = %q, want %q", got, want)
+		}
+		if in.Pos() != int64(buf.Len()-1) {
+			t.Errorf("lowercaseAsciiDecompress(%q) Pos() = %d, want %d", want, in.Pos(), buf.Len()-1)
+		}
+	}
+}
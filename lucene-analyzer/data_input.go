@@ -0,0 +1,487 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ---------- DataInput: Lucene primitive encodings over an io.ReaderAt ----------
+
+const dataInputBufferSize = 8192
+
+var errReadPastEOF = errors.New("read past EOF")
+
+// DataInput is a buffered, seekable reader over a window of an io.ReaderAt.
+// It mirrors Lucene's DataInput/IndexInput: multi-byte integers are
+// little-endian unless the method name says otherwise (BE) or the input was
+// switched with SetByteOrder for a pre-9.0 file, and positions
+// are relative to the start of the window so that compound-file slices
+// behave exactly like standalone files.
+type DataInput struct {
+	name   string
+	r      io.ReaderAt
+	closer io.Closer
+	base   int64 // start of the window within r
+	length int64 // size of the window
+	pos    int64 // current position, relative to base
+	mark   int64 // position at which the most recent Read* call started
+	order  binary.ByteOrder
+
+	buf      []byte
+	bufStart int64 // window position of buf[0]
+}
+
+// NewDataInput reads the first length bytes of r.
+func NewDataInput(name string, r io.ReaderAt, length int64) *DataInput {
+	return &DataInput{name: name, r: r, length: length, order: binary.LittleEndian}
+}
+
+// OpenDataInput opens a file; the returned input must be closed.
+func OpenDataInput(path string) (*DataInput, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	in := NewDataInput(filepath.Base(path), f, fi.Size())
+	in.closer = f
+	return in, nil
+}
+
+func (in *DataInput) Close() error {
+	if in.closer == nil {
+		return nil
+	}
+	return in.closer.Close()
+}
+
+// SetByteOrder switches ReadShort/ReadInt/ReadLong to order. Lucene 9 moved
+// to little-endian; files written by earlier versions are big-endian
+// (EndiannessReverserUtil on the Java side).
+func (in *DataInput) SetByteOrder(order binary.ByteOrder) {
+	in.order = order
+}
+
+func (in *DataInput) Name() string  { return in.name }
+func (in *DataInput) Pos() int64    { return in.pos }
+func (in *DataInput) Length() int64 { return in.length }
+
+// SeekTo moves to an absolute position within the window.
+func (in *DataInput) SeekTo(pos int64) error {
+	if pos < 0 || pos > in.length {
+		return fmt.Errorf("seek to %d is outside of [0, %d]", pos, in.length)
+	}
+	in.pos = pos
+	return nil
+}
+
+// SkipBytes advances the position by n bytes.
+func (in *DataInput) SkipBytes(n int64) error {
+	in.mark = in.pos
+	if n < 0 || in.pos+n > in.length {
+		return errReadPastEOF
+	}
+	in.pos += n
+	return nil
+}
+
+// Slice returns an independent input over [offset, offset+length) of this one.
+func (in *DataInput) Slice(name string, offset, length int64) (*DataInput, error) {
+	if offset < 0 || length < 0 || offset+length > in.length {
+		return nil, fmt.Errorf("slice [%d, %d) is outside of %s (length %d)", offset, offset+length, in.name, in.length)
+	}
+	return &DataInput{name: name, r: in.r, base: in.base + offset, length: length, order: binary.LittleEndian}, nil
+}
+
+// fail wraps cause into a ParseError located at the start of the most recent read.
+func (in *DataInput) fail(field string, cause error) error {
+	var pe *ParseError
+	if errors.As(cause, &pe) {
+		return cause
+	}
+	if cause == io.EOF {
+		cause = io.ErrUnexpectedEOF
+	}
+	return &ParseError{File: in.name, Offset: in.mark, Field: field, Cause: cause}
+}
+
+// fill makes sure that at least n bytes starting at pos are buffered.
+func (in *DataInput) fill(n int) error {
+	if in.pos+int64(n) > in.length {
+		return errReadPastEOF
+	}
+	if in.pos >= in.bufStart && in.pos+int64(n) <= in.bufStart+int64(len(in.buf)) {
+		return nil
+	}
+	size := dataInputBufferSize
+	if n > size {
+		size = n
+	}
+	if rem := in.length - in.pos; int64(size) > rem {
+		size = int(rem)
+	}
+	if cap(in.buf) < size {
+		in.buf = make([]byte, size)
+	}
+	in.buf = in.buf[:size]
+	got, err := in.r.ReadAt(in.buf, in.base+in.pos)
+	in.buf = in.buf[:got]
+	in.bufStart = in.pos
+	if got < n {
+		if err == nil || err == io.EOF {
+			err = errReadPastEOF
+		}
+		return err
+	}
+	return nil
+}
+
+// next returns the next n bytes from the buffer and advances past them.
+func (in *DataInput) next(n int) ([]byte, error) {
+	if err := in.fill(n); err != nil {
+		return nil, err
+	}
+	off := in.pos - in.bufStart
+	in.pos += int64(n)
+	return in.buf[off : off+int64(n)], nil
+}
+
+func (in *DataInput) readByte() (byte, error) {
+	b, err := in.next(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+func (in *DataInput) ReadByte() (byte, error) {
+	in.mark = in.pos
+	return in.readByte()
+}
+
+// ReadBytes fills b completely.
+func (in *DataInput) ReadBytes(b []byte) error {
+	in.mark = in.pos
+	if len(b) > dataInputBufferSize {
+		if in.pos+int64(len(b)) > in.length {
+			return errReadPastEOF
+		}
+		if _, err := in.r.ReadAt(b, in.base+in.pos); err != nil && err != io.EOF {
+			return err
+		}
+		in.pos += int64(len(b))
+		return nil
+	}
+	src, err := in.next(len(b))
+	if err != nil {
+		return err
+	}
+	copy(b, src)
+	return nil
+}
+
+func (in *DataInput) ReadShort() (int16, error) {
+	in.mark = in.pos
+	b, err := in.next(2)
+	if err != nil {
+		return 0, err
+	}
+	return int16(in.order.Uint16(b)), nil
+}
+
+func (in *DataInput) ReadInt() (int32, error) {
+	in.mark = in.pos
+	b, err := in.next(4)
+	if err != nil {
+		return 0, err
+	}
+	return int32(in.order.Uint32(b)), nil
+}
+
+func (in *DataInput) ReadLong() (int64, error) {
+	in.mark = in.pos
+	b, err := in.next(8)
+	if err != nil {
+		return 0, err
+	}
+	return int64(in.order.Uint64(b)), nil
+}
+
+// ReadBEInt reads a big-endian int, as used by codec headers, segments_N and pre-9.0 formats.
+func (in *DataInput) ReadBEInt() (int32, error) {
+	in.mark = in.pos
+	b, err := in.next(4)
+	if err != nil {
+		return 0, err
+	}
+	return int32(binary.BigEndian.Uint32(b)), nil
+}
+
+func (in *DataInput) ReadBELong() (int64, error) {
+	in.mark = in.pos
+	b, err := in.next(8)
+	if err != nil {
+		return 0, err
+	}
+	return int64(binary.BigEndian.Uint64(b)), nil
+}
+
+func (in *DataInput) readVInt() (int32, error) {
+	var result uint32
+	for shift := uint(0); shift < 35; shift += 7 {
+		b, err := in.readByte()
+		if err != nil {
+			return 0, err
+		}
+		result |= uint32(b&0x7F) << shift
+		if b&0x80 == 0 {
+			return int32(result), nil
+		}
+	}
+	return 0, errors.New("invalid vInt (too long)")
+}
+
+func (in *DataInput) ReadVInt() (int32, error) {
+	in.mark = in.pos
+	return in.readVInt()
+}
+
+func (in *DataInput) readVLong() (int64, error) {
+	var result uint64
+	for shift := uint(0); shift < 70; shift += 7 {
+		b, err := in.readByte()
+		if err != nil {
+			return 0, err
+		}
+		result |= uint64(b&0x7F) << shift
+		if b&0x80 == 0 {
+			return int64(result), nil
+		}
+	}
+	return 0, errors.New("invalid vLong (too long)")
+}
+
+func (in *DataInput) ReadVLong() (int64, error) {
+	in.mark = in.pos
+	return in.readVLong()
+}
+
+// ReadZInt reads a zig-zag encoded vInt.
+func (in *DataInput) ReadZInt() (int32, error) {
+	in.mark = in.pos
+	v, err := in.readVInt()
+	return int32(uint32(v)>>1) ^ -(v & 1), err
+}
+
+// ReadZLong reads a zig-zag encoded vLong.
+func (in *DataInput) ReadZLong() (int64, error) {
+	in.mark = in.pos
+	v, err := in.readVLong()
+	return zigZagDecode(v), err
+}
+
+func zigZagDecode(v int64) int64 {
+	return int64(uint64(v)>>1) ^ -(v & 1)
+}
+
+func (in *DataInput) readString() (string, error) {
+	n, err := in.readVInt()
+	if err != nil {
+		return "", err
+	}
+	if n < 0 || int64(n) > in.length-in.pos {
+		return "", fmt.Errorf("invalid string length %d (%d bytes remaining)", n, in.length-in.pos)
+	}
+	b, err := in.next(int(n))
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func (in *DataInput) ReadString() (string, error) {
+	in.mark = in.pos
+	return in.readString()
+}
+
+func (in *DataInput) readCount() (int, error) {
+	n, err := in.readVInt()
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 || int64(n) > in.length-in.pos {
+		return 0, fmt.Errorf("invalid collection size %d", n)
+	}
+	return int(n), nil
+}
+
+func (in *DataInput) ReadSetOfStrings() ([]string, error) {
+	in.mark = in.pos
+	cnt, err := in.readCount()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, 0, cnt)
+	for i := 0; i < cnt; i++ {
+		s, err := in.readString()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+func (in *DataInput) ReadMapOfStrings() (map[string]string, error) {
+	in.mark = in.pos
+	cnt, err := in.readCount()
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]string, cnt)
+	for i := 0; i < cnt; i++ {
+		k, err := in.readString()
+		if err != nil {
+			return nil, err
+		}
+		v, err := in.readString()
+		if err != nil {
+			return nil, err
+		}
+		m[k] = v
+	}
+	return m, nil
+}
+
+// ReadGroupVInts decodes limit ints written by Lucene's GroupVIntUtil: groups
+// of four values share a flag byte holding their byte lengths, and the tail
+// that does not fill a group is written as plain vInts.
+func (in *DataInput) ReadGroupVInts(dst []int64, limit int) error {
+	in.mark = in.pos
+	i := 0
+	for ; i <= limit-4; i += 4 {
+		flag, err := in.readByte()
+		if err != nil {
+			return err
+		}
+		for j := 0; j < 4; j++ {
+			n := int(flag>>(6-2*j))&0x03 + 1
+			b, err := in.next(n)
+			if err != nil {
+				return err
+			}
+			var v uint32
+			for k := n - 1; k >= 0; k-- {
+				v = v<<8 | uint32(b[k])
+			}
+			dst[i+j] = int64(v)
+		}
+	}
+	for ; i < limit; i++ {
+		v, err := in.readVInt()
+		if err != nil {
+			return err
+		}
+		dst[i] = int64(uint32(v))
+	}
+	return nil
+}
+
+// ---------- codec header ----------
+
+type IndexHeader struct {
+	Codec   string
+	Version int32
+	ID      []byte
+	Suffix  string
+}
+
+// HeaderFormat is one codec name a parser accepts, with its supported versions.
+type HeaderFormat struct {
+	Codec      string
+	MinVersion int32
+	MaxVersion int32
+	BigEndian  bool // written before Lucene 9.0
+}
+
+// checkHeader reads a CodecUtil header (magic, codec name, version) and
+// validates the codec name and format version.
+func checkHeader(in *DataInput, codec string, minVersion, maxVersion int32) (*IndexHeader, error) {
+	return checkHeaderOf(in, HeaderFormat{Codec: codec, MinVersion: minVersion, MaxVersion: maxVersion})
+}
+
+// checkHeaderOf accepts any of formats, selected by codec name, and switches
+// the input to big-endian for pre-9.0 formats.
+func checkHeaderOf(in *DataInput, formats ...HeaderFormat) (*IndexHeader, error) {
+	magic, err := in.ReadBEInt()
+	if err != nil {
+		return nil, in.fail("header.magic", err)
+	}
+	if magic != CODEC_MAGIC {
+		return nil, in.fail("header.magic", fmt.Errorf("codec header mismatch: actual header=%#x vs expected header=%#x", uint32(magic), CODEC_MAGIC))
+	}
+	name, err := in.ReadString()
+	if err != nil {
+		return nil, in.fail("header.codec", err)
+	}
+	var format *HeaderFormat
+	for i := range formats {
+		if formats[i].Codec == name {
+			format = &formats[i]
+		}
+	}
+	if format == nil {
+		expected := make([]string, len(formats))
+		for i, f := range formats {
+			expected[i] = f.Codec
+		}
+		return nil, in.fail("header.codec", fmt.Errorf("codec mismatch: actual codec=%s vs expected codec=%s", name, strings.Join(expected, "|")))
+	}
+	version, err := in.ReadBEInt()
+	if err != nil {
+		return nil, in.fail("header.version", err)
+	}
+	if version < format.MinVersion || version > format.MaxVersion {
+		return nil, in.fail("header.version", fmt.Errorf("%w: %s version %d (supported: %d-%d)", ErrUnsupportedFormat, name, version, format.MinVersion, format.MaxVersion))
+	}
+	if format.BigEndian {
+		in.SetByteOrder(binary.BigEndian)
+	}
+	return &IndexHeader{Codec: name, Version: version}, nil
+}
+
+// checkIndexHeader additionally reads the object ID and the suffix that
+// CodecUtil.writeIndexHeader appends.
+func checkIndexHeader(in *DataInput, codec string, minVersion, maxVersion int32) (*IndexHeader, error) {
+	return checkIndexHeaderOf(in, HeaderFormat{Codec: codec, MinVersion: minVersion, MaxVersion: maxVersion})
+}
+
+func checkIndexHeaderOf(in *DataInput, formats ...HeaderFormat) (*IndexHeader, error) {
+	hdr, err := checkHeaderOf(in, formats...)
+	if err != nil {
+		return nil, err
+	}
+	hdr.ID = make([]byte, ID_LENGTH)
+	if err := in.ReadBytes(hdr.ID); err != nil {
+		return nil, in.fail("header.id", err)
+	}
+	n, err := in.ReadByte()
+	if err != nil {
+		return nil, in.fail("header.suffix", err)
+	}
+	suffix := make([]byte, n)
+	if err := in.ReadBytes(suffix); err != nil {
+		return nil, in.fail("header.suffix", err)
+	}
+	hdr.Suffix = string(suffix)
+	return hdr, nil
+}
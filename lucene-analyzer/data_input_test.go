@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+)
+
+func newTestInput(b []byte) *DataInput {
+	return NewDataInput("test.bin", bytes.NewReader(b), int64(len(b)))
+}
+
+// TestDataInputVarInts tests vInt, vLong, zInt and zLong decoding
+func TestDataInputVarInts(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []byte
+		read  func(in *DataInput) (int64, error)
+		want  int64
+	}{
+		{"vint 0", []byte{0x00}, func(in *DataInput) (int64, error) { v, err := in.ReadVInt(); return int64(v), err }, 0},
+		{"vint 300", []byte{0xAC, 0x02}, func(in *DataInput) (int64, error) { v, err := in.ReadVInt(); return int64(v), err }, 300},
+		{"vint -1", []byte{0xFF, 0xFF, 0xFF, 0xFF, 0x0F}, func(in *DataInput) (int64, error) { v, err := in.ReadVInt(); return int64(v), err }, -1},
+		{"vlong 2^35", []byte{0x80, 0x80, 0x80, 0x80, 0x80, 0x01}, func(in *DataInput) (int64, error) { return in.ReadVLong() }, 1 << 35},
+		{"zint -1", []byte{0x01}, func(in *DataInput) (int64, error) { v, err := in.ReadZInt(); return int64(v), err }, -1},
+		{"zint 2", []byte{0x04}, func(in *DataInput) (int64, error) { v, err := in.ReadZInt(); return int64(v), err }, 2},
+		{"zlong -3", []byte{0x05}, func(in *DataInput) (int64, error) { return in.ReadZLong() }, -3},
+		{"zlong min", []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x01}, func(in *DataInput) (int64, error) { return in.ReadZLong() }, -1 << 63},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := newTestInput(tt.input)
+			got, err := tt.read(in)
+			if err != nil {
+				t.Fatalf("read() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("read() = %d, want %d", got, tt.want)
+			}
+			if in.Pos() != int64(len(tt.input)) {
+				t.Errorf("Pos() = %d, want %d", in.Pos(), len(tt.input))
+			}
+		})
+	}
+}
+
+// TestDataInputFixedWidth tests little- and big-endian fixed width reads
+func TestDataInputFixedWidth(t *testing.T) {
+	in := newTestInput([]byte{
+		0x34, 0x12, // LE short
+		0x78, 0x56, 0x34, 0x12, // LE int
+		0x08, 0x07, 0x06, 0x05, 0x04, 0x03, 0x02, 0x01, // LE long
+		0x12, 0x34, 0x56, 0x78, // BE int
+		0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, // BE long
+	})
+	if v, err := in.ReadShort(); err != nil || v != 0x1234 {
+		t.Errorf("ReadShort() = %#x, %v", v, err)
+	}
+	if v, err := in.ReadInt(); err != nil || v != 0x12345678 {
+		t.Errorf("ReadInt() = %#x, %v", v, err)
+	}
+	if v, err := in.ReadLong(); err != nil || v != 0x0102030405060708 {
+		t.Errorf("ReadLong() = %#x, %v", v, err)
+	}
+	if v, err := in.ReadBEInt(); err != nil || v != 0x12345678 {
+		t.Errorf("ReadBEInt() = %#x, %v", v, err)
+	}
+	if v, err := in.ReadBELong(); err != nil || v != 0x0102030405060708 {
+		t.Errorf("ReadBELong() = %#x, %v", v, err)
+	}
+	if _, err := in.ReadByte(); !errors.Is(err, errReadPastEOF) {
+		t.Errorf("ReadByte() at EOF error = %v, want %v", err, errReadPastEOF)
+	}
+}
+
+// TestDataInputStrings tests strings, sets and maps
+func TestDataInputStrings(t *testing.T) {
+	in := newTestInput([]byte{
+		3, 'a', 'b', 'c',
+		2, 1, 'x', 1, 'y',
+		1, 1, 'k', 1, 'v',
+		9, 'z', // string longer than the input
+	})
+	if s, err := in.ReadString(); err != nil || s != "abc" {
+		t.Errorf("ReadString() = %q, %v", s, err)
+	}
+	if set, err := in.ReadSetOfStrings(); err != nil || len(set) != 2 || set[0] != "x" || set[1] != "y" {
+		t.Errorf("ReadSetOfStrings() = %v, %v", set, err)
+	}
+	if m, err := in.ReadMapOfStrings(); err != nil || len(m) != 1 || m["k"] != "v" {
+		t.Errorf("ReadMapOfStrings() = %v, %v", m, err)
+	}
+	if _, err := in.ReadString(); err == nil {
+		t.Errorf("ReadString() with oversized length should fail")
+	}
+}
+
+// TestDataInputGroupVInts tests GroupVIntUtil decoding, including the vInt tail
+func TestDataInputGroupVInts(t *testing.T) {
+	// flag 0b00_01_10_11: lengths 1, 2, 3, 4 bytes
+	in := newTestInput([]byte{
+		0x1B,
+		0x05,
+		0x00, 0x01,
+		0x01, 0x00, 0x01,
+		0xFF, 0xFF, 0xFF, 0xFF,
+		0xAC, 0x02, // tail vInt 300
+	})
+	dst := make([]int64, 5)
+	if err := in.ReadGroupVInts(dst, 5); err != nil {
+		t.Fatalf("ReadGroupVInts() error = %v", err)
+	}
+	want := []int64{5, 256, 65537, 0xFFFFFFFF, 300}
+	for i := range want {
+		if dst[i] != want[i] {
+			t.Errorf("ReadGroupVInts()[%d] = %d, want %d", i, dst[i], want[i])
+		}
+	}
+}
+
+// TestDataInputSeekAndSlice tests positioning, slicing and reads that cross the buffer
+func TestDataInputSeekAndSlice(t *testing.T) {
+	data := make([]byte, 3*dataInputBufferSize)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	in := newTestInput(data)
+
+	if err := in.SeekTo(dataInputBufferSize - 2); err != nil {
+		t.Fatalf("SeekTo() error = %v", err)
+	}
+	if v, err := in.ReadInt(); err != nil || v != 0x01_00_FF_FE {
+		t.Errorf("ReadInt() across buffer boundary = %#x, %v", v, err)
+	}
+	big := make([]byte, dataInputBufferSize+10)
+	if err := in.ReadBytes(big); err != nil {
+		t.Fatalf("ReadBytes() error = %v", err)
+	}
+	if big[0] != data[dataInputBufferSize+2] || in.Pos() != 2*dataInputBufferSize+12 {
+		t.Errorf("ReadBytes() got first byte %d, pos %d", big[0], in.Pos())
+	}
+	if err := in.SeekTo(in.Length() + 1); err == nil {
+		t.Errorf("SeekTo() past the end should fail")
+	}
+
+	sl, err := in.Slice("sub", 100, 4)
+	if err != nil {
+		t.Fatalf("Slice() error = %v", err)
+	}
+	b, err := sl.ReadByte()
+	if err != nil || b != 100 {
+		t.Errorf("Slice().ReadByte() = %d, %v, want 100", b, err)
+	}
+	if err := sl.SkipBytes(3); err != nil {
+		t.Errorf("SkipBytes() error = %v", err)
+	}
+	if _, err := sl.ReadByte(); err == nil {
+		t.Errorf("reading past the end of a slice should fail")
+	}
+	if _, err := in.Slice("bad", in.Length()-1, 2); err == nil {
+		t.Errorf("Slice() past the end should fail")
+	}
+}
+
+// TestDataInputFail tests that fail() reports the offset at which the failing read started
+func TestDataInputFail(t *testing.T) {
+	in := newTestInput([]byte{0x01, 0x80, 0x80})
+	if _, err := in.ReadByte(); err != nil {
+		t.Fatal(err)
+	}
+	_, err := in.ReadVInt()
+	perr := in.fail("count", err)
+
+	var pe *ParseError
+	if !errors.As(perr, &pe) {
+		t.Fatalf("fail() = %T, want *ParseError", perr)
+	}
+	if pe.Offset != 1 || pe.Field != "count" || pe.File != "test.bin" {
+		t.Errorf("fail() = %+v, want offset 1, field count", pe)
+	}
+	if errors.Is(perr, io.EOF) {
+		t.Errorf("fail() should not surface a bare io.EOF")
+	}
+	if again := in.fail("outer", perr); again != perr {
+		t.Errorf("fail() should keep an existing ParseError unchanged")
+	}
+}
+
+// writeIndexHeader writes a CodecUtil index header with an all-zero ID
+func writeIndexHeader(buf *bytes.Buffer, codec string, version int32, suffix string) {
+	binary.Write(buf, binary.BigEndian, int32(CODEC_MAGIC))
+	writeString(buf, codec)
+	binary.Write(buf, binary.BigEndian, version)
+	buf.Write(make([]byte, ID_LENGTH))
+	buf.WriteByte(byte(len(suffix)))
+	buf.WriteString(suffix)
+}
+
+func writeString(buf *bytes.Buffer, s string) {
+	writeVIntBytes(buf, len(s))
+	buf.WriteString(s)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// ---------- Directory: where index files are read from ----------
+
+// Directory lists and opens index files. FSDirectory serves plain files of an
+// index directory, CompoundDirectory serves the sub-files of a compound
+// segment, so format parsers never need to know which one they are reading.
+type Directory interface {
+	ListAll() ([]string, error)
+	FileLength(name string) (int64, error)
+	OpenInput(name string) (*DataInput, error)
+	Close() error
+}
+
+// FSDirectory is a Directory over a plain filesystem path.
+type FSDirectory struct {
+	Path string
+}
+
+func (d FSDirectory) ListAll() ([]string, error) {
+	entries, err := os.ReadDir(d.Path)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if !e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (d FSDirectory) FileLength(name string) (int64, error) {
+	fi, err := os.Stat(filepath.Join(d.Path, name))
+	if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
+
+func (d FSDirectory) OpenInput(name string) (*DataInput, error) {
+	return OpenDataInput(filepath.Join(d.Path, name))
+}
+
+func (d FSDirectory) Close() error { return nil }
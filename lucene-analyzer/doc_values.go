@@ -0,0 +1,703 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"strconv"
+)
+
+// ---------- doc values metadata (.dvm) per Lucene90DocValuesFormat ----------
+
+const (
+	DOC_VALUES_FORMAT_NAME = "Lucene90"
+	DOC_VALUES_META_CODEC  = "Lucene90DocValuesMetadata"
+	DOC_VALUES_DATA_CODEC  = "Lucene90DocValuesData"
+	DOC_VALUES_VERSION     = 0
+
+	DV_TERMS_DICT_BLOCK_LZ4_SHIFT = 6 // 每个 LZ4 块 64 个 term
+
+	// PerFieldDocValuesFormat 写入 .fnm 的属性，决定文件名后缀
+	PER_FIELD_DV_FORMAT_ATTR = "PerFieldDocValuesFormat.format"
+	PER_FIELD_DV_SUFFIX_ATTR = "PerFieldDocValuesFormat.suffix"
+
+	ES_SOFT_DELETES_FIELD = "__soft_deletes"
+)
+
+// docsWithField locates the IndexedDISI of the documents that have a value
+// in the .dvd file. Offset -2 means no document has one, -1 means all do.
+type docsWithField struct {
+	offset              int64
+	length              int64
+	jumpTableEntryCount int16
+	denseRankPower      int8
+}
+
+func (d docsWithField) empty() bool { return d.offset == -2 }
+func (d docsWithField) all() bool   { return d.offset == -1 }
+
+// docValuesEntry is the metadata of one field in a .dvm file.
+type docValuesEntry struct {
+	field int32
+	typ   string
+	docs  docsWithField
+
+	numValues        int64 // NUMERIC/SORTED_NUMERIC values, or ords of SORTED/SORTED_SET
+	numDocsWithField int64
+	bitsPerValue     int
+	minValue         int64
+	gcd              int64
+	tableSize        int32 // distinct values of a table-encoded field, -1 otherwise
+	table            []int64
+	blockShift       int32 // values encoded per block of 2^blockShift, 0 otherwise
+	valuesOffset     int64
+	valuesLength     int64
+
+	minLength, maxLength int32 // BINARY
+	dataOffset           int64 // BINARY
+	dataLength           int64 // BINARY
+	termsDictSize        int64 // SORTED/SORTED_SET: unique terms
+	maxTermLength        int32
+	multiValued          bool // SORTED_SET with more than one value per doc
+
+	skipper    bool
+	skipperMin int64
+	skipperMax int64
+	dataBytes  int64 // bytes of the field in .dvd
+}
+
+// docValuesFileName returns the .dvm/.dvd base name of fi: the field's
+// format suffix from PerFieldDocValuesFormat, prefixed by the update
+// generation when the doc values were rewritten by an update.
+func docValuesFileName(segName string, fi FieldInfo) (string, error) {
+	format, suffix := fi.Attributes[PER_FIELD_DV_FORMAT_ATTR], fi.Attributes[PER_FIELD_DV_SUFFIX_ATTR]
+	if format == "" || suffix == "" {
+		return "", fmt.Errorf("field %s has no per-field doc values format attributes", fi.Name)
+	}
+	name := segName
+	if fi.DocValuesGen > 0 {
+		name += "_" + strconv.FormatInt(fi.DocValuesGen, 36)
+	}
+	return name + "_" + format + "_" + suffix, nil
+}
+
+// readDocValuesMeta reads every field entry of <base>.dvm.
+// Meta: Header, <FieldNumber(int), Type(byte), [Skipper], Entry>*, -1, Footer
+func readDocValuesMeta(dir Directory, segName, base string, fields []FieldInfo) ([]docValuesEntry, error) {
+	in, err := dir.OpenInput(base + ".dvm")
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	hdr, err := checkIndexHeader(in, DOC_VALUES_META_CODEC, DOC_VALUES_VERSION, DOC_VALUES_VERSION)
+	if err != nil {
+		return nil, err
+	}
+	if want := base[len(segName)+1:]; hdr.Suffix != want {
+		return nil, in.fail("header.suffix", fmt.Errorf("suffix %q does not match file name suffix %q", hdr.Suffix, want))
+	}
+	byNumber := make(map[int32]FieldInfo, len(fields))
+	for _, fi := range fields {
+		byNumber[fi.Number] = fi
+	}
+
+	var entries []docValuesEntry
+	for i := 0; ; i++ {
+		m := &metaReader{in: in, prefix: fmt.Sprintf("fields[%d].", i)}
+		number := m.int("fieldNumber")
+		if m.err != nil {
+			return nil, m.err
+		}
+		if number == -1 {
+			if in.Pos() != in.Length()-FOOTER_LENGTH {
+				return nil, in.fail("footer", fmt.Errorf("%d unread bytes after the last field", in.Length()-FOOTER_LENGTH-in.Pos()))
+			}
+			return entries, nil
+		}
+		fi, ok := byNumber[number]
+		if !ok {
+			return nil, in.fail(m.prefix+"fieldNumber", fmt.Errorf("unknown field number %d", number))
+		}
+		e := docValuesEntry{field: number, tableSize: -1}
+		t := m.byte("type")
+		if m.err == nil && int(t)+1 >= len(docValuesTypeNames) {
+			return nil, in.fail(m.prefix+"type", fmt.Errorf("invalid doc values type %d", t))
+		}
+		e.typ = docValuesTypeNames[t+1]
+		if fi.DocValuesSkipIndex != "" && fi.DocValuesSkipIndex != "NONE" {
+			// Skipper: Offset, Length, MaxValue, MinValue, DocCount(int), MaxDocID(int)
+			e.skipper = true
+			m.long("skipper.offset")
+			e.dataBytes += m.long("skipper.length")
+			e.skipperMax = m.long("skipper.maxValue")
+			e.skipperMin = m.long("skipper.minValue")
+			m.int("skipper.docCount")
+			m.int("skipper.maxDocID")
+		}
+		switch e.typ {
+		case "NUMERIC":
+			m.numeric(&e)
+		case "BINARY":
+			m.binary(&e)
+		case "SORTED":
+			m.numeric(&e)
+			m.termsDict(&e)
+		case "SORTED_SET":
+			switch multi := m.byte("multiValued"); {
+			case m.err != nil:
+			case multi == 0:
+				m.numeric(&e)
+				m.termsDict(&e)
+			case multi == 1:
+				e.multiValued = true
+				m.sortedNumeric(&e)
+				m.termsDict(&e)
+			default:
+				return nil, in.fail(m.prefix+"multiValued", fmt.Errorf("invalid value %d", multi))
+			}
+		case "SORTED_NUMERIC":
+			m.sortedNumeric(&e)
+		}
+		if m.err != nil {
+			return nil, m.err
+		}
+		entries = append(entries, e)
+	}
+}
+
+// metaReader reads a run of metadata values, keeping the first error and
+// the name of the value that caused it.
+type metaReader struct {
+	in     *DataInput
+	prefix string
+	err    error
+}
+
+func (m *metaReader) int(field string) int32 {
+	if m.err != nil {
+		return 0
+	}
+	v, err := m.in.ReadInt()
+	if err != nil {
+		m.err = m.in.fail(m.prefix+field, err)
+	}
+	return v
+}
+
+func (m *metaReader) long(field string) int64 {
+	if m.err != nil {
+		return 0
+	}
+	v, err := m.in.ReadLong()
+	if err != nil {
+		m.err = m.in.fail(m.prefix+field, err)
+	}
+	return v
+}
+
+func (m *metaReader) short(field string) int16 {
+	if m.err != nil {
+		return 0
+	}
+	v, err := m.in.ReadShort()
+	if err != nil {
+		m.err = m.in.fail(m.prefix+field, err)
+	}
+	return v
+}
+
+func (m *metaReader) byte(field string) byte {
+	if m.err != nil {
+		return 0
+	}
+	v, err := m.in.ReadByte()
+	if err != nil {
+		m.err = m.in.fail(m.prefix+field, err)
+	}
+	return v
+}
+
+func (m *metaReader) vInt(field string) int32 {
+	if m.err != nil {
+		return 0
+	}
+	v, err := m.in.ReadVInt()
+	if err != nil {
+		m.err = m.in.fail(m.prefix+field, err)
+	}
+	return v
+}
+
+func (m *metaReader) vLong(field string) int64 {
+	if m.err != nil {
+		return 0
+	}
+	v, err := m.in.ReadVLong()
+	if err != nil {
+		m.err = m.in.fail(m.prefix+field, err)
+	}
+	return v
+}
+
+func (m *metaReader) monotonic(field string, numValues int64, blockShift int32) {
+	if m.err != nil {
+		return
+	}
+	_, m.err = readDirectMonotonicMeta(m.in, m.prefix+field, numValues, blockShift)
+}
+
+func (m *metaReader) docsWithField(e *docValuesEntry) {
+	e.docs = docsWithField{
+		offset:              m.long("docsWithFieldOffset"),
+		length:              m.long("docsWithFieldLength"),
+		jumpTableEntryCount: m.short("jumpTableEntryCount"),
+		denseRankPower:      int8(m.byte("denseRankPower")),
+	}
+	if e.docs.offset >= 0 {
+		e.dataBytes += e.docs.length
+	}
+}
+
+// numeric: DocsWithField, NumValues, TableSize, [Table], BitsPerValue, MinValue, GCD, ValuesOffset, ValuesLength, ValueJumpTableOffset
+func (m *metaReader) numeric(e *docValuesEntry) {
+	m.docsWithField(e)
+	e.numValues = m.long("numValues")
+	e.tableSize = m.int("tableSize")
+	if m.err == nil && e.tableSize > 256 {
+		m.err = m.in.fail(m.prefix+"tableSize", fmt.Errorf("invalid table size %d", e.tableSize))
+	}
+	for i := int32(0); i < e.tableSize; i++ {
+		e.table = append(e.table, m.long(fmt.Sprintf("table[%d]", i)))
+	}
+	if e.tableSize < -1 {
+		e.blockShift = -2 - e.tableSize // 负数表示按块编码：blockShift = -2 - tableSize
+		e.tableSize = -1
+	}
+	e.bitsPerValue = int(m.byte("bitsPerValue"))
+	e.minValue = m.long("minValue")
+	e.gcd = m.long("gcd")
+	e.valuesOffset = m.long("valuesOffset")
+	e.valuesLength = m.long("valuesLength")
+	e.dataBytes += e.valuesLength
+	m.long("valueJumpTableOffset")
+}
+
+// sortedNumeric: Numeric, NumDocsWithField, [AddressesOffset, BlockShift, Addresses, AddressesLength]
+func (m *metaReader) sortedNumeric(e *docValuesEntry) {
+	m.numeric(e)
+	e.numDocsWithField = int64(m.int("numDocsWithField"))
+	if m.err == nil && e.numDocsWithField != e.numValues {
+		m.long("addressesOffset")
+		blockShift := m.vInt("addressesBlockShift")
+		m.monotonic("addresses", e.numDocsWithField+1, blockShift)
+		e.dataBytes += m.long("addressesLength")
+	}
+}
+
+// binary: DataOffset, DataLength, DocsWithField, NumDocsWithField, MinLength, MaxLength, [AddressesOffset, BlockShift, Addresses, AddressesLength]
+func (m *metaReader) binary(e *docValuesEntry) {
+	e.dataOffset = m.long("dataOffset")
+	e.dataLength = m.long("dataLength")
+	e.dataBytes += e.dataLength
+	m.docsWithField(e)
+	e.numDocsWithField = int64(m.int("numDocsWithField"))
+	e.minLength = m.int("minLength")
+	e.maxLength = m.int("maxLength")
+	if m.err == nil && e.minLength < e.maxLength {
+		m.long("addressesOffset")
+		blockShift := m.vInt("addressesBlockShift")
+		m.monotonic("addresses", e.numDocsWithField+1, blockShift)
+		e.dataBytes += m.long("addressesLength")
+	}
+}
+
+// termsDict: Size(vLong), BlockShift, Addresses, MaxTermLength, MaxBlockLength, data/addresses offsets and lengths, IndexShift, IndexAddresses, index offsets and lengths
+func (m *metaReader) termsDict(e *docValuesEntry) {
+	e.termsDictSize = m.vLong("termsDictSize")
+	blockShift := m.int("termsDictBlockShift")
+	numBlocks := (e.termsDictSize + 1<<DV_TERMS_DICT_BLOCK_LZ4_SHIFT - 1) >> DV_TERMS_DICT_BLOCK_LZ4_SHIFT
+	m.monotonic("termsAddresses", numBlocks, blockShift)
+	e.maxTermLength = m.int("maxTermLength")
+	m.int("maxBlockLength")
+	m.long("termsDataOffset")
+	e.dataBytes += m.long("termsDataLength")
+	m.long("termsAddressesOffset")
+	e.dataBytes += m.long("termsAddressesLength")
+	indexShift := m.int("termsDictIndexShift")
+	if m.err == nil && (indexShift < 0 || indexShift > 30) {
+		m.err = m.in.fail(m.prefix+"termsDictIndexShift", fmt.Errorf("invalid shift %d", indexShift))
+	}
+	indexSize := (e.termsDictSize + 1<<indexShift - 1) >> indexShift
+	m.monotonic("termsIndexAddresses", 1+indexSize, blockShift)
+	m.long("termsIndexOffset")
+	e.dataBytes += m.long("termsIndexLength")
+	m.long("termsIndexAddressesOffset")
+	e.dataBytes += m.long("termsIndexAddressesLength")
+}
+
+// ---------- per-field doc values report ----------
+
+// FieldDocValues describes the doc values of one field in .dvm/.dvd.
+type FieldDocValues struct {
+	File          string `json:"file"`                 // base name of the .dvm/.dvd pair, with the update generation if any
+	DocCount      int64  `json:"doc_count"`            // documents with a value
+	NumValues     int64  `json:"num_values,omitempty"` // values, or ords of SORTED/SORTED_SET; above doc_count for multi-valued fields
+	Encoding      string `json:"encoding,omitempty"`   // constant, table, gcd, delta or blocks
+	BitsPerValue  int    `json:"bits_per_value,omitempty"`
+	TableSize     int32  `json:"table_size,omitempty"`
+	GCD           int64  `json:"gcd,omitempty"` // only when greater than 1
+	Min           *int64 `json:"min,omitempty"` // NUMERIC and SORTED_NUMERIC, decoded from .dvd only with ?doc_values_range=true
+	Max           *int64 `json:"max,omitempty"`
+	OrdCount      int64  `json:"ord_count,omitempty"` // SORTED and SORTED_SET: unique terms
+	MaxTermLength int32  `json:"max_term_length,omitempty"`
+	MinLength     int32  `json:"min_length,omitempty"` // BINARY
+	MaxLength     int32  `json:"max_length,omitempty"`
+	SkipIndex     bool   `json:"skip_index"`
+	SizeBytes     int64  `json:"size_bytes"` // the field's data in .dvd
+}
+
+// addDocValuesRanges re-reads the doc values of every segment of rep with
+// scan, decoding every value in .dvd for the bounds the metadata lacks, so
+// /analyze only does it with ?doc_values_range=true; errors are recorded on
+// the segment.
+func addDocValuesRanges(rep *Report) {
+	dir := FSDirectory{rep.IndexPath}
+	for i := range rep.Segments {
+		s := &rep.Segments[i]
+		if s.siErr != nil {
+			continue // 已记录在 s.Errors 中
+		}
+		segDir, err := openSegmentDirectory(dir, s)
+		if err == nil {
+			err = parseDocValues(dir, segDir, s, true)
+			segDir.Close()
+		}
+		if err != nil {
+			s.Errors = append(s.Errors, err.Error())
+		}
+	}
+}
+
+// parseDocValues reads every Lucene90 .dvm of the segment, including the
+// ones written by doc values updates, and fills fields[].doc_values. Fields
+// of other formats (such as Elasticsearch's ES87TSDB) are left out. Numeric
+// bounds that .dvm does not record are decoded from .dvd only with scan.
+func parseDocValues(dir, segDir Directory, s *SegInfoSummary, scan bool) error {
+	var bases []string
+	byBase := map[string][]int{}
+	for i, fi := range s.Fields {
+		if fi.DocValuesType == "NONE" || fi.Attributes[PER_FIELD_DV_FORMAT_ATTR] != DOC_VALUES_FORMAT_NAME {
+			continue
+		}
+		base, err := docValuesFileName(s.SegName, fi)
+		if err != nil {
+			return err
+		}
+		if byBase[base] == nil {
+			bases = append(bases, base)
+		}
+		byBase[base] = append(byBase[base], i)
+	}
+	for _, base := range bases {
+		// 更新后的 doc values 位于复合文件之外
+		dvDir := segDir
+		if s.Fields[byBase[base][0]].DocValuesGen > 0 {
+			dvDir = dir
+		}
+		if err := parseDocValuesFile(dvDir, s, base, byBase[base], scan); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseDocValuesFile fills the doc values of the fields at indexes, all of
+// which live in <base>.dvm. Entries of fields that were updated since are
+// still present in the original file and are skipped.
+func parseDocValuesFile(dir Directory, s *SegInfoSummary, base string, indexes []int, scan bool) error {
+	entries, err := readDocValuesMeta(dir, s.SegName, base, s.Fields)
+	if err != nil {
+		return err
+	}
+	byNumber := make(map[int32]docValuesEntry, len(entries))
+	for _, e := range entries {
+		byNumber[e.field] = e
+	}
+
+	var data *DataInput
+	defer func() {
+		if data != nil {
+			data.Close()
+		}
+	}()
+	for _, i := range indexes {
+		fi := &s.Fields[i]
+		e, ok := byNumber[fi.Number]
+		if !ok {
+			return fmt.Errorf("%s.dvm has no entry for field %s", base, fi.Name)
+		}
+		if e.typ != fi.DocValuesType {
+			return fmt.Errorf("%s.dvm has %s doc values for field %s, field infos say %s", base, e.typ, fi.Name, fi.DocValuesType)
+		}
+		dv := &FieldDocValues{File: base, DocCount: e.numValues, SkipIndex: e.skipper, SizeBytes: e.dataBytes}
+		if e.typ == "BINARY" || e.typ == "SORTED_NUMERIC" || e.multiValued {
+			dv.DocCount = e.numDocsWithField
+		}
+		if e.typ == "BINARY" {
+			dv.MinLength, dv.MaxLength = e.minLength, e.maxLength
+			fi.DocValues = dv
+			continue
+		}
+		dv.NumValues = e.numValues
+		dv.Encoding = e.encoding()
+		if e.bitsPerValue <= 64 {
+			dv.BitsPerValue = e.bitsPerValue
+		}
+		if dv.Encoding == "table" {
+			dv.TableSize = e.tableSize
+		}
+		if dv.Encoding != "constant" && e.gcd > 1 {
+			dv.GCD = e.gcd
+		}
+		if e.typ == "SORTED" || e.typ == "SORTED_SET" {
+			dv.OrdCount, dv.MaxTermLength = e.termsDictSize, e.maxTermLength
+		} else if lo, hi, ok := metaRange(e); ok {
+			dv.Min, dv.Max = &lo, &hi
+		} else if scan && e.numValues > 0 {
+			if data == nil {
+				if data, err = dir.OpenInput(base + ".dvd"); err != nil {
+					return err
+				}
+				if _, err := checkIndexHeader(data, DOC_VALUES_DATA_CODEC, DOC_VALUES_VERSION, DOC_VALUES_VERSION); err != nil {
+					return err
+				}
+			}
+			lo, hi, err := scanRange(data, e)
+			if err != nil {
+				return data.fail(fi.Name+".values", err)
+			}
+			dv.Min, dv.Max = &lo, &hi
+		}
+		fi.DocValues = dv
+	}
+	return nil
+}
+
+// encoding names how the values (or ords) of e are packed in .dvd.
+func (e docValuesEntry) encoding() string {
+	switch {
+	case e.blockShift > 0:
+		return "blocks"
+	case e.tableSize >= 0:
+		return "table"
+	case e.bitsPerValue == 0:
+		return "constant"
+	case e.gcd != 1:
+		return "gcd"
+	}
+	return "delta"
+}
+
+// metaRange returns the smallest and largest value of a numeric entry when
+// .dvm records them: the skip index and table encodings store both, and
+// constant values are stored as min.
+func metaRange(e docValuesEntry) (int64, int64, bool) {
+	switch {
+	case e.numValues == 0:
+	case e.skipper:
+		return e.skipperMin, e.skipperMax, true
+	case e.tableSize > 0:
+		return e.table[0], e.table[e.tableSize-1], true
+	case e.bitsPerValue == 0:
+		return e.minValue, e.minValue, true
+	}
+	return 0, 0, false
+}
+
+// scanRange decodes every value of a numeric entry from data to find the
+// smallest and largest one.
+// Blocks: <BitsPerValue(byte), Min(long), [Length(int), Values]>
+func scanRange(data *DataInput, e docValuesEntry) (int64, int64, error) {
+	lo, hi := int64(math.MaxInt64), int64(math.MinInt64)
+	base := e.minValue
+	add := func(v uint64) {
+		x := base + int64(v)*e.gcd
+		lo, hi = min(lo, x), max(hi, x)
+	}
+	if err := data.SeekTo(e.valuesOffset); err != nil {
+		return 0, 0, err
+	}
+	if e.blockShift == 0 {
+		return lo, hi, directScan(data, e.bitsPerValue, e.numValues, add)
+	}
+	for n := e.numValues; n > 0; n -= 1 << e.blockShift {
+		bpv, err := data.ReadByte()
+		if err != nil {
+			return 0, 0, err
+		}
+		if base, err = data.ReadLong(); err != nil {
+			return 0, 0, err
+		}
+		if bpv == 0 {
+			add(0)
+			continue
+		}
+		length, err := data.ReadInt()
+		if err != nil {
+			return 0, 0, err
+		}
+		start := data.Pos()
+		if err := directScan(data, int(bpv), min(n, 1<<e.blockShift), add); err != nil {
+			return 0, 0, err
+		}
+		if err := data.SeekTo(start + int64(length)); err != nil {
+			return 0, 0, err
+		}
+	}
+	return lo, hi, nil
+}
+
+// ---------- IndexedDISI: the set of documents with a value ----------
+
+const (
+	DISI_BLOCK_SIZE       = 1 << 16
+	DISI_MAX_ARRAY_LENGTH = 1<<12 - 1 // 不超过此基数的块存为 short 数组
+	DISI_DENSE_LONGS      = DISI_BLOCK_SIZE / 64
+	DISI_END_BLOCK        = 0x7FFF // NO_MORE_DOCS >>> 16
+)
+
+// bitSet is a fixed-size set of doc IDs.
+type bitSet []uint64
+
+func newBitSet(numBits int) bitSet { return make(bitSet, (numBits+63)/64) }
+
+func (b bitSet) get(doc int) bool { return b[doc>>6]&(1<<uint(doc&63)) != 0 }
+func (b bitSet) set(doc int)      { b[doc>>6] |= 1 << uint(doc&63) }
+
+// readIndexedDISI decodes the documents of d from the .dvd file. Blocks of
+// 65536 docs are stored sparse (short array), dense (rank table + bitset)
+// or as "all"; a block with index 0x7FFF terminates the set.
+// Block: BlockIndex(short), Cardinality-1(short), Docs
+func readIndexedDISI(data *DataInput, d docsWithField, maxDoc int) (bitSet, error) {
+	docs := newBitSet(maxDoc)
+	if d.empty() {
+		return docs, nil
+	}
+	if d.all() {
+		for doc := 0; doc < maxDoc; doc++ {
+			docs.set(doc)
+		}
+		return docs, nil
+	}
+	in, err := data.Slice(data.Name()+"[docsWithField]", d.offset, d.length)
+	if err != nil {
+		return nil, err
+	}
+	for prev := -1; ; {
+		hdr, err := in.next(4)
+		if err != nil {
+			return nil, in.fail("block.header", err)
+		}
+		block := int(uint16(hdr[0]) | uint16(hdr[1])<<8)
+		cardinality := int(uint16(hdr[2])|uint16(hdr[3])<<8) + 1
+		if block == DISI_END_BLOCK {
+			return docs, nil
+		}
+		if block <= prev {
+			return nil, in.fail("block.index", fmt.Errorf("block %d after block %d", block, prev))
+		}
+		prev = block
+		base := block << 16
+		field := fmt.Sprintf("blocks[%d]", block)
+		switch {
+		case cardinality == DISI_BLOCK_SIZE:
+			if base+DISI_BLOCK_SIZE > maxDoc {
+				return nil, in.fail(field, fmt.Errorf("block beyond maxDoc %d", maxDoc))
+			}
+			for doc := base; doc < base+DISI_BLOCK_SIZE; doc++ {
+				docs.set(doc)
+			}
+		case cardinality <= DISI_MAX_ARRAY_LENGTH:
+			for i := 0; i < cardinality; i++ {
+				s, err := in.ReadShort()
+				if err != nil {
+					return nil, in.fail(field+".docs", err)
+				}
+				doc := base | int(uint16(s))
+				if doc >= maxDoc {
+					return nil, in.fail(field+".docs", fmt.Errorf("doc %d beyond maxDoc %d", doc, maxDoc))
+				}
+				docs.set(doc)
+			}
+		default:
+			if d.denseRankPower != -1 {
+				if err := in.SkipBytes(int64(DISI_DENSE_LONGS >> (d.denseRankPower - 7))); err != nil {
+					return nil, in.fail(field+".rank", err)
+				}
+			}
+			for i := 0; i < DISI_DENSE_LONGS; i++ {
+				w, err := in.ReadLong()
+				if err != nil {
+					return nil, in.fail(field+".bits", err)
+				}
+				for ; w != 0; w &= w - 1 {
+					doc := base + i*64 + bits.TrailingZeros64(uint64(w))
+					if doc >= maxDoc {
+						return nil, in.fail(field+".bits", fmt.Errorf("doc %d beyond maxDoc %d", doc, maxDoc))
+					}
+					docs.set(doc)
+				}
+			}
+		}
+	}
+}
+
+// ---------- soft deletes ----------
+
+// readSoftDeletes returns the documents that have a value in the soft-deletes
+// doc values field (Elasticsearch's __soft_deletes), or nil when the segment
+// has no such field. Updated doc values are read from the index directory
+// dir, the original ones from segDir.
+func readSoftDeletes(dir, segDir Directory, s *SegInfoSummary) (bitSet, error) {
+	var fi *FieldInfo
+	for i := range s.Fields {
+		if s.Fields[i].SoftDeletes || s.Fields[i].Name == ES_SOFT_DELETES_FIELD {
+			fi = &s.Fields[i]
+			break
+		}
+	}
+	if fi == nil || fi.DocValuesType == "NONE" {
+		return nil, nil
+	}
+	base, err := docValuesFileName(s.SegName, *fi)
+	if err != nil {
+		return nil, err
+	}
+	// 更新后的 doc values 位于复合文件之外
+	dvDir := segDir
+	if fi.DocValuesGen > 0 {
+		dvDir = dir
+	}
+	entries, err := readDocValuesMeta(dvDir, s.SegName, base, s.Fields)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.field != fi.Number {
+			continue
+		}
+		if e.docs.empty() || e.docs.all() {
+			return readIndexedDISI(nil, e.docs, int(s.MaxDoc))
+		}
+		data, err := dvDir.OpenInput(base + ".dvd")
+		if err != nil {
+			return nil, err
+		}
+		defer data.Close()
+		if _, err := checkIndexHeader(data, DOC_VALUES_DATA_CODEC, DOC_VALUES_VERSION, DOC_VALUES_VERSION); err != nil {
+			return nil, err
+		}
+		return readIndexedDISI(data, e.docs, int(s.MaxDoc))
+	}
+	return nil, fmt.Errorf("%s.dvm has no entry for soft deletes field %s", base, fi.Name)
+}
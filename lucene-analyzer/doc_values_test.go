@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeIndexedDISI writes docs (sorted) in IndexedDISI blocks with rank power 9
+func writeIndexedDISI(buf *bytes.Buffer, docs []int) {
+	le := binary.LittleEndian
+	for len(docs) > 0 {
+		block := docs[0] >> 16
+		n := 0
+		for n < len(docs) && docs[n]>>16 == block {
+			n++
+		}
+		binary.Write(buf, le, uint16(block))
+		binary.Write(buf, le, uint16(n-1))
+		switch {
+		case n == DISI_BLOCK_SIZE:
+		case n <= DISI_MAX_ARRAY_LENGTH:
+			for _, doc := range docs[:n] {
+				binary.Write(buf, le, uint16(doc))
+			}
+		default:
+			buf.Write(make([]byte, DISI_DENSE_LONGS>>2)) // rank table, not needed to decode
+			words := make([]uint64, DISI_DENSE_LONGS)
+			for _, doc := range docs[:n] {
+				words[doc&0xFFFF>>6] |= 1 << (doc & 63)
+			}
+			binary.Write(buf, le, words)
+		}
+		docs = docs[n:]
+	}
+	binary.Write(buf, le, []uint16{DISI_END_BLOCK, 0, 0xFFFF})
+}
+
+// TestReadIndexedDISI tests sparse, dense and full blocks
+func TestReadIndexedDISI(t *testing.T) {
+	var docs []int
+	docs = append(docs, 3, 5, 65535)         // block 0: sparse
+	for doc := 1 << 16; doc < 2<<16; doc++ { // block 1: all
+		docs = append(docs, doc)
+	}
+	for doc := 2 << 16; doc < 2<<16+10000; doc += 2 { // block 2: dense
+		docs = append(docs, doc)
+	}
+	maxDoc := 2<<16 + 10000
+
+	var buf bytes.Buffer
+	buf.Write([]byte("padding"))
+	writeIndexedDISI(&buf, docs)
+	d := docsWithField{offset: 7, length: int64(buf.Len() - 7), denseRankPower: 9}
+	got, err := readIndexedDISI(newTestInput(buf.Bytes()), d, maxDoc)
+	if err != nil {
+		t.Fatalf("readIndexedDISI() error = %v", err)
+	}
+	want := newBitSet(maxDoc)
+	for _, doc := range docs {
+		want.set(doc)
+	}
+	for doc := 0; doc < maxDoc; doc++ {
+		if got.get(doc) != want.get(doc) {
+			t.Fatalf("readIndexedDISI() doc %d = %v, want %v", doc, got.get(doc), want.get(doc))
+		}
+	}
+
+	if _, err := readIndexedDISI(newTestInput(buf.Bytes()), d, 2<<16); err == nil {
+		t.Errorf("readIndexedDISI() with docs beyond maxDoc succeeded")
+	}
+	all, err := readIndexedDISI(nil, docsWithField{offset: -1}, 10)
+	if err != nil || !all.get(0) || !all.get(9) {
+		t.Errorf("readIndexedDISI(all) = %v, %v", all, err)
+	}
+}
+
+// TestReadSoftDeletes tests reading the soft deletes field from updated doc values files
+func TestReadSoftDeletes(t *testing.T) {
+	tempDir := t.TempDir()
+	fields := []FieldInfo{
+		{Name: "_id", Number: 0, DocValuesType: "NONE"},
+		{Name: ES_SOFT_DELETES_FIELD, Number: 1, DocValuesType: "NUMERIC", DocValuesGen: 1, SoftDeletes: true,
+			Attributes: map[string]string{PER_FIELD_DV_FORMAT_ATTR: "Lucene90", PER_FIELD_DV_SUFFIX_ATTR: "0"}},
+	}
+
+	var data bytes.Buffer
+	writeIndexHeader(&data, DOC_VALUES_DATA_CODEC, DOC_VALUES_VERSION, "1_Lucene90_0")
+	offset := data.Len()
+	writeIndexedDISI(&data, []int{2, 7})
+	length := data.Len() - offset
+
+	le := binary.LittleEndian
+	var meta bytes.Buffer
+	writeIndexHeader(&meta, DOC_VALUES_META_CODEC, DOC_VALUES_VERSION, "1_Lucene90_0")
+	binary.Write(&meta, le, int32(1)) // field number
+	meta.WriteByte(0)                 // NUMERIC
+	binary.Write(&meta, le, []int64{int64(offset), int64(length)})
+	binary.Write(&meta, le, int16(-1)) // jumpTableEntryCount
+	meta.WriteByte(9)                  // denseRankPower
+	binary.Write(&meta, le, int64(2))  // numValues
+	binary.Write(&meta, le, int32(-1)) // tableSize
+	meta.WriteByte(0)                  // bitsPerValue
+	binary.Write(&meta, le, []int64{1, 0, int64(data.Len()), 0, -1})
+	binary.Write(&meta, le, int32(-1))
+
+	files := map[string][]byte{"_0_1_Lucene90_0.dvm": withFooter(meta.Bytes()), "_0_1_Lucene90_0.dvd": withFooter(data.Bytes())}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tempDir, name), content, 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+
+	s := &SegInfoSummary{SegName: "_0", MaxDoc: 10, Fields: fields}
+	dir := FSDirectory{tempDir}
+	deleted, err := readSoftDeletes(dir, dir, s)
+	if err != nil {
+		t.Fatalf("readSoftDeletes() error = %v", err)
+	}
+	for doc := 0; doc < 10; doc++ {
+		if want := doc == 2 || doc == 7; deleted.get(doc) != want {
+			t.Errorf("readSoftDeletes() doc %d = %v, want %v", doc, deleted.get(doc), want)
+		}
+	}
+
+	// 没有软删除字段的段
+	s.Fields = fields[:1]
+	if deleted, err := readSoftDeletes(dir, dir, s); deleted != nil || err != nil {
+		t.Errorf("readSoftDeletes() without the field = %v, %v, want nil", deleted, err)
+	}
+}
+
+// writeNumericDV writes a numeric entry whose documents all have a value
+func writeNumericDV(meta *bytes.Buffer, numValues int64, tableSize int32, table []int64, bpv byte, minValue, gcd, valuesOffset, valuesLength int64) {
+	le := binary.LittleEndian
+	binary.Write(meta, le, []int64{-1, 0}) // docsWithField: all
+	binary.Write(meta, le, int16(-1))
+	meta.WriteByte(0xFF) // denseRankPower
+	binary.Write(meta, le, numValues)
+	binary.Write(meta, le, tableSize)
+	binary.Write(meta, le, table)
+	meta.WriteByte(bpv)
+	binary.Write(meta, le, []int64{minValue, gcd, valuesOffset, valuesLength, -1})
+}
+
+// TestParseDocValues tests per-field doc values metadata, numeric ranges and updated doc values files
+func TestParseDocValues(t *testing.T) {
+	le := binary.LittleEndian
+	dvAttrs := map[string]string{PER_FIELD_DV_FORMAT_ATTR: "Lucene90", PER_FIELD_DV_SUFFIX_ATTR: "0"}
+	fields := []FieldInfo{
+		{Name: "count", Number: 0, DocValuesType: "NUMERIC", Attributes: dvAttrs},
+		{Name: "bytes", Number: 1, DocValuesType: "NUMERIC", Attributes: dvAttrs},
+		{Name: "status", Number: 2, DocValuesType: "SORTED_NUMERIC", Attributes: dvAttrs},
+		{Name: "hash", Number: 3, DocValuesType: "BINARY", Attributes: dvAttrs},
+		{Name: "ts", Number: 4, DocValuesType: "NUMERIC", DocValuesSkipIndex: "RANGE", Attributes: dvAttrs},
+		{Name: ES_SOFT_DELETES_FIELD, Number: 5, DocValuesType: "NUMERIC", DocValuesGen: 1, SoftDeletes: true, Attributes: dvAttrs},
+		{Name: "tsdb", Number: 6, DocValuesType: "NUMERIC",
+			Attributes: map[string]string{PER_FIELD_DV_FORMAT_ATTR: "ES87TSDB", PER_FIELD_DV_SUFFIX_ATTR: "0"}},
+	}
+
+	var data, meta bytes.Buffer
+	writeIndexHeader(&data, DOC_VALUES_DATA_CODEC, DOC_VALUES_VERSION, "Lucene90_0")
+	writeIndexHeader(&meta, DOC_VALUES_META_CODEC, DOC_VALUES_VERSION, "Lucene90_0")
+
+	// count: 7 3 12 9 3 4, delta from 3 in 4 bits
+	binary.Write(&meta, le, int32(0))
+	meta.WriteByte(0)
+	offset := int64(data.Len())
+	data.Write(packLE([]uint64{4, 0, 9, 6, 0, 1}, 4))
+	writeNumericDV(&meta, 6, -1, nil, 4, 3, 1, offset, int64(data.Len())-offset)
+
+	// bytes: blocks of 4 values with GCD 10: 100 120 150 100 | -30 -30
+	binary.Write(&meta, le, int32(1))
+	meta.WriteByte(0)
+	offset = int64(data.Len())
+	packed := packLE([]uint64{0, 2, 5, 0}, 4)
+	data.WriteByte(4)
+	binary.Write(&data, le, int64(100))
+	binary.Write(&data, le, int32(len(packed)))
+	data.Write(packed)
+	data.WriteByte(0)
+	binary.Write(&data, le, int64(-30))
+	writeNumericDV(&meta, 6, -2-2, nil, 0xFF, -30, 10, offset, int64(data.Len())-offset)
+
+	// status: table of 3 values, one per document
+	binary.Write(&meta, le, int32(2))
+	meta.WriteByte(4)
+	writeNumericDV(&meta, 6, 3, []int64{200, 404, 500}, 2, 0, 1, int64(data.Len()), 2)
+	binary.Write(&meta, le, int32(6)) // numDocsWithField
+	data.Write(make([]byte, 2))
+
+	// hash: 4 bytes per document
+	binary.Write(&meta, le, int32(3))
+	meta.WriteByte(1)
+	binary.Write(&meta, le, []int64{int64(data.Len()), 24, -1, 0})
+	binary.Write(&meta, le, int16(-1))
+	meta.WriteByte(0xFF)
+	binary.Write(&meta, le, []int32{6, 4, 4})
+	data.Write(make([]byte, 24))
+
+	// ts: bounds from the skip index, the values are not read
+	binary.Write(&meta, le, int32(4))
+	meta.WriteByte(0)
+	binary.Write(&meta, le, []int64{0, 40, 1700000009000, 1700000000000})
+	binary.Write(&meta, le, []int32{6, 5})
+	writeNumericDV(&meta, 6, -1, nil, 16, 1700000000000, 1000, 1<<40, 12)
+
+	// __soft_deletes 在原文件中的条目已被 gen 1 取代
+	binary.Write(&meta, le, int32(5))
+	meta.WriteByte(0)
+	writeNumericDV(&meta, 0, -1, nil, 0, 0, 0, 0, 0)
+	binary.Write(&meta, le, int32(-1))
+
+	var updated bytes.Buffer
+	writeIndexHeader(&updated, DOC_VALUES_META_CODEC, DOC_VALUES_VERSION, "1_Lucene90_0")
+	binary.Write(&updated, le, int32(5))
+	updated.WriteByte(0)
+	binary.Write(&updated, le, []int64{100, 10})
+	binary.Write(&updated, le, int16(-1))
+	updated.WriteByte(9)
+	binary.Write(&updated, le, int64(2))
+	binary.Write(&updated, le, int32(-1))
+	updated.WriteByte(0)
+	binary.Write(&updated, le, []int64{1, 0, 110, 0, -1})
+	binary.Write(&updated, le, int32(-1))
+
+	tempDir := t.TempDir()
+	files := map[string][]byte{
+		"_0_Lucene90_0.dvm":   withFooter(meta.Bytes()),
+		"_0_Lucene90_0.dvd":   withFooter(data.Bytes()),
+		"_0_1_Lucene90_0.dvm": withFooter(updated.Bytes()),
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tempDir, name), content, 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+	s := &SegInfoSummary{SegName: "_0", MaxDoc: 6, Fields: fields}
+	dir := FSDirectory{tempDir}
+	if err := parseDocValues(dir, dir, s, false); err != nil {
+		t.Fatalf("parseDocValues() error = %v", err)
+	}
+	// 不扫描 .dvd 时只有元数据中记录的范围
+	for _, i := range []int{0, 1} {
+		if dv := s.Fields[i].DocValues; dv == nil || dv.Min != nil || dv.Max != nil {
+			t.Errorf("%s doc values = %+v, want no bounds without a scan", s.Fields[i].Name, dv)
+		}
+	}
+	if err := parseDocValues(dir, dir, s, true); err != nil {
+		t.Fatalf("parseDocValues() error = %v", err)
+	}
+
+	tests := []struct {
+		field    int
+		encoding string
+		min, max int64
+	}{
+		{0, "delta", 3, 12},
+		{1, "blocks", -30, 150},
+		{2, "table", 200, 500},
+		{4, "gcd", 1700000000000, 1700000009000},
+		{5, "constant", 1, 1},
+	}
+	for _, tt := range tests {
+		fi := s.Fields[tt.field]
+		dv := fi.DocValues
+		if dv == nil || dv.Min == nil || dv.Max == nil {
+			t.Errorf("%s doc values = %+v, want numeric bounds", fi.Name, dv)
+			continue
+		}
+		if dv.Encoding != tt.encoding || *dv.Min != tt.min || *dv.Max != tt.max {
+			t.Errorf("%s doc values = %s [%d, %d], want %s [%d, %d]", fi.Name, dv.Encoding, *dv.Min, *dv.Max, tt.encoding, tt.min, tt.max)
+		}
+	}
+	if dv := s.Fields[1].DocValues; dv.GCD != 10 || dv.BitsPerValue != 0 || dv.SizeBytes != int64(1+8+4+len(packed)+1+8) {
+		t.Errorf("bytes doc values = %+v", dv)
+	}
+	if dv := s.Fields[2].DocValues; dv.TableSize != 3 || dv.DocCount != 6 || dv.NumValues != 6 {
+		t.Errorf("status doc values = %+v", dv)
+	}
+	if dv := s.Fields[3].DocValues; dv.DocCount != 6 || dv.MinLength != 4 || dv.MaxLength != 4 || dv.Encoding != "" || dv.SizeBytes != 24 {
+		t.Errorf("hash doc values = %+v", dv)
+	}
+	if dv := s.Fields[4].DocValues; !dv.SkipIndex || dv.GCD != 1000 || dv.SizeBytes != 52 {
+		t.Errorf("ts doc values = %+v", dv)
+	}
+	if dv := s.Fields[5].DocValues; dv.File != "_0_1_Lucene90_0" || dv.DocCount != 2 || dv.SizeBytes != 10 {
+		t.Errorf("%s doc values = %+v", ES_SOFT_DELETES_FIELD, dv)
+	}
+	if s.Fields[6].DocValues != nil {
+		t.Errorf("tsdb doc values = %+v, want none for another format", s.Fields[6].DocValues)
+	}
+
+	// 类型与 .fnm 不一致
+	s.Fields[2].DocValuesType = "NUMERIC"
+	if err := parseDocValues(dir, dir, s, false); err == nil {
+		t.Errorf("parseDocValues() with a type mismatch succeeded")
+	}
+}
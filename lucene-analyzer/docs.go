@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// ---------- document sampling: decoded stored fields of live documents ----------
+
+const (
+	DOCS_DEFAULT_SIZE = 10
+	DOCS_MAX_SIZE     = 100
+
+	ES_ID_FIELD      = "_id"
+	ES_SOURCE_FIELD  = "_source"
+	ES_ROUTING_FIELD = "_routing"
+
+	// Uid 编码的首字节
+	UID_BASE64_ESCAPE = 0xFD // base64 ids whose decoded first byte is >= 0xFD
+	UID_NUMERIC       = 0xFE
+	UID_UTF8          = 0xFF
+)
+
+// DocSample is a page of live documents read from one segment's stored fields.
+type DocSample struct {
+	Segment string       `json:"segment"`
+	From    int          `json:"from"` // first doc ID considered
+	Size    int          `json:"size"` // requested number of documents
+	Next    int          `json:"next"` // doc ID to continue from, maxDoc when exhausted
+	Docs    []SampledDoc `json:"docs"`
+}
+
+// SampledDoc is one document with the Elasticsearch metadata fields decoded.
+type SampledDoc struct {
+	Doc            int             `json:"doc"` // Lucene doc ID within the segment
+	ID             string          `json:"_id,omitempty"`
+	IDEncoding     string          `json:"_id_encoding,omitempty"` // "hex" when the stored _id is not a valid Uid encoding
+	Routing        string          `json:"_routing,omitempty"`
+	Source         json.RawMessage `json:"_source,omitempty"`
+	SourceEncoding string          `json:"_source_encoding,omitempty"` // "base64" when _source is not JSON (e.g. SMILE)
+	Fields         []StoredField   `json:"fields,omitempty"`           // other stored fields
+}
+
+// sampleDocs reads up to size live documents of segment starting at doc ID
+// from, skipping both .liv deletes and soft deletes. An empty segment name
+// selects the first segment of the report.
+func sampleDocs(rep *Report, segment string, from, size int) (*DocSample, error) {
+	if from < 0 {
+		return nil, fmt.Errorf("invalid from %d", from)
+	}
+	if size <= 0 || size > DOCS_MAX_SIZE {
+		return nil, fmt.Errorf("invalid size %d, must be in [1, %d]", size, DOCS_MAX_SIZE)
+	}
+	var s *SegInfoSummary
+	for i := range rep.Segments {
+		if segment == "" || rep.Segments[i].SegName == segment {
+			s = &rep.Segments[i]
+			break
+		}
+	}
+	if s == nil {
+		if segment == "" {
+			return nil, fmt.Errorf("index has no segments")
+		}
+		return nil, fmt.Errorf("segment %s not found", segment)
+	}
+	if s.siErr != nil {
+		return nil, fmt.Errorf("segment %s: %w", s.SegName, s.siErr)
+	}
+	if s.StoredFields == nil {
+		return nil, fmt.Errorf("segment %s: unsupported stored fields format", s.SegName)
+	}
+
+	dir := FSDirectory{rep.IndexPath}
+	var live liveDocsBits
+	if s.DelGen > 0 {
+		var err error
+		if live, err = readLiveDocs(dir, s); err != nil {
+			return nil, err
+		}
+	}
+	segDir, err := openSegmentDirectory(dir, s)
+	if err != nil {
+		return nil, err
+	}
+	defer segDir.Close()
+	softDeleted, err := readSoftDeletes(dir, segDir, s)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := openStoredFieldsReader(segDir, s)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	sample := &DocSample{Segment: s.SegName, From: from, Size: size, Docs: []SampledDoc{}}
+	doc := from
+	for ; doc < int(s.MaxDoc) && len(sample.Docs) < size; doc++ {
+		if !live.get(doc) || softDeleted != nil && softDeleted.get(doc) {
+			continue
+		}
+		fields, err := reader.document(doc)
+		if err != nil {
+			return nil, err
+		}
+		sample.Docs = append(sample.Docs, esDocument(doc, fields))
+	}
+	sample.Next = max(doc, from)
+	return sample, nil
+}
+
+// esDocument pulls _id, _routing and _source out of the stored fields.
+func esDocument(doc int, fields []StoredField) SampledDoc {
+	d := SampledDoc{Doc: doc}
+	for _, f := range fields {
+		switch {
+		case f.Name == ES_ID_FIELD && f.Type == "binary":
+			d.ID, d.IDEncoding = termString(ES_ID_FIELD, f.Value.([]byte))
+		case f.Name == ES_ID_FIELD && f.Type == "string":
+			d.ID = f.Value.(string)
+		case f.Name == ES_ROUTING_FIELD && f.Type == "string":
+			d.Routing = f.Value.(string)
+		case f.Name == ES_SOURCE_FIELD && f.Type == "binary":
+			src := f.Value.([]byte)
+			if json.Valid(src) {
+				d.Source = src
+			} else {
+				d.Source, _ = json.Marshal(src)
+				d.SourceEncoding = "base64"
+			}
+		default:
+			// NaN/Inf 无法编码为 JSON
+			switch v := f.Value.(type) {
+			case float32:
+				if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+					f.Value = fmt.Sprint(v)
+				}
+			case float64:
+				if math.IsNaN(v) || math.IsInf(v, 0) {
+					f.Value = fmt.Sprint(v)
+				}
+			}
+			d.Fields = append(d.Fields, f)
+		}
+	}
+	return d
+}
+
+// decodeUid reverses Elasticsearch's Uid.encodeId: numeric ids are packed
+// two digits per byte after 0xFE, UTF-8 ids follow 0xFF, and base64 ids are
+// stored decoded, after 0xFD when their first byte would look like a marker.
+func decodeUid(b []byte) (string, error) {
+	if len(b) == 0 {
+		return "", nil
+	}
+	switch b[0] {
+	case UID_NUMERIC:
+		var sb strings.Builder
+		for i, c := range b[1:] {
+			for j, nibble := range []byte{c >> 4, c & 0x0F} {
+				if nibble == 0x0F && j == 1 && i == len(b)-2 { // 奇数位数的结尾
+					return sb.String(), nil
+				}
+				if nibble > 9 {
+					return "", fmt.Errorf("invalid numeric id % x", b)
+				}
+				sb.WriteByte('0' + nibble)
+			}
+		}
+		return sb.String(), nil
+	case UID_UTF8:
+		if !utf8.Valid(b[1:]) {
+			return "", fmt.Errorf("invalid UTF-8 id % x", b)
+		}
+		return string(b[1:]), nil
+	case UID_BASE64_ESCAPE:
+		b = b[1:]
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+// TestDecodeUid tests the numeric, UTF-8 and base64 forms of Elasticsearch ids
+func TestDecodeUid(t *testing.T) {
+	auto, _ := base64.RawURLEncoding.DecodeString("OAL7l5sBlJuKgWk6BF77")
+	tests := []struct {
+		name    string
+		input   []byte
+		want    string
+		wantErr bool
+	}{
+		{"numeric odd", []byte{UID_NUMERIC, 0x12, 0x34, 0x5F}, "12345", false},
+		{"numeric even", []byte{UID_NUMERIC, 0x42}, "42", false},
+		{"numeric invalid digit", []byte{UID_NUMERIC, 0x1A}, "", true},
+		{"numeric early end", []byte{UID_NUMERIC, 0x1F, 0x23}, "", true},
+		{"utf8", append([]byte{UID_UTF8}, "user|42"...), "user|42", false},
+		{"utf8 invalid", []byte{UID_UTF8, 0xC3, 0x28}, "", true},
+		{"base64", auto, "OAL7l5sBlJuKgWk6BF77", false},
+		// 解码后首字节为 0xFD-0xFF 的 base64 id 前面有转义字节
+		{"base64 escaped", []byte{UID_BASE64_ESCAPE, 0xFD, 0x01, 0x02}, "_QEC", false},
+		{"base64 escaped marker", []byte{UID_BASE64_ESCAPE, 0xFF, 0x10}, "_xA", false},
+		{"empty", nil, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeUid(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeUid() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("decodeUid() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestEsDocument tests that metadata fields are decoded and other fields kept
+func TestEsDocument(t *testing.T) {
+	fields := []StoredField{
+		{Name: ES_ID_FIELD, Type: "binary", Value: []byte{UID_NUMERIC, 0x7F}},
+		{Name: ES_ROUTING_FIELD, Type: "string", Value: "r1"},
+		{Name: ES_SOURCE_FIELD, Type: "binary", Value: []byte(`{"a":1}`)},
+		{Name: "price", Type: "double", Value: 9.5},
+	}
+	d := esDocument(3, fields)
+	if d.Doc != 3 || d.ID != "7" || d.Routing != "r1" || string(d.Source) != `{"a":1}` || d.SourceEncoding != "" {
+		t.Errorf("esDocument() = %+v", d)
+	}
+	if len(d.Fields) != 1 || d.Fields[0].Name != "price" {
+		t.Errorf("esDocument() fields = %+v, want [price]", d.Fields)
+	}
+
+	// SMILE 等二进制 _source 以 base64 返回
+	d = esDocument(0, []StoredField{{Name: ES_SOURCE_FIELD, Type: "binary", Value: []byte{':', ')', '\n', 0xFA}}})
+	if d.SourceEncoding != "base64" || string(d.Source) != `"OikK+g=="` {
+		t.Errorf("esDocument() source = %s (%s), want base64", d.Source, d.SourceEncoding)
+	}
+
+	// 无效的 _id 以 hex 返回，不猜测
+	d = esDocument(0, []StoredField{{Name: ES_ID_FIELD, Type: "binary", Value: []byte{UID_UTF8, 0xC3, 0x28}}})
+	if d.ID != "ffc328" || d.IDEncoding != "hex" {
+		t.Errorf("esDocument() _id = %q (%s), want hex", d.ID, d.IDEncoding)
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ---------- typed parse errors ----------
+
+// ErrUnsupportedFormat marks files written in a format version this tool cannot decode.
+var ErrUnsupportedFormat = errors.New("unsupported format")
+
+// ParseError pinpoints the file, byte offset and field at which decoding failed.
+type ParseError struct {
+	File   string
+	Offset int64
+	Field  string
+	Cause  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s: failed to read %s at offset %d: %v", e.File, e.Field, e.Offset, e.Cause)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Cause
+}
+
+func (e *ParseError) MarshalJSON() ([]byte, error) {
+	cause := ""
+	if e.Cause != nil {
+		cause = e.Cause.Error()
+	}
+	return json.Marshal(struct {
+		File   string `json:"file"`
+		Offset int64  `json:"offset"`
+		Field  string `json:"field"`
+		Cause  string `json:"cause"`
+	}{e.File, e.Offset, e.Field, cause})
+}
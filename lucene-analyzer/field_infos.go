@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// ---------- parse .fnm (FieldInfos) per Lucene94FieldInfosFormat (and Lucene60/90) ----------
+
+const (
+	FIELD_INFOS_CODEC_NAME          = "Lucene94FieldInfos"
+	FIELD_INFOS_FORMAT_START        = 0
+	FIELD_INFOS_FORMAT_PARENT_FIELD = 1
+	FIELD_INFOS_FORMAT_DV_SKIPPER   = 2
+	FIELD_INFOS_FORMAT_CURRENT      = FIELD_INFOS_FORMAT_DV_SKIPPER
+
+	// 旧版本格式
+	FIELD_INFOS_CODEC_NAME_90           = "Lucene90FieldInfos" // Lucene 9.0 - 9.3, no vector encoding
+	FIELD_INFOS_CODEC_NAME_60           = "Lucene60FieldInfos" // Lucene 7.x - 8.x, no vectors
+	FIELD_INFOS_60_FORMAT_SELECTIVE_IDX = 2                    // adds pointIndexDimensionCount
+
+	// field bits
+	FIELD_STORE_TERMVECTOR   = 0x1
+	FIELD_OMIT_NORMS         = 0x2
+	FIELD_STORE_PAYLOADS     = 0x4
+	FIELD_SOFT_DELETES_FIELD = 0x8
+	FIELD_PARENT_FIELD       = 0x10
+)
+
+var (
+	indexOptionsNames  = []string{"NONE", "DOCS", "DOCS_AND_FREQS", "DOCS_AND_FREQS_AND_POSITIONS", "DOCS_AND_FREQS_AND_POSITIONS_AND_OFFSETS"}
+	docValuesTypeNames = []string{"NONE", "NUMERIC", "BINARY", "SORTED", "SORTED_SET", "SORTED_NUMERIC"}
+	dvSkipIndexNames   = []string{"NONE", "RANGE"}
+	vectorEncNames     = []string{"BYTE", "FLOAT32"}
+	vectorSimNames     = []string{"EUCLIDEAN", "DOT_PRODUCT", "COSINE", "MAXIMUM_INNER_PRODUCT"}
+)
+
+// FieldInfo describes one field of a segment as stored in its .fnm file.
+type FieldInfo struct {
+	Name                     string            `json:"name"`
+	Number                   int32             `json:"number"`
+	IndexOptions             string            `json:"index_options"`
+	DocValuesType            string            `json:"doc_values_type"`
+	DocValuesSkipIndex       string            `json:"doc_values_skip_index,omitempty"`
+	DocValuesGen             int64             `json:"doc_values_gen"`
+	HasNorms                 bool              `json:"has_norms"`
+	HasPayloads              bool              `json:"has_payloads"`
+	HasTermVectors           bool              `json:"has_term_vectors"`
+	SoftDeletes              bool              `json:"soft_deletes,omitempty"`
+	ParentField              bool              `json:"parent_field,omitempty"`
+	PointDimensionCount      int32             `json:"point_dimension_count,omitempty"`
+	PointIndexDimensionCount int32             `json:"point_index_dimension_count,omitempty"`
+	PointNumBytes            int32             `json:"point_num_bytes,omitempty"`
+	VectorDimension          int32             `json:"vector_dimension,omitempty"`
+	VectorEncoding           string            `json:"vector_encoding,omitempty"`
+	VectorSimilarity         string            `json:"vector_similarity,omitempty"`
+	Attributes               map[string]string `json:"attributes,omitempty"`
+	Postings                 *FieldPostings    `json:"postings,omitempty"`   // terms dictionary statistics, indexed fields only
+	Points                   *FieldPoints      `json:"points,omitempty"`     // BKD tree metadata, point fields only
+	DocValues                *FieldDocValues   `json:"doc_values,omitempty"` // .dvm/.dvd metadata, Lucene90 doc values only
+	Norms                    *FieldNorms       `json:"norms,omitempty"`      // .nvm metadata, fields with norms only
+	Vectors                  *FieldVectors     `json:"vectors,omitempty"`    // KNN vectors metadata, Lucene99 vectors formats only
+}
+
+// fieldInfosFileName picks the .fnm of a segment: a generation-updated
+// _<seg>_<gen>.fnm when FieldInfosGen > 0, the original _<seg>.fnm otherwise.
+func fieldInfosFileName(segName string, fieldInfosGen int64) string {
+	if fieldInfosGen <= 0 {
+		return segName + ".fnm"
+	}
+	return segName + "_" + strconv.FormatInt(fieldInfosGen, 36) + ".fnm"
+}
+
+// enumName maps an ordinal byte onto one of names, failing on unknown values.
+func enumName(in *DataInput, field string, names []string) (string, error) {
+	b, err := in.ReadByte()
+	if err != nil {
+		return "", in.fail(field, err)
+	}
+	if int(b) >= len(names) {
+		return "", in.fail(field, fmt.Errorf("invalid value %d", b))
+	}
+	return names[b], nil
+}
+
+// parseFieldInfos 解析 .fnm 文件
+// FieldInfos: Header, FieldsCount, <FieldName, FieldNumber, FieldBits, IndexOptions, DocValuesType, DocValuesSkipIndex, DocValuesGen, Attributes, PointDimensionCount, PointNumBytes, VectorDimension, VectorEncoding, VectorSimilarity>FieldsCount, Footer
+func parseFieldInfos(dir Directory, fileName string) ([]FieldInfo, error) {
+	in, err := dir.OpenInput(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	return readFieldInfos(in)
+}
+
+func readFieldInfos(in *DataInput) ([]FieldInfo, error) {
+	hdr, err := checkIndexHeaderOf(in,
+		HeaderFormat{Codec: FIELD_INFOS_CODEC_NAME, MinVersion: FIELD_INFOS_FORMAT_START, MaxVersion: FIELD_INFOS_FORMAT_CURRENT},
+		HeaderFormat{Codec: FIELD_INFOS_CODEC_NAME_90},
+		HeaderFormat{Codec: FIELD_INFOS_CODEC_NAME_60, MaxVersion: FIELD_INFOS_60_FORMAT_SELECTIVE_IDX, BigEndian: true})
+	if err != nil {
+		return nil, err
+	}
+	codec, format := hdr.Codec, hdr.Version
+	is94 := codec == FIELD_INFOS_CODEC_NAME
+
+	size, err := in.ReadVInt()
+	if err != nil {
+		return nil, in.fail("numFields", err)
+	}
+	if size < 0 {
+		return nil, in.fail("numFields", fmt.Errorf("invalid field count %d", size))
+	}
+
+	fields := make([]FieldInfo, 0, size)
+	for i := 0; i < int(size); i++ {
+		field := func(name string) string { return fmt.Sprintf("fields[%d].%s", i, name) }
+		var fi FieldInfo
+
+		if fi.Name, err = in.ReadString(); err != nil {
+			return nil, in.fail(field("name"), err)
+		}
+		if fi.Number, err = in.ReadVInt(); err != nil {
+			return nil, in.fail(field("number"), err)
+		}
+		if fi.Number < 0 {
+			return nil, in.fail(field("number"), fmt.Errorf("invalid field number %d for field %s", fi.Number, fi.Name))
+		}
+		bits, err := in.ReadByte()
+		if err != nil {
+			return nil, in.fail(field("bits"), err)
+		}
+		// 低版本只允许低 4 位
+		allowed := byte(0x0F)
+		if is94 && format >= FIELD_INFOS_FORMAT_PARENT_FIELD {
+			allowed = 0x1F
+		}
+		if bits&^allowed != 0 {
+			return nil, in.fail(field("bits"), fmt.Errorf("unused bits are set %#x", bits))
+		}
+		fi.HasTermVectors = bits&FIELD_STORE_TERMVECTOR != 0
+		fi.HasPayloads = bits&FIELD_STORE_PAYLOADS != 0
+		fi.SoftDeletes = bits&FIELD_SOFT_DELETES_FIELD != 0
+		fi.ParentField = bits&FIELD_PARENT_FIELD != 0
+
+		if fi.IndexOptions, err = enumName(in, field("indexOptions"), indexOptionsNames); err != nil {
+			return nil, err
+		}
+		fi.HasNorms = fi.IndexOptions != "NONE" && bits&FIELD_OMIT_NORMS == 0
+		if fi.DocValuesType, err = enumName(in, field("docValuesType"), docValuesTypeNames); err != nil {
+			return nil, err
+		}
+		if is94 && format >= FIELD_INFOS_FORMAT_DV_SKIPPER {
+			if fi.DocValuesSkipIndex, err = enumName(in, field("docValuesSkipIndex"), dvSkipIndexNames); err != nil {
+				return nil, err
+			}
+		}
+		if fi.DocValuesGen, err = in.ReadLong(); err != nil {
+			return nil, in.fail(field("docValuesGen"), err)
+		}
+		if fi.Attributes, err = in.ReadMapOfStrings(); err != nil {
+			return nil, in.fail(field("attributes"), err)
+		}
+
+		if fi.PointDimensionCount, err = in.ReadVInt(); err != nil {
+			return nil, in.fail(field("pointDimensionCount"), err)
+		}
+		if fi.PointDimensionCount != 0 {
+			// Lucene60 格式 2 之前索引维度数等于数据维度数
+			fi.PointIndexDimensionCount = fi.PointDimensionCount
+			if codec != FIELD_INFOS_CODEC_NAME_60 || format >= FIELD_INFOS_60_FORMAT_SELECTIVE_IDX {
+				if fi.PointIndexDimensionCount, err = in.ReadVInt(); err != nil {
+					return nil, in.fail(field("pointIndexDimensionCount"), err)
+				}
+			}
+			if fi.PointNumBytes, err = in.ReadVInt(); err != nil {
+				return nil, in.fail(field("pointNumBytes"), err)
+			}
+		}
+
+		if codec == FIELD_INFOS_CODEC_NAME_60 {
+			fields = append(fields, fi)
+			continue
+		}
+		if fi.VectorDimension, err = in.ReadVInt(); err != nil {
+			return nil, in.fail(field("vectorDimension"), err)
+		}
+		// Lucene90 只支持 FLOAT32 向量，不写入编码
+		enc := "FLOAT32"
+		if is94 {
+			if enc, err = enumName(in, field("vectorEncoding"), vectorEncNames); err != nil {
+				return nil, err
+			}
+		}
+		sim, err := enumName(in, field("vectorSimilarity"), vectorSimNames)
+		if err != nil {
+			return nil, err
+		}
+		// 没有向量的字段也会写入默认的编码和相似度，这里不输出
+		if fi.VectorDimension > 0 {
+			fi.VectorEncoding, fi.VectorSimilarity = enc, sim
+		}
+		fields = append(fields, fi)
+	}
+	return fields, nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fnmField writes one field entry of a Lucene94FieldInfos file (format 2)
+func fnmField(buf *bytes.Buffer, name string, number int, bits, indexOptions, dvType byte, pointDims, vectorDim int) {
+	writeString(buf, name)
+	writeVIntBytes(buf, number)
+	buf.Write([]byte{bits, indexOptions, dvType, 0})
+	binary.Write(buf, binary.LittleEndian, int64(-1))
+	writeVIntBytes(buf, 1)
+	writeString(buf, "PerFieldPostingsFormat.format")
+	writeString(buf, "Lucene103")
+	writeVIntBytes(buf, pointDims)
+	if pointDims != 0 {
+		writeVIntBytes(buf, pointDims)
+		writeVIntBytes(buf, 8)
+	}
+	writeVIntBytes(buf, vectorDim)
+	buf.Write([]byte{1, 2}) // FLOAT32, COSINE
+}
+
+// TestParseFieldInfos tests .fnm parsing for postings, points and vector fields
+func TestParseFieldInfos(t *testing.T) {
+	var buf bytes.Buffer
+	writeIndexHeader(&buf, FIELD_INFOS_CODEC_NAME, FIELD_INFOS_FORMAT_CURRENT, "")
+	writeVIntBytes(&buf, 3)
+	fnmField(&buf, "title", 0, FIELD_STORE_TERMVECTOR|FIELD_STORE_PAYLOADS, 4, 0, 0, 0)
+	fnmField(&buf, "timestamp", 1, FIELD_OMIT_NORMS, 0, 5, 1, 0)
+	fnmField(&buf, "embedding", 2, FIELD_OMIT_NORMS, 0, 0, 0, 384)
+
+	tempDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tempDir, "_0.fnm"), withFooter(buf.Bytes()), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	fields, err := parseFieldInfos(FSDirectory{tempDir}, "_0.fnm")
+	if err != nil {
+		t.Fatalf("parseFieldInfos() error = %v", err)
+	}
+	if len(fields) != 3 {
+		t.Fatalf("parseFieldInfos() returned %d fields, want 3", len(fields))
+	}
+
+	title := fields[0]
+	if title.IndexOptions != "DOCS_AND_FREQS_AND_POSITIONS_AND_OFFSETS" || !title.HasNorms || !title.HasTermVectors || !title.HasPayloads {
+		t.Errorf("parseFieldInfos() title = %+v", title)
+	}
+	if title.Attributes["PerFieldPostingsFormat.format"] != "Lucene103" || title.VectorEncoding != "" {
+		t.Errorf("parseFieldInfos() title attributes/vector = %+v", title)
+	}
+	ts := fields[1]
+	if ts.DocValuesType != "SORTED_NUMERIC" || ts.HasNorms || ts.PointDimensionCount != 1 || ts.PointNumBytes != 8 || ts.DocValuesGen != -1 {
+		t.Errorf("parseFieldInfos() timestamp = %+v", ts)
+	}
+	vec := fields[2]
+	if vec.VectorDimension != 384 || vec.VectorEncoding != "FLOAT32" || vec.VectorSimilarity != "COSINE" {
+		t.Errorf("parseFieldInfos() embedding = %+v", vec)
+	}
+}
+
+// TestParseFieldInfosErrors tests that invalid enum values are reported with their field
+func TestParseFieldInfosErrors(t *testing.T) {
+	var buf bytes.Buffer
+	writeIndexHeader(&buf, FIELD_INFOS_CODEC_NAME, FIELD_INFOS_FORMAT_CURRENT, "")
+	writeVIntBytes(&buf, 1)
+	fnmField(&buf, "f", 0, 0, 9, 0, 0, 0) // index options 9 does not exist
+
+	tempDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tempDir, "_0.fnm"), buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	_, err := parseFieldInfos(FSDirectory{tempDir}, "_0.fnm")
+	var pe *ParseError
+	if !errors.As(err, &pe) {
+		t.Fatalf("parseFieldInfos() error = %v, want *ParseError", err)
+	}
+	if pe.Field != "fields[0].indexOptions" {
+		t.Errorf("parseFieldInfos() error field = %s, want fields[0].indexOptions", pe.Field)
+	}
+}
+
+// TestParseFieldInfosLucene60 tests a big-endian 7.x .fnm without index dimensions or vectors
+func TestParseFieldInfosLucene60(t *testing.T) {
+	var buf bytes.Buffer
+	writeIndexHeader(&buf, FIELD_INFOS_CODEC_NAME_60, 1, "")
+	writeVIntBytes(&buf, 2)
+	for i, name := range []string{"body", "location"} {
+		writeString(&buf, name)
+		writeVIntBytes(&buf, i)
+		if i == 0 {
+			buf.Write([]byte{0, 3, 0}) // DOCS_AND_FREQS_AND_POSITIONS
+		} else {
+			buf.Write([]byte{FIELD_OMIT_NORMS, 0, 0})
+		}
+		binary.Write(&buf, binary.BigEndian, int64(-1))
+		writeVIntBytes(&buf, 0) // attributes
+		writeVIntBytes(&buf, 2*i)
+		if i == 1 {
+			writeVIntBytes(&buf, 4) // pointNumBytes only, format 1 has no index dimension count
+		}
+	}
+
+	tempDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tempDir, "_0.fnm"), withFooter(buf.Bytes()), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	fields, err := parseFieldInfos(FSDirectory{tempDir}, "_0.fnm")
+	if err != nil {
+		t.Fatalf("parseFieldInfos() error = %v", err)
+	}
+	if len(fields) != 2 {
+		t.Fatalf("parseFieldInfos() returned %d fields, want 2", len(fields))
+	}
+	if f := fields[0]; f.IndexOptions != "DOCS_AND_FREQS_AND_POSITIONS" || !f.HasNorms || f.DocValuesGen != -1 {
+		t.Errorf("parseFieldInfos() body = %+v", f)
+	}
+	if f := fields[1]; f.PointDimensionCount != 2 || f.PointIndexDimensionCount != 2 || f.PointNumBytes != 4 || f.HasNorms {
+		t.Errorf("parseFieldInfos() location = %+v", f)
+	}
+}
+
+// TestFieldInfosFileName tests generation-aware .fnm naming
+func TestFieldInfosFileName(t *testing.T) {
+	tests := []struct {
+		seg  string
+		gen  int64
+		want string
+	}{
+		{"_0", -1, "_0.fnm"},
+		{"_8rd", 2, "_8rd_2.fnm"},
+		{"_5", 36, "_5_10.fnm"},
+	}
+	for _, tt := range tests {
+		if got := fieldInfosFileName(tt.seg, tt.gen); got != tt.want {
+			t.Errorf("fieldInfosFileName(%s, %d) = %v, want %v", tt.seg, tt.gen, got, tt.want)
+		}
+	}
+}
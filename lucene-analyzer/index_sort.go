@@ -0,0 +1,315 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// ---------- index sort (SortFieldProvider) as written into .si ----------
+
+const (
+	SORT_PROVIDER_SORT_FIELD     = "SortField"
+	SORT_PROVIDER_SORTED_NUMERIC = "SortedNumericSortField"
+	SORT_PROVIDER_SORTED_SET     = "SortedSetSortField"
+
+	SORT_MISSING_FIRST = "STRING_FIRST"
+	SORT_MISSING_LAST  = "STRING_LAST"
+)
+
+var (
+	sortedNumericSelectors = []string{"MIN", "MAX"}
+	sortedSetSelectors     = []string{"MIN", "MAX", "MIDDLE_MIN", "MIDDLE_MAX"}
+)
+
+// IndexSortField is one SortField of a segment's index sort.
+type IndexSortField struct {
+	Field    string `json:"field"`
+	Provider string `json:"provider"`
+	Type     string `json:"type"` // SortField.Type: STRING, INT, LONG, FLOAT, DOUBLE, ...
+	Reverse  bool   `json:"reverse"`
+	Selector string `json:"selector,omitempty"` // multi-valued fields only
+	Missing  any    `json:"missing,omitempty"`  // number, or STRING_FIRST / STRING_LAST
+}
+
+// IndexSort: NumSortFields, <ProviderName, SortField>NumSortFields
+func readIndexSort(in *DataInput) ([]IndexSortField, error) {
+	n, err := in.ReadVInt()
+	if err != nil {
+		return nil, in.fail("numSortFields", err)
+	}
+	if n < 0 {
+		return nil, in.fail("numSortFields", fmt.Errorf("invalid index sort field count %d", n))
+	}
+	var fields []IndexSortField
+	for i := 0; i < int(n); i++ {
+		field := func(name string) string { return fmt.Sprintf("indexSort[%d].%s", i, name) }
+		provider, err := in.ReadString()
+		if err != nil {
+			return nil, in.fail(field("provider"), err)
+		}
+		sf := IndexSortField{Provider: provider}
+		switch provider {
+		case SORT_PROVIDER_SORT_FIELD:
+			err = readSortField(in, &sf, field)
+		case SORT_PROVIDER_SORTED_NUMERIC:
+			err = readSortedNumericSortField(in, &sf, field)
+		case SORT_PROVIDER_SORTED_SET:
+			err = readSortedSetSortField(in, &sf, field)
+		default:
+			err = in.fail(field("provider"), fmt.Errorf("unknown SortFieldProvider %q", provider))
+		}
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, sf)
+	}
+	return fields, nil
+}
+
+// readSortField: Field, Type, Reverse(int), HasMissing(int), [Missing]
+func readSortField(in *DataInput, sf *IndexSortField, field func(string) string) error {
+	if err := readSortFieldHead(in, sf, field, true); err != nil {
+		return err
+	}
+	hasMissing, err := in.ReadInt()
+	if err != nil {
+		return in.fail(field("hasMissing"), err)
+	}
+	if hasMissing != 1 {
+		return nil
+	}
+	if sf.Type == "STRING" {
+		v, err := in.ReadInt()
+		if err != nil {
+			return in.fail(field("missing"), err)
+		}
+		sf.Missing = SORT_MISSING_LAST
+		if v == 1 {
+			sf.Missing = SORT_MISSING_FIRST
+		}
+		return nil
+	}
+	return readNumericMissing(in, sf, field)
+}
+
+// readSortedNumericSortField: Field, Type, Reverse(int), Selector(int), HasMissing(int), [Missing]
+func readSortedNumericSortField(in *DataInput, sf *IndexSortField, field func(string) string) error {
+	if err := readSortFieldHead(in, sf, field, true); err != nil {
+		return err
+	}
+	if err := readSelector(in, sf, field, sortedNumericSelectors); err != nil {
+		return err
+	}
+	hasMissing, err := in.ReadInt()
+	if err != nil {
+		return in.fail(field("hasMissing"), err)
+	}
+	if hasMissing != 1 {
+		return nil
+	}
+	return readNumericMissing(in, sf, field)
+}
+
+// readSortedSetSortField: Field, Reverse(int), Selector(int), Missing(int: 1 first, 2 last)
+func readSortedSetSortField(in *DataInput, sf *IndexSortField, field func(string) string) error {
+	sf.Type = "STRING"
+	if err := readSortFieldHead(in, sf, field, false); err != nil {
+		return err
+	}
+	if err := readSelector(in, sf, field, sortedSetSelectors); err != nil {
+		return err
+	}
+	missing, err := in.ReadInt()
+	if err != nil {
+		return in.fail(field("missing"), err)
+	}
+	switch missing {
+	case 1:
+		sf.Missing = SORT_MISSING_FIRST
+	case 2:
+		sf.Missing = SORT_MISSING_LAST
+	}
+	return nil
+}
+
+func readSortFieldHead(in *DataInput, sf *IndexSortField, field func(string) string, withType bool) error {
+	var err error
+	if sf.Field, err = in.ReadString(); err != nil {
+		return in.fail(field("field"), err)
+	}
+	if withType {
+		if sf.Type, err = in.ReadString(); err != nil {
+			return in.fail(field("type"), err)
+		}
+	}
+	reverse, err := in.ReadInt()
+	if err != nil {
+		return in.fail(field("reverse"), err)
+	}
+	sf.Reverse = reverse == 1
+	return nil
+}
+
+func readSelector(in *DataInput, sf *IndexSortField, field func(string) string, names []string) error {
+	sel, err := in.ReadInt()
+	if err != nil {
+		return in.fail(field("selector"), err)
+	}
+	if sel < 0 || int(sel) >= len(names) {
+		return in.fail(field("selector"), fmt.Errorf("invalid selector %d", sel))
+	}
+	sf.Selector = names[sel]
+	return nil
+}
+
+// readNumericMissing 读取数值类型的缺失值，FLOAT/DOUBLE 以 NumericUtils 的可排序编码存储
+func readNumericMissing(in *DataInput, sf *IndexSortField, field func(string) string) error {
+	switch sf.Type {
+	case "INT":
+		v, err := in.ReadInt()
+		if err != nil {
+			return in.fail(field("missing"), err)
+		}
+		sf.Missing = v
+	case "LONG":
+		v, err := in.ReadLong()
+		if err != nil {
+			return in.fail(field("missing"), err)
+		}
+		sf.Missing = v
+	case "FLOAT":
+		v, err := in.ReadInt()
+		if err != nil {
+			return in.fail(field("missing"), err)
+		}
+		sf.Missing = sortableIntToFloat(v)
+	case "DOUBLE":
+		v, err := in.ReadLong()
+		if err != nil {
+			return in.fail(field("missing"), err)
+		}
+		sf.Missing = sortableLongToDouble(v)
+	default:
+		return in.fail(field("missing"), fmt.Errorf("cannot deserialize missing value of sort type %s", sf.Type))
+	}
+	return nil
+}
+
+// sortableIntToFloat reverses NumericUtils.floatToSortableInt.
+func sortableIntToFloat(v int32) float32 {
+	return math.Float32frombits(uint32(v ^ (v>>31)&0x7fffffff))
+}
+
+// sortableLongToDouble reverses NumericUtils.doubleToSortableLong.
+func sortableLongToDouble(v int64) float64 {
+	return math.Float64frombits(uint64(v ^ (v>>63)&0x7fffffffffffffff))
+}
+
+var legacySortTypes = []string{"STRING", "LONG", "INT", "DOUBLE", "FLOAT"}
+
+// readLegacyIndexSort reads the fixed sort type IDs of Lucene70SegmentInfoFormat:
+// NumSortFields, <FieldName, SortTypeID, [Selector], Reverse(byte), Missing>NumSortFields
+func readLegacyIndexSort(in *DataInput) ([]IndexSortField, error) {
+	n, err := in.ReadVInt()
+	if err != nil {
+		return nil, in.fail("numSortFields", err)
+	}
+	if n < 0 {
+		return nil, in.fail("numSortFields", fmt.Errorf("invalid index sort field count %d", n))
+	}
+	var fields []IndexSortField
+	for i := 0; i < int(n); i++ {
+		field := func(name string) string { return fmt.Sprintf("indexSort[%d].%s", i, name) }
+		var sf IndexSortField
+		if sf.Field, err = in.ReadString(); err != nil {
+			return nil, in.fail(field("field"), err)
+		}
+		typeID, err := in.ReadVInt()
+		if err != nil {
+			return nil, in.fail(field("type"), err)
+		}
+		switch {
+		case typeID >= 0 && int(typeID) < len(legacySortTypes):
+			sf.Provider, sf.Type = SORT_PROVIDER_SORT_FIELD, legacySortTypes[typeID]
+		case typeID == 5:
+			sf.Provider, sf.Type = SORT_PROVIDER_SORTED_SET, "STRING"
+			if sf.Selector, err = enumName(in, field("selector"), sortedSetSelectors); err != nil {
+				return nil, err
+			}
+		case typeID == 6:
+			sf.Provider = SORT_PROVIDER_SORTED_NUMERIC
+			// 数值类型编号与 SortField 不同：0 LONG, 1 INT, 2 DOUBLE, 3 FLOAT
+			if sf.Type, err = enumName(in, field("numericType"), legacySortTypes[1:]); err != nil {
+				return nil, err
+			}
+			if sf.Selector, err = enumName(in, field("selector"), sortedNumericSelectors); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, in.fail(field("type"), fmt.Errorf("invalid index sort field type ID %d", typeID))
+		}
+
+		// 注意：旧格式中 0 表示倒序
+		reverse, err := in.ReadByte()
+		if err != nil {
+			return nil, in.fail(field("reverse"), err)
+		}
+		if reverse > 1 {
+			return nil, in.fail(field("reverse"), fmt.Errorf("invalid reverse flag %d", reverse))
+		}
+		sf.Reverse = reverse == 0
+
+		hasMissing, err := in.ReadByte()
+		if err != nil {
+			return nil, in.fail(field("missing"), err)
+		}
+		if hasMissing != 0 {
+			if err := readLegacyMissing(in, &sf, hasMissing, field); err != nil {
+				return nil, err
+			}
+		}
+		fields = append(fields, sf)
+	}
+	return fields, nil
+}
+
+// readLegacyMissing: STRING uses the flag itself (1 last, 2 first), numbers follow as raw bits
+func readLegacyMissing(in *DataInput, sf *IndexSortField, flag byte, field func(string) string) error {
+	if sf.Type == "STRING" {
+		switch flag {
+		case 1:
+			sf.Missing = SORT_MISSING_LAST
+		case 2:
+			sf.Missing = SORT_MISSING_FIRST
+		default:
+			return in.fail(field("missing"), fmt.Errorf("invalid missing value flag %d", flag))
+		}
+		return nil
+	}
+	if flag != 1 {
+		return in.fail(field("missing"), fmt.Errorf("invalid missing value flag %d", flag))
+	}
+	var err error
+	switch sf.Type {
+	case "LONG":
+		var v int64
+		v, err = in.ReadLong()
+		sf.Missing = v
+	case "INT":
+		var v int32
+		v, err = in.ReadInt()
+		sf.Missing = v
+	case "DOUBLE":
+		var v int64
+		v, err = in.ReadLong()
+		sf.Missing = math.Float64frombits(uint64(v))
+	case "FLOAT":
+		var v int32
+		v, err = in.ReadInt()
+		sf.Missing = math.Float32frombits(uint32(v))
+	}
+	if err != nil {
+		return in.fail(field("missing"), err)
+	}
+	return nil
+}
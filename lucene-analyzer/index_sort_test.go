@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+// TestReadIndexSort tests every SortFieldProvider and their missing values
+func TestReadIndexSort(t *testing.T) {
+	le := func(buf *bytes.Buffer, v any) { binary.Write(buf, binary.LittleEndian, v) }
+	var buf bytes.Buffer
+	writeVIntBytes(&buf, 4)
+
+	writeString(&buf, SORT_PROVIDER_SORT_FIELD)
+	writeString(&buf, "name")
+	writeString(&buf, "STRING")
+	le(&buf, int32(0)) // reverse
+	le(&buf, int32(1)) // has missing
+	le(&buf, int32(1)) // STRING_FIRST
+
+	writeString(&buf, SORT_PROVIDER_SORT_FIELD)
+	writeString(&buf, "score")
+	writeString(&buf, "DOUBLE")
+	le(&buf, int32(1))
+	le(&buf, int32(1))
+	bits := int64(math.Float64bits(-1.5))
+	le(&buf, bits^(bits>>63)&0x7fffffffffffffff) // NumericUtils.doubleToSortableLong
+
+	writeString(&buf, SORT_PROVIDER_SORTED_SET)
+	writeString(&buf, "tags")
+	le(&buf, int32(0))
+	le(&buf, int32(3)) // MIDDLE_MAX
+	le(&buf, int32(2)) // STRING_LAST
+
+	writeString(&buf, SORT_PROVIDER_SORTED_NUMERIC)
+	writeString(&buf, "price")
+	writeString(&buf, "FLOAT")
+	le(&buf, int32(0))
+	le(&buf, int32(0)) // MIN
+	le(&buf, int32(0)) // no missing
+
+	got, err := readIndexSort(newTestInput(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("readIndexSort() error = %v", err)
+	}
+	want := []IndexSortField{
+		{Field: "name", Provider: SORT_PROVIDER_SORT_FIELD, Type: "STRING", Missing: SORT_MISSING_FIRST},
+		{Field: "score", Provider: SORT_PROVIDER_SORT_FIELD, Type: "DOUBLE", Reverse: true, Missing: -1.5},
+		{Field: "tags", Provider: SORT_PROVIDER_SORTED_SET, Type: "STRING", Selector: "MIDDLE_MAX", Missing: SORT_MISSING_LAST},
+		{Field: "price", Provider: SORT_PROVIDER_SORTED_NUMERIC, Type: "FLOAT", Selector: "MIN"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("readIndexSort() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("readIndexSort()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+// TestReadIndexSortUnknownProvider tests that an unknown provider stops parsing with a ParseError
+func TestReadIndexSortUnknownProvider(t *testing.T) {
+	var buf bytes.Buffer
+	writeVIntBytes(&buf, 1)
+	writeString(&buf, "CustomSortField")
+	_, err := readIndexSort(newTestInput(buf.Bytes()))
+	pe, ok := err.(*ParseError)
+	if !ok || pe.Field != "indexSort[0].provider" || pe.Offset != 1 {
+		t.Errorf("readIndexSort() error = %v, want ParseError at indexSort[0].provider offset 1", err)
+	}
+}
+
+// TestSortableFloatConversions tests the NumericUtils sortable encodings
+func TestSortableFloatConversions(t *testing.T) {
+	for _, f := range []float32{0, 1.25, -3.5} {
+		b := int32(math.Float32bits(f))
+		if got := sortableIntToFloat(b ^ (b>>31)&0x7fffffff); got != f {
+			t.Errorf("sortableIntToFloat() = %v, want %v", got, f)
+		}
+	}
+}
+
+// TestReadLegacyIndexSort tests the Lucene70 sort type IDs, which are big-endian and store reverse inverted
+func TestReadLegacyIndexSort(t *testing.T) {
+	var buf bytes.Buffer
+	writeVIntBytes(&buf, 3)
+	writeString(&buf, "price")
+	writeVIntBytes(&buf, 3) // DOUBLE
+	buf.Write([]byte{1, 1}) // ascending, has missing
+	binary.Write(&buf, binary.BigEndian, math.Float64bits(2.5))
+	writeString(&buf, "tags")
+	writeVIntBytes(&buf, 5)    // SortedSetSortField
+	buf.Write([]byte{1, 0, 2}) // selector MAX, descending, missing first
+	writeString(&buf, "@timestamp")
+	writeVIntBytes(&buf, 6)    // SortedNumericSortField
+	buf.Write([]byte{0, 1, 1}) // LONG, selector MAX, ascending
+	buf.Write([]byte{0})       // no missing value
+
+	in := newTestInput(buf.Bytes())
+	in.SetByteOrder(binary.BigEndian)
+	got, err := readLegacyIndexSort(in)
+	if err != nil {
+		t.Fatalf("readLegacyIndexSort() error = %v", err)
+	}
+	want := []IndexSortField{
+		{Field: "price", Provider: SORT_PROVIDER_SORT_FIELD, Type: "DOUBLE", Missing: 2.5},
+		{Field: "tags", Provider: SORT_PROVIDER_SORTED_SET, Type: "STRING", Reverse: true, Selector: "MAX", Missing: SORT_MISSING_FIRST},
+		{Field: "@timestamp", Provider: SORT_PROVIDER_SORTED_NUMERIC, Type: "LONG", Selector: "MAX"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("readLegacyIndexSort() returned %d fields, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("readLegacyIndexSort()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
@@ -3,10 +3,17 @@ package main
 import (
 	"archive/zip"
 	"bytes"
+	"encoding/json"
 	"io"
 	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -86,6 +93,16 @@ func TestAnalyzeWithRealData(t *testing.T) {
 		t.Errorf("report.TotalDocs = %v, should be >= 0", report.TotalDocs)
 	}
 
+	// The sample archives are intact, so every footer checksum should verify
+	if report.Integrity != INTEGRITY_OK {
+		t.Errorf("report.Integrity = %v, want %v (corrupt: %v)", report.Integrity, INTEGRITY_OK, report.CorruptFiles)
+	}
+	for _, s := range report.Segments {
+		if len(s.Checksums) == 0 {
+			t.Errorf("segment %s has no checksums", s.SegName)
+		}
+	}
+
 	// Print the report for debugging (optional)
 	// t.Logf("Report: %+v", report)
 }
@@ -178,8 +195,697 @@ func TestMultipleTestDataFiles(t *testing.T) {
 			if report.SegmentsFile == "" {
 				t.Error("report.SegmentsFile should not be empty")
 			}
+			if report.Integrity != INTEGRITY_OK {
+				t.Errorf("report.Integrity = %v, want %v (corrupt: %v)", report.Integrity, INTEGRITY_OK, report.CorruptFiles)
+			}
 		})
 
 		processedFiles++
 	}
 }
+
+// extractTestIndex unpacks one of the sample archives and returns its Lucene index directory
+func extractTestIndex(t *testing.T, archive string) string {
+	t.Helper()
+	testData, err := os.ReadFile(filepath.Join("../test/test-data", archive))
+	if err != nil {
+		t.Fatalf("Failed to read test data file: %v", err)
+	}
+	reader, err := zip.NewReader(bytes.NewReader(testData), int64(len(testData)))
+	if err != nil {
+		t.Fatalf("Failed to process zip file: %v", err)
+	}
+	tempDir := t.TempDir()
+	for _, f := range reader.File {
+		path := filepath.Join(tempDir, f.Name)
+		if f.FileInfo().IsDir() {
+			os.MkdirAll(path, 0755)
+			continue
+		}
+		os.MkdirAll(filepath.Dir(path), 0755)
+		src, err := f.Open()
+		if err != nil {
+			t.Fatalf("Failed to open zip file entry: %v", err)
+		}
+		content, err := io.ReadAll(src)
+		src.Close()
+		if err != nil {
+			t.Fatalf("Failed to read zip file entry: %v", err)
+		}
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+	indexDir, err := findLuceneIndexDir(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to find Lucene index directory: %v", err)
+	}
+	return indexDir
+}
+
+// TestFieldInfosWithRealData tests that a FieldInfosGen update selects the _<seg>_<gen>.fnm file
+func TestFieldInfosWithRealData(t *testing.T) {
+	report, err := buildReport(extractTestIndex(t, "s_NL8E3ySUW7ittn8yvdDQ.zip"))
+	if err != nil {
+		t.Fatalf("buildReport() error = %v", err)
+	}
+	var seg *SegInfoSummary
+	for i := range report.Segments {
+		if report.Segments[i].SegName == "_8rd" {
+			seg = &report.Segments[i]
+		}
+	}
+	if seg == nil {
+		t.Fatalf("segment _8rd not found in report")
+	}
+	if seg.FieldInfosGen != 2 || seg.FieldInfosFile != "_8rd_2.fnm" {
+		t.Errorf("segment _8rd field_infos_gen = %d, file = %s, want 2, _8rd_2.fnm", seg.FieldInfosGen, seg.FieldInfosFile)
+	}
+	byName := make(map[string]FieldInfo)
+	for _, f := range seg.Fields {
+		byName[f.Name] = f
+	}
+	if id, ok := byName["_id"]; !ok || id.IndexOptions != "DOCS" {
+		t.Errorf("segment _8rd _id field = %+v, want indexed with DOCS", id)
+	}
+	if sd, ok := byName["__soft_deletes"]; !ok || !sd.SoftDeletes || sd.DocValuesType != "NUMERIC" {
+		t.Errorf("segment _8rd __soft_deletes field = %+v, want soft-deletes NUMERIC doc values", sd)
+	}
+}
+
+// TestCompoundSegmentsWithRealData tests field infos of compound segments, including gen-updated .fnm files
+func TestCompoundSegmentsWithRealData(t *testing.T) {
+	report, err := buildReport(extractTestIndex(t, "uh9g61-vSqyXfxqX3OPvGg.zip"))
+	if err != nil {
+		t.Fatalf("buildReport() error = %v", err)
+	}
+	for _, s := range report.Segments {
+		if !s.Compound {
+			continue
+		}
+		if len(s.CompoundFiles) == 0 {
+			t.Errorf("segment %s is compound but lists no compound_files", s.SegName)
+		}
+		for _, e := range s.CompoundFiles {
+			if e.Length <= 0 {
+				t.Errorf("segment %s compound entry %+v has no length", s.SegName, e)
+			}
+		}
+		if len(s.Fields) == 0 {
+			t.Errorf("segment %s (%s) has no fields", s.SegName, s.FieldInfosFile)
+		}
+		if s.FieldInfosGen > 0 && s.FieldInfosFile != fieldInfosFileName(s.SegName, s.FieldInfosGen) {
+			t.Errorf("segment %s field_infos_file = %s", s.SegName, s.FieldInfosFile)
+		}
+	}
+}
+
+// TestSizeBreakdownWithRealData tests that segment and extension sizes add up to the shard total
+func TestSizeBreakdownWithRealData(t *testing.T) {
+	report, err := buildReport(extractTestIndex(t, "s_NL8E3ySUW7ittn8yvdDQ.zip"))
+	if err != nil {
+		t.Fatalf("buildReport() error = %v", err)
+	}
+	var segTotal, extTotal int64
+	var percent float64
+	for _, s := range report.Segments {
+		if s.SizeBytes <= 0 {
+			t.Errorf("segment %s size_bytes = %d", s.SegName, s.SizeBytes)
+		}
+		segTotal += s.SizeBytes
+		percent += s.SizePercent
+	}
+	for _, e := range report.SizeByExtension {
+		extTotal += e.SizeBytes
+	}
+	if extTotal != report.TotalSizeBytes {
+		t.Errorf("size_by_extension sums to %d, want total_size_bytes %d", extTotal, report.TotalSizeBytes)
+	}
+	if segTotal >= report.TotalSizeBytes {
+		t.Errorf("segment sizes %d should exclude segments_N from total %d", segTotal, report.TotalSizeBytes)
+	}
+	if percent < 99 || percent > 100.01 {
+		t.Errorf("segment size_percent sums to %.2f", percent)
+	}
+}
+
+// TestSegmentInfoWithRealData tests versions and attributes read from .si files
+func TestSegmentInfoWithRealData(t *testing.T) {
+	report, err := buildReport(extractTestIndex(t, "xGXIZba7Qha6U73SBkrCIw.zip"))
+	if err != nil {
+		t.Fatalf("buildReport() error = %v", err)
+	}
+	if report.CommitLuceneVersion != "10.3.2" || report.IndexCreatedMajor != 10 || report.MinSegmentVersion != "10.3.2" {
+		t.Errorf("commit versions = %s / %d / %s, want 10.3.2 / 10 / 10.3.2", report.CommitLuceneVersion, report.IndexCreatedMajor, report.MinSegmentVersion)
+	}
+	if gen, _ := generationFromSegmentsFileName(report.SegmentsFile); report.Generation != gen || len(report.CommitID) != 2*ID_LENGTH {
+		t.Errorf("generation = %d, commit_id = %q, want %d and a hex ID", report.Generation, report.CommitID, gen)
+	}
+	for _, s := range report.Segments {
+		// 新段以 counter 命名，已有段的编号必然小于 counter
+		if n, err := strconv.ParseInt(s.SegName[1:], 36, 64); err != nil || n >= report.NameCounter {
+			t.Errorf("segment %s is not below name_counter %d", s.SegName, report.NameCounter)
+		}
+		if s.LuceneVersion != "10.3.2" {
+			t.Errorf("segment %s lucene_version = %q, want 10.3.2", s.SegName, s.LuceneVersion)
+		}
+		if s.Attributes["Lucene90StoredFieldsFormat.mode"] == "" {
+			t.Errorf("segment %s attributes = %v, want Lucene90StoredFieldsFormat.mode", s.SegName, s.Attributes)
+		}
+		if !slices.Contains(s.Files, s.SegName+".si") {
+			t.Errorf("segment %s files = %v, want to include its .si", s.SegName, s.Files)
+		}
+	}
+}
+
+// TestCommitPointsWithRealData tests that a sample shard has a single, intact latest commit
+func TestCommitPointsWithRealData(t *testing.T) {
+	indexDir := extractTestIndex(t, "s_NL8E3ySUW7ittn8yvdDQ.zip")
+	report, err := buildReport(indexDir)
+	if err != nil {
+		t.Fatalf("buildReport() error = %v", err)
+	}
+	latest, err := parseSegmentsFile(indexDir, report.SegmentsFile)
+	if err != nil {
+		t.Fatalf("parseSegmentsFile() error = %v", err)
+	}
+	commits, err := analyzeCommitPoints(indexDir, report.SegmentsFile, latest, nil)
+	if err != nil {
+		t.Fatalf("analyzeCommitPoints() error = %v", err)
+	}
+	if len(commits) != 1 {
+		t.Fatalf("analyzeCommitPoints() returned %d commits, want 1", len(commits))
+	}
+	c := commits[0]
+	if !c.Latest || c.Orphaned || c.Error != "" || c.SegmentsFile != report.SegmentsFile {
+		t.Errorf("commit = %+v, want the intact latest %s", c, report.SegmentsFile)
+	}
+	if c.TotalDocs != report.TotalDocs || len(c.Segments) != report.TotalSegments {
+		t.Errorf("commit docs/segments = %d/%d, want %d/%d", c.TotalDocs, len(c.Segments), report.TotalDocs, report.TotalSegments)
+	}
+
+	if len(report.UnreferencedFiles) != 0 || len(report.MissingFiles) != 0 {
+		t.Errorf("unreferenced = %v, missing = %v, want none for an intact shard", report.UnreferencedFiles, report.MissingFiles)
+	}
+
+	// 失败合并留下的文件不属于任何提交
+	if err := os.WriteFile(filepath.Join(indexDir, "_zz.fdt"), make([]byte, 64), 0644); err != nil {
+		t.Fatalf("Failed to create stray file: %v", err)
+	}
+	report, err = buildReport(indexDir)
+	if err != nil {
+		t.Fatalf("buildReport() error = %v", err)
+	}
+	if len(report.UnreferencedFiles) != 1 || report.UnreferencedFiles[0].File != "_zz.fdt" || report.UnreferencedSizeBytes != 64 {
+		t.Errorf("unreferenced = %+v (%d bytes), want _zz.fdt of 64 bytes", report.UnreferencedFiles, report.UnreferencedSizeBytes)
+	}
+}
+
+// TestDocValuesUpdatesWithRealData tests that the soft-delete doc values update of _8rd is resolved per field
+func TestDocValuesUpdatesWithRealData(t *testing.T) {
+	report, err := buildReport(extractTestIndex(t, "s_NL8E3ySUW7ittn8yvdDQ.zip"))
+	if err != nil {
+		t.Fatalf("buildReport() error = %v", err)
+	}
+	for _, s := range report.Segments {
+		if s.SegName != "_8rd" {
+			continue
+		}
+		if len(s.UpdatesFiles) != 1 || s.UpdatesFiles[0] != "_8rd_2.fnm" {
+			t.Errorf("updates_files = %v, want [_8rd_2.fnm]", s.UpdatesFiles)
+		}
+		if len(s.DVUpdates) != 1 {
+			t.Fatalf("dv_updates = %+v, want one field", s.DVUpdates)
+		}
+		u := s.DVUpdates[0]
+		if u.FieldName != "__soft_deletes" || u.Gen != 2 || len(u.Files) != 2 || !slices.Contains(u.Files, "_8rd_2_Lucene90_0.dvd") {
+			t.Errorf("dv_updates[0] = %+v, want __soft_deletes gen 2 with its .dvd/.dvm", u)
+		}
+		return
+	}
+	t.Fatal("segment _8rd not found")
+}
+
+// TestStoredFieldsWithRealData tests that the chunk index covers every document and all of .fdt
+func TestStoredFieldsWithRealData(t *testing.T) {
+	indexDir := extractTestIndex(t, "s_NL8E3ySUW7ittn8yvdDQ.zip")
+	report, err := buildReport(indexDir)
+	if err != nil {
+		t.Fatalf("buildReport() error = %v", err)
+	}
+	for _, s := range report.Segments {
+		sf := s.StoredFields
+		if sf == nil {
+			t.Fatalf("segment %s has no stored fields info", s.SegName)
+		}
+		if sf.Mode != "BEST_SPEED" || sf.DataCodec != STORED_FIELDS_FAST_CODEC || sf.NumChunks <= 0 {
+			t.Errorf("segment %s stored fields = %+v", s.SegName, sf)
+		}
+		if docs := sf.DocsPerChunk.Avg * float64(sf.NumChunks); math.Abs(docs-float64(s.MaxDoc)) > 1 {
+			t.Errorf("segment %s chunks hold %.0f docs, want %d", s.SegName, docs, s.MaxDoc)
+		}
+		if sf.DocsPerChunk.Max > int64(sf.MaxDocsPerChunk) || sf.DirtyChunks > sf.NumChunks {
+			t.Errorf("segment %s stored fields = %+v", s.SegName, sf)
+		}
+		if s.Compound {
+			continue
+		}
+		// .fdt = header + chunks + footer
+		fi, err := os.Stat(filepath.Join(indexDir, s.SegName+".fdt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		header := int64(4 + 1 + len(sf.DataCodec) + 4 + ID_LENGTH + 1)
+		if header+sf.CompressedBytes+FOOTER_LENGTH != fi.Size() {
+			t.Errorf("segment %s compressed_bytes = %d, .fdt size %d", s.SegName, sf.CompressedBytes, fi.Size())
+		}
+	}
+}
+
+// TestPostingsWithRealData tests term statistics read from the Lucene103 trie terms index
+func TestPostingsWithRealData(t *testing.T) {
+	report, err := buildReport(extractTestIndex(t, "s_NL8E3ySUW7ittn8yvdDQ.zip"))
+	if err != nil {
+		t.Fatalf("buildReport() error = %v", err)
+	}
+	for _, s := range report.Segments {
+		for _, fi := range s.Fields {
+			if (fi.IndexOptions != "NONE") != (fi.Postings != nil) {
+				t.Errorf("segment %s field %s index_options %s, postings %+v", s.SegName, fi.Name, fi.IndexOptions, fi.Postings)
+			}
+		}
+		if s.SegName != "_8rd" {
+			continue
+		}
+		for _, fi := range s.Fields {
+			p := fi.Postings
+			switch fi.Name {
+			case ES_ID_FIELD:
+				if p.NumTerms != 10209 || p.DocCount != 10209 || p.IndexType != TERMS_INDEX_TRIE || p.IndexSizeBytes != 2506 {
+					t.Errorf("_id postings = %+v", p)
+				}
+			case "message":
+				if p.NumTerms != 1 || p.MinTerm != "hello" || p.MaxTerm != "hello" {
+					t.Errorf("message postings = %+v", p)
+				}
+			}
+		}
+	}
+}
+
+// TestPointsWithRealData tests BKD metadata and decoded bounds of the point fields
+func TestPointsWithRealData(t *testing.T) {
+	report, err := buildReport(extractTestIndex(t, "s_NL8E3ySUW7ittn8yvdDQ.zip"))
+	if err != nil {
+		t.Fatalf("buildReport() error = %v", err)
+	}
+	for _, s := range report.Segments {
+		for _, fi := range s.Fields {
+			if (fi.PointDimensionCount > 0) != (fi.Points != nil) {
+				t.Errorf("segment %s field %s point dimensions %d, points %+v", s.SegName, fi.Name, fi.PointDimensionCount, fi.Points)
+			}
+		}
+		if s.SegName != "_8rd" {
+			continue
+		}
+		for _, fi := range s.Fields {
+			p := fi.Points
+			switch fi.Name {
+			case "_seq_no":
+				if p.ValueType != "long" || p.Min[0] != "1" || p.Max[0] != "10218" || p.PointCount != 10210 ||
+					p.NumLeaves != 20 || p.MaxPointsInLeaf != 512 {
+					t.Errorf("_seq_no points = %+v", p)
+				}
+			case "ts":
+				if p.ValueType != "long" || p.Min[0] != "1700000000" || p.Max[0] != "1700000000" {
+					t.Errorf("ts points = %+v", p)
+				}
+			}
+		}
+	}
+}
+
+// TestDocValuesWithRealData tests doc values metadata, including the generation-updated soft deletes file
+func TestDocValuesWithRealData(t *testing.T) {
+	report, err := buildReport(extractTestIndex(t, "s_NL8E3ySUW7ittn8yvdDQ.zip"))
+	if err != nil {
+		t.Fatalf("buildReport() error = %v", err)
+	}
+	for _, fi := range report.Segments[0].Fields {
+		if fi.Name == "_seq_no" && fi.DocValues.Min != nil {
+			t.Errorf("_seq_no doc values = %+v, want no bounds before addDocValuesRanges", fi.DocValues)
+		}
+	}
+	addDocValuesRanges(report)
+	for _, s := range report.Segments {
+		for _, fi := range s.Fields {
+			if (fi.DocValuesType != "NONE") != (fi.DocValues != nil) {
+				t.Errorf("segment %s field %s doc_values_type %s, doc values %+v", s.SegName, fi.Name, fi.DocValuesType, fi.DocValues)
+			}
+		}
+		if s.SegName != "_8rd" {
+			continue
+		}
+		for _, fi := range s.Fields {
+			dv := fi.DocValues
+			switch fi.Name {
+			case "_seq_no":
+				if dv.DocCount != 10210 || dv.Encoding != "delta" || dv.BitsPerValue != 16 || *dv.Min != 1 || *dv.Max != 10218 {
+					t.Errorf("_seq_no doc values = %+v", dv)
+				}
+			case ES_SOFT_DELETES_FIELD:
+				if dv.File != "_8rd_2_Lucene90_0" || dv.DocCount != int64(s.SoftDelCount) || dv.Encoding != "constant" {
+					t.Errorf("%s doc values = %+v, soft_del_count %d", fi.Name, dv, s.SoftDelCount)
+				}
+			}
+		}
+	}
+}
+
+// TestNormsWithRealData tests that every field with norms has an .nvm entry
+func TestNormsWithRealData(t *testing.T) {
+	report, err := buildReport(extractTestIndex(t, "s_NL8E3ySUW7ittn8yvdDQ.zip"))
+	if err != nil {
+		t.Fatalf("buildReport() error = %v", err)
+	}
+	for _, s := range report.Segments {
+		for _, fi := range s.Fields {
+			if fi.HasNorms != (fi.Norms != nil) {
+				t.Errorf("segment %s field %s has_norms %v, norms %+v", s.SegName, fi.Name, fi.HasNorms, fi.Norms)
+			}
+			// 每个文档的 message 都是 "hello"，norm 相同
+			if s.SegName == "_8rd" && fi.Name == "message" {
+				if n := fi.Norms; n.DocCount != 10209 || !n.Constant || n.BytesPerNorm != 0 || n.SizeBytes == 0 {
+					t.Errorf("message norms = %+v", n)
+				}
+			}
+		}
+	}
+}
+
+// TestPostingsStatsWithRealData tests singleton counts and the split of .doc/.pos among the fields
+func TestPostingsStatsWithRealData(t *testing.T) {
+	report, err := buildReport(extractTestIndex(t, "s_NL8E3ySUW7ittn8yvdDQ.zip"))
+	if err != nil {
+		t.Fatalf("buildReport() error = %v", err)
+	}
+	for _, fi := range report.Segments[0].Fields {
+		if fi.Postings != nil && fi.Postings.Stats != nil {
+			t.Fatalf("field %s has postings stats without ?postings_stats=true", fi.Name)
+		}
+	}
+	addPostingsStats(report)
+	s := report.Segments[0]
+	if s.SegName != "_8rd" {
+		t.Fatalf("first segment = %s, want _8rd", s.SegName)
+	}
+	if len(s.Errors) != 0 {
+		t.Fatalf("addPostingsStats() errors = %v", s.Errors)
+	}
+	byName := map[string]*FieldPostings{}
+	var docBytes, posBytes int64
+	for _, fi := range s.Fields {
+		if p := fi.Postings; p != nil {
+			byName[fi.Name] = p
+			docBytes, posBytes = docBytes+p.Stats.DocBytes, posBytes+p.Stats.PosBytes
+		}
+	}
+	// 每个 _id 只出现在一个文档中，文档号内联在 .tim 中
+	if p := byName["_id"]; p == nil || p.Stats.SingletonTerms != p.NumTerms || p.Stats.SingletonPercent != 100 || p.Stats.DocBytes != 0 || p.Positions {
+		t.Errorf("_id postings = %+v, stats %+v", p, p.Stats)
+	}
+	if p := byName["message"]; p == nil || p.Stats.SingletonTerms != 0 || !p.Positions || p.Offsets || p.Payloads || p.AvgPositionsPerDoc != 1 ||
+		p.Stats.DocBytes == 0 || p.Stats.PosBytes == 0 || p.Stats.SizeBytes != p.Stats.DocBytes+p.Stats.PosBytes {
+		t.Errorf("message postings = %+v, stats %+v", p, p.Stats)
+	}
+	// 各字段之和等于 .doc/.pos 去掉 header 和 footer 的大小
+	for _, e := range s.SizeByExtension {
+		want := map[string]int64{"doc": docBytes, "pos": posBytes}[e.Extension]
+		header := int64(4 + 1 + len("Lucene103PostingsWriterDoc") + 4 + ID_LENGTH + 1 + len("Lucene103_0"))
+		if want != 0 && header+want+FOOTER_LENGTH != e.SizeBytes {
+			t.Errorf("%s postings of the fields = %d bytes, file is %d bytes", e.Extension, want, e.SizeBytes)
+		}
+	}
+}
+
+// TestSegmentErrorsWithRealData tests that a truncated .nvm is reported on its segment without dropping the others
+func TestSegmentErrorsWithRealData(t *testing.T) {
+	indexDir := extractTestIndex(t, "s_NL8E3ySUW7ittn8yvdDQ.zip")
+	nvm := filepath.Join(indexDir, "_8rd.nvm")
+	data, err := os.ReadFile(nvm)
+	if err != nil {
+		t.Fatalf("Failed to read _8rd.nvm: %v", err)
+	}
+	os.WriteFile(nvm, data[:len(data)/2], 0644)
+
+	report, err := buildReport(indexDir)
+	if err != nil {
+		t.Fatalf("buildReport() error = %v", err)
+	}
+	if report.TotalSegments != 7 || len(report.Segments) != 7 {
+		t.Fatalf("buildReport() kept %d segments, want 7", len(report.Segments))
+	}
+	s := report.Segments[0]
+	if len(s.Errors) != 1 || !strings.Contains(s.Errors[0], "_8rd.nvm") {
+		t.Errorf("segment _8rd errors = %v, want one .nvm error", s.Errors)
+	}
+	if len(s.Fields) == 0 || s.StoredFields == nil || s.SizeBytes == 0 {
+		t.Errorf("segment _8rd lost the formats parsed around the error: %+v", s)
+	}
+	for _, s := range report.Segments[1:] {
+		if len(s.Errors) != 0 {
+			t.Errorf("segment %s errors = %v, want none", s.SegName, s.Errors)
+		}
+	}
+}
+
+// TestStateWithRealData tests the SMILE-encoded _state files of the OpenSearch index
+func TestStateWithRealData(t *testing.T) {
+	report, err := buildReport(extractTestIndex(t, "s_NL8E3ySUW7ittn8yvdDQ.zip"))
+	if err != nil {
+		t.Fatalf("buildReport() error = %v", err)
+	}
+	md := report.IndexMetadata
+	if md == nil || md.Name != "test" || md.UUID != "s_NL8E3ySUW7ittn8yvdDQ" || md.State != "open" || md.NumberOfShards != 1 ||
+		md.NumberOfReplicas != 1 || len(md.PrimaryTerms) != 1 || md.PrimaryTerms[0] != 3 || md.CreationDate != "2026-01-05T07:22:34.217Z" {
+		t.Fatalf("index_metadata = %+v", md)
+	}
+	// mappings 以 DEFLATE 压缩的 JSON 存为 SMILE 二进制值
+	doc, _ := md.Mappings["_doc"].(map[string]any)
+	props, _ := doc["properties"].(map[string]any)
+	if ts, _ := props["ts"].(map[string]any); ts["type"] != "long" {
+		t.Errorf("mappings = %v, want ts of type long", md.Mappings)
+	}
+	st := report.ShardState
+	if st == nil || !st.Primary || st.IndexUUID != md.UUID || st.AllocationID != "ftCNx8ZvTvquiIDGnTIUhQ" {
+		t.Fatalf("shard_state = %+v", st)
+	}
+	if ids := md.InSyncAllocations["0"]; len(ids) != 1 || ids[0] != st.AllocationID {
+		t.Errorf("in_sync_allocations = %v, want [%s]", md.InSyncAllocations, st.AllocationID)
+	}
+	rl := st.RetentionLeases
+	if rl == nil || rl.File != "0/_state/retention-leases-480.st" || rl.PrimaryTerm != 3 || rl.Version != 479 || len(rl.Leases) != 1 ||
+		rl.Leases[0].RetainingSeqNo != 10308 || rl.Leases[0].Source != "peer recovery" {
+		t.Errorf("retention_leases = %+v", rl)
+	}
+}
+
+// TestStateErrorsWithRealData tests that a corrupt _state file is reported without failing the intact Lucene index
+func TestStateErrorsWithRealData(t *testing.T) {
+	indexDir := extractTestIndex(t, "4bMihoe5Q8Ww7MB_n7z-EA.zip")
+	leases := filepath.Join(filepath.Dir(indexDir), STATE_DIR, "retention-leases-2.st")
+	data, err := os.ReadFile(leases)
+	if err != nil {
+		t.Fatalf("Failed to read retention leases: %v", err)
+	}
+	data[len(data)/2] = 0
+	os.WriteFile(leases, data, 0644)
+
+	report, err := buildReport(indexDir)
+	if err != nil || report.Partial {
+		t.Fatalf("buildReport() error = %v, partial = %v, want the index reported despite _state", err, report.Partial)
+	}
+	if len(report.StateErrors) != 1 || !strings.Contains(report.StateErrors[0], "0/_state/retention-leases-2.st") {
+		t.Errorf("state_errors = %v, want the retention leases checksum failure", report.StateErrors)
+	}
+	if report.ShardState == nil || report.ShardState.RetentionLeases != nil || report.IndexMetadata == nil {
+		t.Errorf("shard_state = %+v, index_metadata = %+v, want both without the retention leases", report.ShardState, report.IndexMetadata)
+	}
+}
+
+// TestSampleDocsWithRealData tests decoding _id and _source of live documents across chunk boundaries
+func TestSampleDocsWithRealData(t *testing.T) {
+	indexDir := extractTestIndex(t, "s_NL8E3ySUW7ittn8yvdDQ.zip")
+	report, err := buildReport(indexDir)
+	if err != nil {
+		t.Fatalf("buildReport() error = %v", err)
+	}
+	sample, err := sampleDocs(report, "_8rd", 4090, DOCS_MAX_SIZE)
+	if err != nil {
+		t.Fatalf("sampleDocs() error = %v", err)
+	}
+	if sample.Segment != "_8rd" || len(sample.Docs) != DOCS_MAX_SIZE || sample.Next != 4190 {
+		t.Fatalf("sampleDocs() = %s from %d, %d docs, next %d", sample.Segment, sample.From, len(sample.Docs), sample.Next)
+	}
+	for _, d := range sample.Docs {
+		if len(d.ID) != 20 || len(d.Source) == 0 || d.SourceEncoding != "" {
+			t.Errorf("doc %d = %+v, want a 20 char auto id and a JSON _source", d.Doc, d)
+		}
+	}
+
+	if _, err := sampleDocs(report, "_missing", 0, 10); err == nil {
+		t.Errorf("sampleDocs() of an unknown segment succeeded")
+	}
+	if _, err := sampleDocs(report, "", 0, DOCS_MAX_SIZE+1); err == nil {
+		t.Errorf("sampleDocs() with size %d succeeded", DOCS_MAX_SIZE+1)
+	}
+}
+
+// TestRecoverDocumentsWithRealData tests that soft-deleted documents are left out of the bulk output
+func TestRecoverDocumentsWithRealData(t *testing.T) {
+	indexDir := extractTestIndex(t, "s_NL8E3ySUW7ittn8yvdDQ.zip")
+	var out bytes.Buffer
+	stats, err := recoverDocuments(indexDir, "restored", &out)
+	if err != nil {
+		t.Fatalf("recoverDocuments() error = %v", err)
+	}
+	if stats.Segments != 7 || stats.Recovered != 10294 || stats.SoftDeleted != 3 || stats.Deleted != 0 || len(stats.FailedSegments) != 0 {
+		t.Errorf("recoverDocuments() stats = %+v, want 7 segments, 10294 recovered, 3 soft deleted", stats)
+	}
+
+	lines := bytes.Split(bytes.TrimSuffix(out.Bytes(), []byte("\n")), []byte("\n"))
+	if len(lines) != 2*int(stats.Recovered) {
+		t.Fatalf("recoverDocuments() wrote %d lines, want %d", len(lines), 2*stats.Recovered)
+	}
+	ids := map[string]bool{}
+	for i := 0; i < len(lines); i += 2 {
+		var action bulkAction
+		if err := json.Unmarshal(lines[i], &action); err != nil || action.Index.Index != "restored" || action.Index.ID == "" {
+			t.Fatalf("action line %d = %s, %v", i, lines[i], err)
+		}
+		if !json.Valid(lines[i+1]) {
+			t.Fatalf("source line %d is not valid JSON: %s", i+1, lines[i+1])
+		}
+		ids[action.Index.ID] = true
+	}
+	if len(ids) != int(stats.Recovered) {
+		t.Errorf("recoverDocuments() wrote %d distinct ids, want %d", len(ids), stats.Recovered)
+	}
+}
+
+// TestOpenRecoverReaderWithRealData tests that segments which cannot be opened are skipped, and that the index fails when none can be
+func TestOpenRecoverReaderWithRealData(t *testing.T) {
+	indexDir := extractTestIndex(t, "s_NL8E3ySUW7ittn8yvdDQ.zip")
+	os.WriteFile(filepath.Join(indexDir, "_8rd.fdm"), nil, 0644)
+
+	rr, err := openRecoverReader(indexDir)
+	if err != nil {
+		t.Fatalf("openRecoverReader() error = %v", err)
+	}
+	rr.Close()
+	if len(rr.segments) != 6 || len(rr.stats.FailedSegments) != 1 || !strings.HasPrefix(rr.stats.FailedSegments[0], "_8rd: ") {
+		t.Errorf("openRecoverReader() opened %d segments, failed %v, want 6 and _8rd", len(rr.segments), rr.stats.FailedSegments)
+	}
+
+	cfs, _ := filepath.Glob(filepath.Join(indexDir, "*.cfs"))
+	for _, f := range cfs {
+		os.Remove(f)
+	}
+	if _, err := openRecoverReader(indexDir); err == nil || !strings.Contains(err.Error(), "no segment can be recovered") {
+		t.Errorf("openRecoverReader() error = %v, want no segment can be recovered", err)
+	}
+}
+
+// TestRecoverHandlerWithRealData tests the streamed NDJSON response and its stats trailer
+func TestRecoverHandlerWithRealData(t *testing.T) {
+	archive, err := os.ReadFile("../test/test-data/xGXIZba7Qha6U73SBkrCIw.zip")
+	if err != nil {
+		t.Fatalf("Failed to read test data file: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/recover", bytes.NewReader(archive))
+	req.Header.Set("Content-Type", "application/zip")
+	rec := httptest.NewRecorder()
+	recoverHandler(rec, req)
+
+	res := rec.Result()
+	if res.StatusCode != http.StatusOK || res.Header.Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("recoverHandler() status = %d, content type %q", res.StatusCode, res.Header.Get("Content-Type"))
+	}
+	body, _ := io.ReadAll(res.Body)
+	var stats RecoverStats
+	if err := json.Unmarshal([]byte(res.Trailer.Get("X-Recover-Stats")), &stats); err != nil {
+		t.Fatalf("X-Recover-Stats trailer = %q: %v", res.Trailer.Get("X-Recover-Stats"), err)
+	}
+	if stats.Recovered != 9 || stats.SoftDeleted != 6 || bytes.Count(body, []byte("\n")) != 18 {
+		t.Errorf("recoverHandler() stats = %+v with %d lines", stats, bytes.Count(body, []byte("\n")))
+	}
+	if res.Trailer.Get("X-Recover-Error") != "" {
+		t.Errorf("X-Recover-Error = %q", res.Trailer.Get("X-Recover-Error"))
+	}
+}
+
+// TestTopTermsWithRealData tests merging term statistics over segments, including deleted documents
+func TestTopTermsWithRealData(t *testing.T) {
+	top, err := topTerms(extractTestIndex(t, "s_NL8E3ySUW7ittn8yvdDQ.zip"), "message.keyword", 5)
+	if err != nil {
+		t.Fatalf("topTerms() error = %v", err)
+	}
+	if top.Segments != 7 || top.UniqueTerms != 1 || len(top.Terms) != 1 || top.Terms[0].Term != "hello" || top.Terms[0].DocFreq != 10296 {
+		t.Errorf("topTerms(message.keyword) = %+v", top)
+	}
+
+	// 同一 _id 的旧版本仍计入 docFreq
+	top, err = topTerms(extractTestIndex(t, "xGXIZba7Qha6U73SBkrCIw.zip"), ES_ID_FIELD, 2)
+	if err != nil {
+		t.Fatalf("topTerms() error = %v", err)
+	}
+	if top.UniqueTerms != 9 || len(top.Terms) != 2 || top.Terms[0].DocFreq != 3 || top.Terms[0].Term > top.Terms[1].Term {
+		t.Errorf("topTerms(_id) = %+v", top)
+	}
+
+	if _, err := topTerms(extractTestIndex(t, "xGXIZba7Qha6U73SBkrCIw.zip"), "missing", 10); err == nil {
+		t.Errorf("topTerms() of an unknown field succeeded")
+	}
+}
+
+// TestTermsHandlerWithRealData tests streaming the terms of a field filtered by prefix
+func TestTermsHandlerWithRealData(t *testing.T) {
+	archive, err := os.ReadFile("../test/test-data/s_NL8E3ySUW7ittn8yvdDQ.zip")
+	if err != nil {
+		t.Fatalf("Failed to read test data file: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/terms?field=_id&prefix=%00%B7&limit=3", bytes.NewReader(archive))
+	req.Header.Set("Content-Type", "application/zip")
+	rec := httptest.NewRecorder()
+	termsHandler(rec, req)
+
+	res := rec.Result()
+	if res.StatusCode != http.StatusOK || res.Header.Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("termsHandler() status = %d, content type %q", res.StatusCode, res.Header.Get("Content-Type"))
+	}
+	body, _ := io.ReadAll(res.Body)
+	lines := bytes.Split(bytes.TrimSuffix(body, []byte("\n")), []byte("\n"))
+	if len(lines) != 3 || res.Trailer.Get("X-Terms-Count") != "3" || res.Trailer.Get("X-Terms-Error") != "" {
+		t.Fatalf("termsHandler() = %q, trailers %v", body, res.Trailer)
+	}
+	var prev string
+	for _, line := range lines {
+		var ts TermStats
+		if err := json.Unmarshal(line, &ts); err != nil {
+			t.Fatalf("line %q: %v", line, err)
+		}
+		// 0x00 0xB7 开头的 Uid 即以 ALc 开头的自动生成 ID
+		if !strings.HasPrefix(ts.Term, "ALc") || ts.Term <= prev || ts.DocFreq != 1 {
+			t.Errorf("term %+v after %q", ts, prev)
+		}
+		prev = ts.Term
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/terms?field=missing", bytes.NewReader(archive))
+	req.Header.Set("Content-Type", "application/zip")
+	rec = httptest.NewRecorder()
+	termsHandler(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("termsHandler() of an unknown field status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
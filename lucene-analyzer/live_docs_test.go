@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeLiveDocs writes _0_<gen>.liv for maxDoc docs with the given docs deleted
+func writeLiveDocs(t *testing.T, dir string, gen string, maxDoc int, deleted []int) {
+	t.Helper()
+	words := make([]uint64, (maxDoc+63)/64)
+	for doc := 0; doc < maxDoc; doc++ {
+		words[doc/64] |= 1 << (doc % 64)
+	}
+	for _, doc := range deleted {
+		words[doc/64] &^= 1 << (doc % 64)
+	}
+	var buf bytes.Buffer
+	writeIndexHeader(&buf, LIVE_DOCS_CODEC_NAME, LIVE_DOCS_VERSION, gen)
+	for _, w := range words {
+		binary.Write(&buf, binary.LittleEndian, w)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "_0_"+gen+".liv"), withFooter(buf.Bytes()), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+}
+
+// TestParseLiveDocs tests deleted-doc counting, the del_count cross-check and the histogram
+func TestParseLiveDocs(t *testing.T) {
+	tempDir := t.TempDir()
+	writeLiveDocs(t, tempDir, "2", 130, []int{0, 1, 2, 129})
+
+	tests := []struct {
+		name        string
+		delCount    int32
+		wantMatches bool
+	}{
+		{"matching del_count", 4, true},
+		{"stale del_count", 3, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &SegInfoSummary{SegName: "_0", MaxDoc: 130, DelGen: 2, DelCount: tt.delCount}
+			info, err := parseLiveDocs(FSDirectory{tempDir}, s)
+			if err != nil {
+				t.Fatalf("parseLiveDocs() error = %v", err)
+			}
+			if info.File != "_0_2.liv" || info.DeletedDocs != 4 || info.LiveDocs != 126 {
+				t.Errorf("parseLiveDocs() = %+v, want 4 deleted, 126 live", info)
+			}
+			if info.DelCountMatches != tt.wantMatches {
+				t.Errorf("parseLiveDocs() DelCountMatches = %v, want %v", info.DelCountMatches, tt.wantMatches)
+			}
+		})
+	}
+
+	s := &SegInfoSummary{SegName: "_0", MaxDoc: 130, DelGen: 2, DelCount: 4}
+	info, _ := parseLiveDocs(FSDirectory{tempDir}, s)
+	h := info.Histogram
+	if len(h) == 0 || len(h) > DELETION_HISTOGRAM_BUCKETS || h[0].StartDoc != 0 || h[len(h)-1].EndDoc != 130 {
+		t.Fatalf("deletion histogram = %+v", h)
+	}
+	if h[0].Deleted != 3 || h[len(h)-1].Deleted != 1 {
+		t.Errorf("deletion histogram first/last = %+v / %+v, want 3 / 1 deleted", h[0], h[len(h)-1])
+	}
+	if h[0].Density != 3.0/7 {
+		t.Errorf("deletion histogram density = %v, want %v", h[0].Density, 3.0/7)
+	}
+}
+
+// TestParseLiveDocsErrors tests generation mismatches and bits set past maxDoc
+func TestParseLiveDocsErrors(t *testing.T) {
+	tempDir := t.TempDir()
+	writeLiveDocs(t, tempDir, "3", 130, nil)
+
+	// 文件名与 header 后缀不一致
+	os.Rename(filepath.Join(tempDir, "_0_3.liv"), filepath.Join(tempDir, "_0_4.liv"))
+	if _, err := parseLiveDocs(FSDirectory{tempDir}, &SegInfoSummary{SegName: "_0", MaxDoc: 130, DelGen: 4}); err == nil {
+		t.Errorf("parseLiveDocs() should reject a header suffix that does not match delGen")
+	}
+
+	writeLiveDocs(t, tempDir, "3", 130, nil)
+	if _, err := parseLiveDocs(FSDirectory{tempDir}, &SegInfoSummary{SegName: "_0", MaxDoc: 100, DelGen: 3}); err == nil {
+		t.Errorf("parseLiveDocs() should reject live bits beyond maxDoc")
+	}
+}
+
+// TestDeletionHistogram tests bucketing when there are fewer docs than buckets
+func TestDeletionHistogram(t *testing.T) {
+	h := deletionHistogram([]uint64{0b101}, 3, DELETION_HISTOGRAM_BUCKETS)
+	if len(h) != 3 || h[1].Deleted != 1 || h[0].Deleted != 0 || h[1].Density != 1 {
+		t.Errorf("deletionHistogram() = %+v", h)
+	}
+	if h := deletionHistogram(nil, 0, DELETION_HISTOGRAM_BUCKETS); h != nil {
+		t.Errorf("deletionHistogram() on an empty segment = %+v, want nil", h)
+	}
+}
@@ -1,149 +1,44 @@
 package main
 
 import (
-	"bufio"
-	"encoding/binary"
 	"encoding/hex"
 	"errors"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 )
 
-// ---------- low-level readers for Lucene DataInput style ----------
+// ---------- constants (low-level readers live in data_input.go) ----------
 
 const (
 	CODEC_MAGIC       = 0x3fd76c17
 	ID_LENGTH         = 16 // Lucene StringHelper.ID_LENGTH == 16
 	SEGMENTS_PREFIX   = "segments"
 	SEGMENTS_GEN_FILE = "segments.gen"
-)
-
-func readExactly(r io.Reader, n int) ([]byte, error) {
-	buf := make([]byte, n)
-	_, err := io.ReadFull(r, buf)
-	return buf, err
-}
-
-func readBEInt32(r io.Reader) (int32, error) {
-	b, err := readExactly(r, 4)
-	if err != nil {
-		return 0, err
-	}
-	return int32(binary.BigEndian.Uint32(b)), nil
-}
-
-func readBELong(r io.Reader) (int64, error) {
-	b, err := readExactly(r, 8)
-	if err != nil {
-		return 0, err
-	}
-	return int64(binary.BigEndian.Uint64(b)), nil
-}
-
-func readByte(r io.Reader) (byte, error) {
-	b := make([]byte, 1)
-	_, err := io.ReadFull(r, b)
-	if err != nil {
-		return 0, err
-	}
-	return b[0], nil
-}
-
-func readVInt(r io.Reader) (int, error) {
-	var result int
-	var shift uint
-	for i := 0; i < 5; i++ {
-		b, err := readByte(r)
-		if err != nil {
-			return 0, err
-		}
-		result |= int(b&0x7F) << shift
-		if b&0x80 == 0 {
-			return result, nil
-		}
-		shift += 7
-	}
-	return 0, errors.New("invalid vInt (too long)")
-}
-
-func readVLong(r io.Reader) (int64, error) {
-	var result int64
-	var shift uint
-	for i := 0; i < 10; i++ {
-		b, err := readByte(r)
-		if err != nil {
-			return 0, err
-		}
-		result |= int64(b&0x7F) << shift
-		if b&0x80 == 0 {
-			return result, nil
-		}
-		shift += 7
-	}
-	return 0, errors.New("invalid vLong (too long)")
-}
 
-func readString(r io.Reader) (string, error) {
-	n, err := readVInt(r)
-	if err != nil {
-		return "", err
-	}
-	if n < 0 {
-		return "", errors.New("negative string length")
-	}
-	if n == 0 {
-		return "", nil
-	}
-	b, err := readExactly(r, n)
-	if err != nil {
-		return "", err
-	}
-	return string(b), nil
-}
-
-func readSetOfStrings(r io.Reader) ([]string, error) {
-	cnt, err := readVInt(r)
-	if err != nil {
-		return nil, err
-	}
-	out := make([]string, 0, cnt)
-	for i := 0; i < cnt; i++ {
-		s, err := readString(r)
-		if err != nil {
-			return nil, err
-		}
-		out = append(out, s)
-	}
-	return out, nil
-}
-
-func readMapOfStrings(r io.Reader) (map[string]string, error) {
-	cnt, err := readVInt(r)
-	if err != nil {
-		return nil, err
-	}
-	m := make(map[string]string, cnt)
-	for i := 0; i < cnt; i++ {
-		k, err := readString(r)
-		if err != nil {
-			return nil, err
-		}
-		v, err := readString(r)
-		if err != nil {
-			return nil, err
-		}
-		m[k] = v
-	}
-	return m, nil
-}
+	SEGMENTS_CODEC_NAME      = "segments"
+	SEGMENTS_VERSION_70      = 7  // Lucene 7.0; older commits are rejected as unsupported
+	SEGMENTS_VERSION_72      = 8  // Lucene 7.2: the counter becomes a vLong
+	SEGMENTS_VERSION_74      = 9  // Lucene 7.4: adds softDelCount
+	SEGMENTS_VERSION_86      = 10 // adds the SegmentCommitInfo ID
+	SEGMENTS_VERSION_CURRENT = SEGMENTS_VERSION_86
+	SI_CODEC_NAME            = "Lucene90SegmentInfo"
+	SI_CODEC_NAME_86         = "Lucene86SegmentInfo" // Lucene 8.6 - 8.11
+	SI_CODEC_NAME_70         = "Lucene70SegmentInfo" // Lucene 7.0 - 8.5
+)
 
 // ---------- helpers to find latest segments_N file ----------
 
+// generationFromSegmentsFileName parses the base36 generation of segments_N;
+// a bare "segments" file, as written by the first commit of old indexes, is
+// generation 0.
 func generationFromSegmentsFileName(name string) (int64, error) {
+	if name == SEGMENTS_PREFIX {
+		return 0, nil
+	}
 	if !strings.HasPrefix(name, SEGMENTS_PREFIX+"_") {
 		return -1, fmt.Errorf("bad segments name: %s", name)
 	}
@@ -156,12 +51,24 @@ func generationFromSegmentsFileName(name string) (int64, error) {
 }
 
 func findLatestSegmentsFile(dir string) (string, error) {
-	fis, err := os.ReadDir(dir)
+	names, err := listSegmentsFiles(dir)
 	if err != nil {
 		return "", err
 	}
-	var bestName string
-	var bestGen int64 = -1
+	if len(names) == 0 {
+		return "", errors.New("no segments_N file found")
+	}
+	return names[len(names)-1], nil
+}
+
+// listSegmentsFiles returns every commit point in dir ordered by generation;
+// a bare "segments" file counts as generation 0.
+func listSegmentsFiles(dir string) ([]string, error) {
+	fis, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	gens := map[string]int64{}
 	for _, fi := range fis {
 		name := fi.Name()
 		if !strings.HasPrefix(name, SEGMENTS_PREFIX) {
@@ -170,26 +77,18 @@ func findLatestSegmentsFile(dir string) (string, error) {
 		if name == SEGMENTS_GEN_FILE {
 			continue
 		}
-		if name == SEGMENTS_PREFIX {
-			if bestGen < 0 {
-				bestGen = 0
-				bestName = name
-			}
-			continue
-		}
 		gen, err := generationFromSegmentsFileName(name)
 		if err != nil {
 			continue
 		}
-		if gen > bestGen {
-			bestGen = gen
-			bestName = name
-		}
+		gens[name] = gen
 	}
-	if bestName == "" {
-		return "", errors.New("no segments_N file found")
+	names := make([]string, 0, len(gens))
+	for name := range gens {
+		names = append(names, name)
 	}
-	return bestName, nil
+	sort.Slice(names, func(i, j int) bool { return gens[names[i]] < gens[names[j]] })
+	return names, nil
 }
 
 // ---------- parse .si (SegmentInfo) per Lucene90SegmentInfoFormat ----------
@@ -198,30 +97,59 @@ type Version struct {
 	Major, Minor, Bugfix int32
 }
 
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Bugfix)
+}
+
+func (v Version) onOrAfter(major, minor int32) bool {
+	return v.Major > major || (v.Major == major && v.Minor >= minor)
+}
+
 type SegmentInfo struct {
 	Name           string
+	Format         string // codec name of the .si header
 	ID             []byte
 	Version        Version
 	MinVersion     *Version
 	DocCount       int32
 	IsCompoundFile bool
+	HasBlocks      bool
 	Diagnostics    map[string]string
 	Files          []string
 	Attributes     map[string]string
+	IndexSort      []IndexSortField
 }
 
-func readMap(r io.Reader) (map[string]string, error) {
-	sz, err := readVInt(r)
-	if err != nil {
-		return nil, err
+// readVersion reads a Version written as three ints (major, minor, bugfix).
+func readVersion(in *DataInput, field string) (Version, error) {
+	var v Version
+	var err error
+	if v.Major, err = in.ReadInt(); err != nil {
+		return v, in.fail(field+".major", err)
+	}
+	if v.Minor, err = in.ReadInt(); err != nil {
+		return v, in.fail(field+".minor", err)
 	}
-	m := make(map[string]string, sz)
-	for i := int(0); i < sz; i++ {
-		k, _ := readString(r)
-		v, _ := readString(r)
-		m[k] = v
+	if v.Bugfix, err = in.ReadInt(); err != nil {
+		return v, in.fail(field+".bugfix", err)
 	}
-	return m, nil
+	return v, nil
+}
+
+// readVIntVersion reads a Version written as three vInts, as in segments_N.
+func readVIntVersion(in *DataInput, field string) (Version, error) {
+	var v Version
+	var err error
+	if v.Major, err = in.ReadVInt(); err != nil {
+		return v, in.fail(field+".major", err)
+	}
+	if v.Minor, err = in.ReadVInt(); err != nil {
+		return v, in.fail(field+".minor", err)
+	}
+	if v.Bugfix, err = in.ReadVInt(); err != nil {
+		return v, in.fail(field+".bugfix", err)
+	}
+	return v, nil
 }
 
 // parseSegmentSI is now in lucene_parser.go
@@ -229,35 +157,100 @@ func readMap(r io.Reader) (map[string]string, error) {
 // ---------- parse segments_N (SegmentInfos) ----------
 
 type SegInfoSummary struct {
-	SegName       string            `json:"name"`
-	SegID         string            `json:"seg_id"` // hex
-	SegCodec      string            `json:"codec"`
-	MaxDoc        int32             `json:"max_doc"`
-	Compound      bool              `json:"compound"`
-	Files         []string          `json:"files,omitempty"`
-	DelGen        int64             `json:"del_gen"`
-	DelCount      int32             `json:"del_count"`
-	FieldInfosGen int64             `json:"field_infos_gen"`
-	DVGen         int64             `json:"dv_gen"`
-	SoftDelCount  int32             `json:"soft_del_count"`
-	SciID         string            `json:"sci_id,omitempty"`
-	Extra         map[string]string `json:"diagnostics,omitempty"`
+	SegName         string            `json:"name"`
+	SegID           string            `json:"seg_id"` // hex
+	SegCodec        string            `json:"codec"`
+	SIFormat        string            `json:"si_format"` // codec name of the .si header, e.g. Lucene90SegmentInfo
+	LuceneVersion   string            `json:"lucene_version"`
+	MinVersion      string            `json:"min_version,omitempty"` // oldest version among merged segments
+	MaxDoc          int32             `json:"max_doc"`
+	Compound        bool              `json:"compound"`
+	Files           []string          `json:"files,omitempty"`
+	DelGen          int64             `json:"del_gen"`
+	DelCount        int32             `json:"del_count"`
+	FieldInfosGen   int64             `json:"field_infos_gen"`
+	DVGen           int64             `json:"dv_gen"`
+	SoftDelCount    int32             `json:"soft_del_count"`
+	SciID           string            `json:"sci_id,omitempty"`
+	Extra           map[string]string `json:"diagnostics,omitempty"`
+	Attributes      map[string]string `json:"attributes,omitempty"` // e.g. Lucene90StoredFieldsFormat.mode
+	IndexSort       []IndexSortField  `json:"index_sort,omitempty"`
+	UpdatesFiles    []string          `json:"updates_files,omitempty"` // files written by field infos updates (_<seg>_<gen>.fnm)
+	DVUpdates       []DocValuesUpdate `json:"dv_updates,omitempty"`
+	FieldInfosFile  string            `json:"field_infos_file,omitempty"`
+	Fields          []FieldInfo       `json:"fields,omitempty"`
+	CompoundFiles   []CompoundEntry   `json:"compound_files,omitempty"` // sub-files of .cfs, compound segments only
+	LiveDocs        *LiveDocsInfo     `json:"live_docs,omitempty"`      // only for segments with hard deletes
+	StoredFields    *StoredFieldsInfo `json:"stored_fields,omitempty"`  // Lucene90 stored fields only
+	TermVectors     *TermVectorsInfo  `json:"term_vectors,omitempty"`   // only when some field stores term vectors
+	SizeBytes       int64             `json:"size_bytes"`
+	SizePercent     float64           `json:"size_percent"` // of the shard total
+	SizeByExtension []ExtensionSize   `json:"size_by_extension,omitempty"`
+	Checksums       []FileChecksum    `json:"checksums,omitempty"`
+	Errors          []string          `json:"errors,omitempty"` // files of this segment that could not be decoded
+
+	siErr error // the .si could not be read; only the segments_N entry is known
 }
 
-// parseSegmentsFile is now in lucene_parser.go
+// DocValuesUpdate lists the files holding the updated doc values of one field.
+type DocValuesUpdate struct {
+	FieldNumber int32    `json:"field_number"`
+	FieldName   string   `json:"field_name,omitempty"` // resolved from the segment's .fnm
+	Gen         int64    `json:"gen"`                  // doc values generation of the field, from the .fnm
+	Files       []string `json:"files"`
+}
+
+// SegmentInfos is the decoded content of a segments_N commit file.
+type SegmentInfos struct {
+	FormatVersion            int32
+	ID                       []byte
+	Generation               int64
+	LuceneVersion            Version // Lucene version that wrote the commit
+	IndexCreatedVersionMajor int32
+	Version                  int64 // incremented on every change to the index
+	Counter                  int64 // used to name new segments: "_" + base36(counter)
+	MinSegmentLuceneVersion  *Version
+	Segments                 []SegInfoSummary
+	UserData                 map[string]string
+}
 
 // ---------- report building and printing ----------
 
 type Report struct {
-	IndexPath            string            `json:"index_path"`
-	SegmentsFile         string            `json:"segments_file"`
-	TotalSegments        int               `json:"total_segments"`
-	TotalDocs            int64             `json:"total_docs"`
-	TotalDeletedDocs     int64             `json:"total_deleted_docs"`
-	TotalSoftDeletedDocs int64             `json:"total_soft_deleted_docs"`
-	UserData             map[string]string `json:"user_data,omitempty"`
-	Segments             []SegInfoSummary  `json:"segments"`
-	Notes                string            `json:"notes,omitempty"`
+	IndexPath             string            `json:"index_path"`
+	SegmentsFile          string            `json:"segments_file"`
+	FormatVersion         int32             `json:"format_version"` // segments_N format: 7 (Lucene 7.0) to 10 (Lucene 8.6+)
+	CommitID              string            `json:"commit_id"`      // hex
+	Generation            int64             `json:"generation"`
+	CommitLuceneVersion   string            `json:"commit_lucene_version"`
+	IndexCreatedMajor     int32             `json:"index_created_version_major"`
+	SegmentInfosVersion   int64             `json:"segment_infos_version"`
+	NameCounter           int64             `json:"name_counter"`
+	MinSegmentVersion     string            `json:"min_segment_lucene_version,omitempty"` // only written when there are segments
+	TotalSegments         int               `json:"total_segments"`
+	TotalDocs             int64             `json:"total_docs"`
+	TotalDeletedDocs      int64             `json:"total_deleted_docs"`
+	TotalSoftDeletedDocs  int64             `json:"total_soft_deleted_docs"`
+	UserData              map[string]string `json:"user_data,omitempty"`
+	IndexMetadata         *IndexMetadata    `json:"index_metadata,omitempty"` // Elasticsearch/OpenSearch <index uuid>/_state
+	ShardState            *ShardState       `json:"shard_state,omitempty"`    // Elasticsearch/OpenSearch <shard>/_state
+	StateErrors           []string          `json:"state_errors,omitempty"`   // _state files that could not be decoded
+	Integrity             string            `json:"integrity"`
+	CorruptFiles          []string          `json:"corrupt_files,omitempty"`
+	SegmentsChecksum      *FileChecksum     `json:"segments_checksum,omitempty"`
+	Partial               bool              `json:"partial,omitempty"` // parsing stopped early, see the accompanying error
+	TotalSizeBytes        int64             `json:"total_size_bytes"`
+	SizeByExtension       []ExtensionSize   `json:"size_by_extension,omitempty"`
+	Segments              []SegInfoSummary  `json:"segments"`
+	UnreferencedFiles     []FileRef         `json:"unreferenced_files,omitempty"`
+	UnreferencedSizeBytes int64             `json:"unreferenced_size_bytes"`
+	MissingFiles          []FileRef         `json:"missing_files,omitempty"`
+	IncompleteCommits     []string          `json:"incomplete_commits,omitempty"` // segments_N not fully decoded; unreferenced_files is left out
+	ReferencesError       string            `json:"references_error,omitempty"`   // the directory could not be listed; unreferenced_files is left out
+	Commits               []CommitPoint     `json:"commits,omitempty"`            // only with ?commits=true
+	Docs                  *DocSample        `json:"docs,omitempty"`               // only with ?docs=true
+	TopTerms              *TopTerms         `json:"top_terms,omitempty"`          // only with ?top_terms=<field>
+	Notes                 string            `json:"notes,omitempty"`
 }
 
 func buildReport(indexDir string) (*Report, error) {
@@ -265,14 +258,21 @@ func buildReport(indexDir string) (*Report, error) {
 	if err != nil {
 		return nil, err
 	}
-	summaries, userData, err := parseSegmentsFile(indexDir, segFile)
-	if err != nil {
-		return nil, err
+	infos, parseErr := parseSegmentsFile(indexDir, segFile)
+	// 段内文件只为最新提交解析；一个段出错不影响其他段，错误记录在该段的 errors 中
+	for i := range infos.Segments {
+		s := &infos.Segments[i]
+		if s.siErr != nil {
+			continue
+		}
+		if err := parseSegmentFiles(FSDirectory{indexDir}, s); err != nil {
+			s.Errors = append(s.Errors, err.Error())
+		}
 	}
 	var totalDocs int64
 	var totalDeleted int64
 	var totalSoftDeleted int64
-	for _, s := range summaries {
+	for _, s := range infos.Segments {
 		totalDocs += int64(s.MaxDoc)
 		totalDeleted += int64(s.DelCount)
 		totalSoftDeleted += int64(s.SoftDelCount) // 累加软删除数量
@@ -280,140 +280,404 @@ func buildReport(indexDir string) (*Report, error) {
 	rep := &Report{
 		IndexPath:            indexDir,
 		SegmentsFile:         segFile,
-		TotalSegments:        len(summaries),
+		FormatVersion:        infos.FormatVersion,
+		CommitID:             hex.EncodeToString(infos.ID),
+		Generation:           infos.Generation,
+		CommitLuceneVersion:  infos.LuceneVersion.String(),
+		IndexCreatedMajor:    infos.IndexCreatedVersionMajor,
+		SegmentInfosVersion:  infos.Version,
+		NameCounter:          infos.Counter,
+		TotalSegments:        len(infos.Segments),
 		TotalDocs:            totalDocs,
 		TotalDeletedDocs:     totalDeleted,
 		TotalSoftDeletedDocs: totalSoftDeleted,
-		UserData:             userData,
-		Segments:             summaries,
-		Notes:                "Parsed per Lucene90SegmentInfoFormat: segVersion (string), maxDoc (int32), isCompound (byte), diagnostics, files, attributes.",
+		UserData:             infos.UserData,
+		Segments:             infos.Segments,
+		Notes:                "Parsed per Lucene70/86/90SegmentInfoFormat: segVersion (string), maxDoc (int32), isCompound (byte), diagnostics, files, attributes.",
+	}
+	if infos.MinSegmentLuceneVersion != nil {
+		rep.MinSegmentVersion = infos.MinSegmentLuceneVersion.String()
+	}
+	verifyIndexIntegrity(rep)
+	summarizeSizes(rep)
+	// 文件引用需要所有提交点，而不仅是最新的一个；最新提交复用上面的解析结果
+	// 列目录失败不影响索引本身的报告，只记录在 references_error 中
+	if rep.Commits, err = analyzeCommitPoints(indexDir, segFile, infos, parseErr); err == nil {
+		err = checkFileReferences(rep, rep.Commits)
+	}
+	if err != nil {
+		rep.UnreferencedFiles, rep.UnreferencedSizeBytes = nil, 0
+		rep.ReferencesError = err.Error()
+	}
+	// 索引目录位于 <index uuid>/<shard>/index，两级 _state 由 Elasticsearch/OpenSearch 写入
+	// _state 不属于 Lucene 索引，读取失败只记录在 state_errors 中
+	shardPath := filepath.Dir(indexDir)
+	rep.ShardState, err = readShardState(shardPath)
+	if err != nil {
+		rep.StateErrors = append(rep.StateErrors, err.Error())
+	}
+	if rep.ShardState != nil || err != nil {
+		if rep.IndexMetadata, err = readIndexMetadata(filepath.Dir(shardPath)); err != nil {
+			rep.StateErrors = append(rep.StateErrors, err.Error())
+		}
+	}
+	// 点索引的数值类型无法仅从编码判断，取自映射
+	if rep.IndexMetadata != nil {
+		applyMappedPointTypes(rep.Segments, rep.IndexMetadata.Mappings)
+	}
+	if parseErr != nil {
+		rep.Partial = true
+		return rep, parseErr
 	}
 	return rep, nil
 }
+
 // .si: Header, SegVersion, SegSize, IsCompoundFile, Diagnostics, Files, Attributes, IndexSort, Footer
-// parseSegmentSI 读取并解析 .si 文件 
-func parseSegmentSI(indexDir, segName string) (int32, bool, map[string]string, error) {
-	path := filepath.Join(indexDir, segName+".si")
-	f, err := os.Open(path)
+// parseSegmentSI 读取并解析 .si 文件
+func parseSegmentSI(indexDir, segName string) (*SegmentInfo, error) {
+	in, err := OpenDataInput(filepath.Join(indexDir, segName+".si"))
 	if err != nil {
-		return 0, false, nil, err
+		return nil, err
 	}
-	defer f.Close()
-	r := bufio.NewReader(f)
-
-	// 跳过 Header: Magic(4), Codec(String), Ver(4), ID(16), Suffix(String)
-	readBEInt32(r)
-	readString(r)
-	readBEInt32(r)
-	readExactly(r, 16)
-	readString(r)
-
-	// 读取版本和可选版本 
-	var v Version
-	binary.Read(r, binary.LittleEndian, &v)
-	var hasMin byte
-	binary.Read(r, binary.LittleEndian, &hasMin)
-	if hasMin == 1 {
-		binary.Read(r, binary.LittleEndian, &v)
+	defer in.Close()
+
+	// Header: Magic(4), Codec(String), Ver(4), ID(16), Suffix(String)
+	// 8.x 及更早的格式为大端序，checkIndexHeaderOf 会切换字节序
+	hdr, err := checkIndexHeaderOf(in,
+		HeaderFormat{Codec: SI_CODEC_NAME},
+		HeaderFormat{Codec: SI_CODEC_NAME_86, BigEndian: true},
+		HeaderFormat{Codec: SI_CODEC_NAME_70, BigEndian: true})
+	if err != nil {
+		return nil, err
 	}
+	si := &SegmentInfo{Name: segName, ID: hdr.ID, Format: hdr.Codec}
 
-	var docCount int32
-	binary.Read(r, binary.LittleEndian, &docCount)
-	isCompound, _ := readByte(r)
-	diag, _ := readMap(r)
+	// 读取版本和可选的最小版本（段由合并产生时为参与合并的最旧版本）
+	if si.Version, err = readVersion(in, "segVersion"); err != nil {
+		return nil, err
+	}
+	hasMin, err := in.ReadByte()
+	if err != nil {
+		return nil, in.fail("hasMinVersion", err)
+	}
+	switch hasMin {
+	case 0:
+	case 1:
+		minVersion, err := readVersion(in, "minVersion")
+		if err != nil {
+			return nil, err
+		}
+		si.MinVersion = &minVersion
+	default:
+		return nil, in.fail("hasMinVersion", fmt.Errorf("illegal boolean value %d", hasMin))
+	}
 
-	return docCount, isCompound == 1, diag, nil
+	if si.DocCount, err = in.ReadInt(); err != nil {
+		return nil, in.fail("docCount", err)
+	}
+	if si.DocCount < 0 {
+		return nil, in.fail("docCount", fmt.Errorf("invalid docCount %d", si.DocCount))
+	}
+	isCompound, err := in.ReadByte()
+	if err != nil {
+		return nil, in.fail("isCompoundFile", err)
+	}
+	si.IsCompoundFile = isCompound == 1
+	// Lucene 9.10+ (Lucene99SegmentInfoFormat) 在 isCompound 之后写入 hasBlocks
+	if si.Format == SI_CODEC_NAME && si.Version.onOrAfter(9, 10) {
+		hasBlocks, err := in.ReadByte()
+		if err != nil {
+			return nil, in.fail("hasBlocks", err)
+		}
+		si.HasBlocks = hasBlocks == 1
+	}
+	if si.Diagnostics, err = in.ReadMapOfStrings(); err != nil {
+		return nil, in.fail("diagnostics", err)
+	}
+	if si.Files, err = in.ReadSetOfStrings(); err != nil {
+		return nil, in.fail("files", err)
+	}
+	if si.Attributes, err = in.ReadMapOfStrings(); err != nil {
+		return nil, in.fail("attributes", err)
+	}
+	// Lucene70 使用固定的排序类型编号，8.6 起改为 SortFieldProvider
+	if si.Format == SI_CODEC_NAME_70 {
+		si.IndexSort, err = readLegacyIndexSort(in)
+	} else {
+		si.IndexSort, err = readIndexSort(in)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return si, nil
 }
+
 // segments_N: Header, LuceneVersion, Version, NameCounter, SegCount, MinSegmentLuceneVersion, <SegName, SegID, SegCodec, DelGen, DeletionCount, FieldInfosGen, DocValuesGen, UpdatesFiles>SegCount, CommitUserData, Footer
 // parseSegmentsFile 解析 segments_N 文件并提取软删除数量
-func parseSegmentsFile(indexDir, segFile string) ([]SegInfoSummary, map[string]string, error) {
-	f, err := os.Open(filepath.Join(indexDir, segFile))
+// 出错时返回已经解析成功的段，以便生成部分报告
+func parseSegmentsFile(indexDir, segFile string) (*SegmentInfos, error) {
+	infos := &SegmentInfos{}
+	in, err := OpenDataInput(filepath.Join(indexDir, segFile))
 	if err != nil {
-		return nil, nil, err
+		return infos, err
 	}
-	defer f.Close()
-	r := bufio.NewReader(f)
+	defer in.Close()
 
 	// 1. 解析 Header
-	magic, _ := readBEInt32(r)
-	if magic != CODEC_MAGIC {
-		return nil, nil, errors.New("bad segments magic")
+	hdr, err := checkIndexHeader(in, SEGMENTS_CODEC_NAME, SEGMENTS_VERSION_70, SEGMENTS_VERSION_CURRENT)
+	if err != nil {
+		return infos, err
+	}
+	formatVer := hdr.Version
+	infos.FormatVersion = formatVer
+	infos.ID = hdr.ID
+	if infos.Generation, err = generationFromSegmentsFileName(segFile); err != nil {
+		return infos, err
 	}
-	readString(r) // "segments"
-	formatVer, _ := readBEInt32(r)
-	readExactly(r, 16) // ID
-	sufLen, _ := readByte(r)
-	readExactly(r, int(sufLen)) // Suffix
 
 	// 2. 解析 Lucene 版本信息
-	readByte(r)
-	readByte(r)
-	readByte(r) // Version Triple
-	readByte(r) // Index Created Version
+	if infos.LuceneVersion, err = readVIntVersion(in, "luceneVersion"); err != nil {
+		return infos, err
+	}
+	if infos.IndexCreatedVersionMajor, err = in.ReadVInt(); err != nil {
+		return infos, in.fail("indexCreatedVersionMajor", err)
+	}
 
 	// 3. 统计信息
-	readBELong(r) // SegInfo Version
-	readVLong(r)  // Counter
-	numSegs, _ := readBEInt32(r)
+	if infos.Version, err = in.ReadBELong(); err != nil {
+		return infos, in.fail("version", err)
+	}
+	// 7.0 格式的 counter 为 int，7.2 起改为 vLong
+	if formatVer >= SEGMENTS_VERSION_72 {
+		if infos.Counter, err = in.ReadVLong(); err != nil {
+			return infos, in.fail("counter", err)
+		}
+	} else {
+		counter, err := in.ReadBEInt()
+		if err != nil {
+			return infos, in.fail("counter", err)
+		}
+		infos.Counter = int64(counter)
+	}
+	numSegs, err := in.ReadBEInt()
+	if err != nil {
+		return infos, in.fail("numSegments", err)
+	}
+	if numSegs < 0 {
+		return infos, in.fail("numSegments", fmt.Errorf("invalid segment count %d", numSegs))
+	}
 
 	if numSegs > 0 {
-		readByte(r)
-		readByte(r)
-		readByte(r) // Min Segment Version
+		minVersion, err := readVIntVersion(in, "minSegmentLuceneVersion")
+		if err != nil {
+			return infos, err
+		}
+		infos.MinSegmentLuceneVersion = &minVersion
 	}
 
-	var summaries []SegInfoSummary
 	for i := 0; i < int(numSegs); i++ {
-		name, _ := readString(r)
-		segIDBytes, _ := readExactly(r, ID_LENGTH) // ID
-		codec, _ := readString(r)
+		field := func(name string) string { return fmt.Sprintf("segments[%d].%s", i, name) }
 
-		// 获取段详细信息
-		maxDoc, isCompound, diag, _ := parseSegmentSI(indexDir, name)
+		name, err := in.ReadString()
+		if err != nil {
+			return infos, in.fail(field("name"), err)
+		}
+		segIDBytes := make([]byte, ID_LENGTH)
+		if err := in.ReadBytes(segIDBytes); err != nil {
+			return infos, in.fail(field("id"), err)
+		}
+		codec, err := in.ReadString()
+		if err != nil {
+			return infos, in.fail(field("codec"), err)
+		}
+
+		// .si 缺失或损坏只影响该段，segments_N 的其余内容不依赖它
+		si, siErr := parseSegmentSI(indexDir, name)
+		files := []string{name + ".si"}
+		if siErr == nil {
+			files = append(files[:0], si.Files...)
+		}
 
 		// 读取删除和软删除计数
-		delGen, _ := readBELong(r)
-		delCount, _ := readBEInt32(r)
-		fieldInfosGen, _ := readBELong(r)
-		dvGen, _ := readBELong(r)
-		softDelCount, _ := readBEInt32(r)
+		delGen, err := in.ReadBELong()
+		if err != nil {
+			return infos, in.fail(field("delGen"), err)
+		}
+		delCount, err := in.ReadBEInt()
+		if err != nil {
+			return infos, in.fail(field("delCount"), err)
+		}
+		fieldInfosGen, err := in.ReadBELong()
+		if err != nil {
+			return infos, in.fail(field("fieldInfosGen"), err)
+		}
+		dvGen, err := in.ReadBELong()
+		if err != nil {
+			return infos, in.fail(field("docValuesGen"), err)
+		}
+		// softDelCount 自 7.4 格式起写入
+		var softDelCount int32
+		if formatVer >= SEGMENTS_VERSION_74 {
+			if softDelCount, err = in.ReadBEInt(); err != nil {
+				return infos, in.fail(field("softDelCount"), err)
+			}
+		}
 
-		// 处理 SCI ID (format > 9)
+		// 处理 SCI ID (8.6 格式起写入)
 		var sciIdBytes []byte
-		if formatVer > 9 {
-			marker, _ := readByte(r)
-			if marker == 1 {
-				sciIdBytes, _ = readExactly(r, ID_LENGTH)
+		if formatVer > SEGMENTS_VERSION_74 {
+			marker, err := in.ReadByte()
+			if err != nil {
+				return infos, in.fail(field("sciIdMarker"), err)
+			}
+			switch marker {
+			case 0:
+			case 1:
+				sciIdBytes = make([]byte, ID_LENGTH)
+				if err := in.ReadBytes(sciIdBytes); err != nil {
+					return infos, in.fail(field("sciId"), err)
+				}
+			default:
+				return infos, in.fail(field("sciIdMarker"), fmt.Errorf("invalid SegmentCommitInfo ID marker %d", marker))
 			}
 		}
 
-		// 跳过文件列表和 DV 更新信息
-		readSetOfStrings(r)
-		numDV, _ := readBEInt32(r)
+		// 字段信息和 DV 更新文件同样属于该段的文件集合
+		fieldInfosFiles, err := in.ReadSetOfStrings()
+		if err != nil {
+			return infos, in.fail(field("fieldInfosFiles"), err)
+		}
+		files = append(files, fieldInfosFiles...)
+		numDV, err := in.ReadBEInt()
+		if err != nil {
+			return infos, in.fail(field("numDVFields"), err)
+		}
+		if numDV < 0 {
+			return infos, in.fail(field("numDVFields"), fmt.Errorf("invalid doc values update count %d", numDV))
+		}
+		var dvUpdates []DocValuesUpdate
 		for j := 0; j < int(numDV); j++ {
-			readBEInt32(r)
-			readSetOfStrings(r)
+			var u DocValuesUpdate
+			if u.FieldNumber, err = in.ReadBEInt(); err != nil {
+				return infos, in.fail(field(fmt.Sprintf("dvUpdateFiles[%d].field", j)), err)
+			}
+			if u.Files, err = in.ReadSetOfStrings(); err != nil {
+				return infos, in.fail(field(fmt.Sprintf("dvUpdateFiles[%d].files", j)), err)
+			}
+			files = append(files, u.Files...)
+			dvUpdates = append(dvUpdates, u)
 		}
+		sort.Slice(dvUpdates, func(a, b int) bool { return dvUpdates[a].FieldNumber < dvUpdates[b].FieldNumber })
+		sort.Strings(files)
 
 		summary := SegInfoSummary{
 			SegName:       name,
 			SegID:         hex.EncodeToString(segIDBytes),
 			SegCodec:      codec,
-			MaxDoc:        maxDoc,
-			Compound:      isCompound,
+			Files:         files,
 			DelGen:        delGen,
 			DelCount:      delCount,
 			FieldInfosGen: fieldInfosGen,
 			DVGen:         dvGen,
 			SoftDelCount:  softDelCount,
-			Extra:         diag,
+			UpdatesFiles:  fieldInfosFiles,
+			DVUpdates:     dvUpdates,
+		}
+		if siErr != nil {
+			summary.siErr = siErr
+			summary.Errors = append(summary.Errors, siErr.Error())
+		} else {
+			summary.SIFormat = si.Format
+			summary.LuceneVersion = si.Version.String()
+			summary.MaxDoc = si.DocCount
+			summary.Compound = si.IsCompoundFile
+			summary.Extra = si.Diagnostics
+			summary.Attributes = si.Attributes
+			summary.IndexSort = si.IndexSort
+			if si.MinVersion != nil {
+				summary.MinVersion = si.MinVersion.String()
+			}
 		}
 		if len(sciIdBytes) > 0 {
 			summary.SciID = hex.EncodeToString(sciIdBytes)
 		}
-		summaries = append(summaries, summary)
+		infos.Segments = append(infos.Segments, summary)
+	}
+
+	if infos.UserData, err = in.ReadMapOfStrings(); err != nil {
+		return infos, in.fail("userData", err)
+	}
+	return infos, nil
+}
+
+// loadFieldInfos 读取段当前的 .fnm：FieldInfosGen > 0 时为索引目录中的更新文件，否则位于 segDir
+func loadFieldInfos(dir, segDir Directory, s *SegInfoSummary) error {
+	s.FieldInfosFile = fieldInfosFileName(s.SegName, s.FieldInfosGen)
+	fnmDir := segDir
+	if s.FieldInfosGen > 0 {
+		fnmDir = dir
+	}
+	fields, err := parseFieldInfos(fnmDir, s.FieldInfosFile)
+	if err != nil {
+		return err
+	}
+	s.Fields = fields
+	return nil
+}
+
+// parseSegmentFiles 解析段内的各格式文件并填充 s
+// 复合段的文件从 .cfs 中读取，按 generation 更新的文件（如 _0_1.fnm）始终位于索引目录中
+// 只有 .cfs 和 .fnm 的错误会中止该段；各格式的错误记录在 s.Errors 中，其余格式照常解析
+func parseSegmentFiles(dir Directory, s *SegInfoSummary) error {
+	segDir := dir
+	if s.Compound {
+		cfs, err := openCompoundDirectory(dir, s.SegName)
+		if err != nil {
+			return err
+		}
+		defer cfs.Close()
+		s.CompoundFiles = cfs.Entries()
+		segDir = cfs
+	}
+
+	if err := loadFieldInfos(dir, segDir, s); err != nil {
+		return err
+	}
+	for i := range s.DVUpdates {
+		u := &s.DVUpdates[i]
+		for _, fi := range s.Fields {
+			if fi.Number == u.FieldNumber {
+				u.FieldName, u.Gen = fi.Name, fi.DocValuesGen
+				break
+			}
+		}
 	}
 
-	userData, _ := readMapOfStrings(r)
-	return summaries, userData, nil
+	record := func(err error) {
+		if err != nil {
+			s.Errors = append(s.Errors, err.Error())
+		}
+	}
+	var err error
+	s.StoredFields, err = parseStoredFields(segDir, s)
+	record(err)
+	s.TermVectors, err = parseTermVectors(segDir, s)
+	record(err)
+	record(parsePostings(segDir, s))
+	record(parsePoints(segDir, s))
+	record(parseDocValues(dir, segDir, s, false))
+	record(parseNorms(segDir, s))
+	record(parseVectors(segDir, s))
+
+	// .liv 与 .fnm 更新文件一样不会写入 .cfs
+	if s.DelGen > 0 {
+		s.LiveDocs, err = parseLiveDocs(dir, s)
+		record(err)
+	}
+
+	record(computeSegmentSizes(dir, s))
+	if s.TermVectors != nil {
+		s.TermVectors.recommend(s.SizeBytes)
+	}
+	return nil
 }
@@ -1,8 +1,13 @@
 package main
 
 import (
+	"bytes"
+	"encoding/binary"
+	"errors"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -29,8 +34,8 @@ func TestGenerationFromSegmentsFileName(t *testing.T) {
 		{
 			name:     "segments",
 			filename: "segments",
-			want:     -1,
-			wantErr:  true,
+			want:     0,
+			wantErr:  false,
 		},
 		{
 			name:     "segments.gen",
@@ -90,23 +95,279 @@ func TestFindLatestSegmentsFile(t *testing.T) {
 }
 
 // TestParseSegmentSI tests the parseSegmentSI function
-// This is a more complex test that would require a real .si file
-// For now, we'll test the error handling
 func TestParseSegmentSI(t *testing.T) {
 	// Test with non-existent file
-	docCount, isCompound, diag, err := parseSegmentSI(".", "non_existent_segment")
+	si, err := parseSegmentSI(".", "non_existent_segment")
 	if err == nil {
 		t.Errorf("parseSegmentSI() should return error for non-existent file")
 	}
+	if si != nil {
+		t.Errorf("parseSegmentSI() = %+v, want nil for error case", si)
+	}
+
+	// A merged 10.3.2 segment with a min version, attributes and an index sort
+	var buf bytes.Buffer
+	writeIndexHeader(&buf, SI_CODEC_NAME, 0, "")
+	for _, v := range []int32{10, 3, 2} {
+		binary.Write(&buf, binary.LittleEndian, v)
+	}
+	buf.WriteByte(1)
+	for _, v := range []int32{9, 12, 1} {
+		binary.Write(&buf, binary.LittleEndian, v)
+	}
+	binary.Write(&buf, binary.LittleEndian, int32(42)) // docCount
+	buf.Write([]byte{1, 0})                            // isCompound, hasBlocks
+	writeVIntBytes(&buf, 1)
+	writeString(&buf, "source")
+	writeString(&buf, "merge")
+	writeVIntBytes(&buf, 2)
+	writeString(&buf, "_0.cfe")
+	writeString(&buf, "_0.cfs")
+	writeVIntBytes(&buf, 1)
+	writeString(&buf, "Lucene90StoredFieldsFormat.mode")
+	writeString(&buf, "BEST_SPEED")
+	writeVIntBytes(&buf, 1)
+	writeString(&buf, SORT_PROVIDER_SORTED_NUMERIC)
+	writeString(&buf, "@timestamp")
+	writeString(&buf, "LONG")
+	binary.Write(&buf, binary.LittleEndian, int32(1)) // reverse
+	binary.Write(&buf, binary.LittleEndian, int32(1)) // selector MAX
+	binary.Write(&buf, binary.LittleEndian, int32(1)) // has missing
+	binary.Write(&buf, binary.LittleEndian, int64(-9))
+
+	tempDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tempDir, "_0.si"), withFooter(buf.Bytes()), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	si, err = parseSegmentSI(tempDir, "_0")
+	if err != nil {
+		t.Fatalf("parseSegmentSI() error = %v", err)
+	}
+	if si.Version.String() != "10.3.2" || si.MinVersion == nil || si.MinVersion.String() != "9.12.1" {
+		t.Errorf("parseSegmentSI() version = %v, min version = %v, want 10.3.2 / 9.12.1", si.Version, si.MinVersion)
+	}
+	if si.DocCount != 42 || !si.IsCompoundFile || si.HasBlocks {
+		t.Errorf("parseSegmentSI() = %+v", si)
+	}
+	if len(si.Files) != 2 || si.Attributes["Lucene90StoredFieldsFormat.mode"] != "BEST_SPEED" || si.Diagnostics["source"] != "merge" {
+		t.Errorf("parseSegmentSI() files = %v, attributes = %v, diagnostics = %v", si.Files, si.Attributes, si.Diagnostics)
+	}
+	want := IndexSortField{Field: "@timestamp", Provider: SORT_PROVIDER_SORTED_NUMERIC, Type: "LONG", Reverse: true, Selector: "MAX", Missing: int64(-9)}
+	if len(si.IndexSort) != 1 || si.IndexSort[0] != want {
+		t.Errorf("parseSegmentSI() index sort = %+v, want %+v", si.IndexSort, want)
+	}
+}
+
+// segmentsHeader returns a segments_N prefix up to and including the segment count
+func segmentsHeader(numSegs int32) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, int32(CODEC_MAGIC))
+	writeVIntBytes(&buf, 8)
+	buf.WriteString("segments")
+	binary.Write(&buf, binary.BigEndian, int32(9))
+	buf.Write(make([]byte, ID_LENGTH))
+	buf.Write([]byte{0})          // suffix
+	buf.Write([]byte{9, 0, 0, 9}) // version triple + index created major
+	binary.Write(&buf, binary.BigEndian, int64(1))
+	writeVLongBytes(&buf, 1)
+	binary.Write(&buf, binary.BigEndian, numSegs)
+	return buf.Bytes()
+}
+
+// writeSegmentEntry writes a segments_N entry of the given format (8 or 9) for a segment without updates
+func writeSegmentEntry(buf *bytes.Buffer, format int32, name string, delCount int32) {
+	writeString(buf, name)
+	buf.Write(make([]byte, ID_LENGTH))
+	writeString(buf, "Lucene103")
+	binary.Write(buf, binary.BigEndian, int64(-1)) // delGen
+	binary.Write(buf, binary.BigEndian, delCount)
+	binary.Write(buf, binary.BigEndian, []int64{-1, -1})
+	if format >= SEGMENTS_VERSION_74 {
+		binary.Write(buf, binary.BigEndian, int32(0)) // softDelCount
+	}
+	writeVIntBytes(buf, 0)                        // fieldInfosFiles
+	binary.Write(buf, binary.BigEndian, int32(0)) // numDVFields
+}
+
+// writeCompoundSI writes a Lucene 10.3.2 <name>.si of a compound segment with docCount documents
+func writeCompoundSI(t *testing.T, dir, name string, docCount int32) {
+	var buf bytes.Buffer
+	writeIndexHeader(&buf, SI_CODEC_NAME, 0, "")
+	binary.Write(&buf, binary.LittleEndian, []int32{10, 3, 2})
+	buf.WriteByte(0) // no min version
+	binary.Write(&buf, binary.LittleEndian, docCount)
+	buf.Write([]byte{1, 0}) // isCompound, hasBlocks
+	writeVIntBytes(&buf, 0)
+	writeVIntBytes(&buf, 3)
+	for _, ext := range []string{".cfe", ".cfs", ".si"} {
+		writeString(&buf, name+ext)
+	}
+	writeVIntBytes(&buf, 0)
+	writeVIntBytes(&buf, 0)
+	if err := os.WriteFile(filepath.Join(dir, name+".si"), withFooter(buf.Bytes()), 0644); err != nil {
+		t.Fatalf("Failed to create %s.si: %v", name, err)
+	}
+}
+
+// TestParseSegmentsFileErrors tests that malformed segments files produce positioned ParseErrors
+func TestParseSegmentsFileErrors(t *testing.T) {
+	badMagic := segmentsHeader(0)
+	badMagic[0] = 0
+
+	badCodec := segmentsHeader(0)
+	copy(badCodec[5:], "segmentz")
+
+	tests := []struct {
+		name       string
+		content    []byte
+		wantField  string
+		wantOffset int64
+	}{
+		{"bad magic", badMagic, "header.magic", 0},
+		{"bad codec", badCodec, "header.codec", 4},
+		{"truncated header", segmentsHeader(0)[:20], "header.id", 17},
+		{"missing min version", segmentsHeader(1), "minSegmentLuceneVersion.major", int64(len(segmentsHeader(1)))},
+		{"missing user data", segmentsHeader(0), "userData", int64(len(segmentsHeader(0)))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(tempDir, "segments_1"), tt.content, 0644); err != nil {
+				t.Fatalf("Failed to create segments file: %v", err)
+			}
+			_, err := parseSegmentsFile(tempDir, "segments_1")
+			var pe *ParseError
+			if !errors.As(err, &pe) {
+				t.Fatalf("parseSegmentsFile() error = %v, want *ParseError", err)
+			}
+			if pe.File != "segments_1" || pe.Field != tt.wantField || pe.Offset != tt.wantOffset {
+				t.Errorf("parseSegmentsFile() error = %+v, want field %s at offset %d", pe, tt.wantField, tt.wantOffset)
+			}
+		})
+	}
+}
+
+// TestParseSegmentsFileFormats tests the Lucene 7.0 and 7.2 layouts and the rejection of pre-7.0 commits
+func TestParseSegmentsFileFormats(t *testing.T) {
+	commit := func(format int32) []byte {
+		var buf bytes.Buffer
+		writeIndexHeader(&buf, SEGMENTS_CODEC_NAME, format, "")
+		buf.Write([]byte{7, 7, 0, 7}) // version triple + index created major
+		binary.Write(&buf, binary.BigEndian, int64(3))
+		binary.Write(&buf, binary.BigEndian, int32(5)) // 7.0 counter is an int
+		binary.Write(&buf, binary.BigEndian, int32(0))
+		writeVIntBytes(&buf, 1)
+		writeString(&buf, "k")
+		writeString(&buf, "v")
+		return buf.Bytes()
+	}
+
+	tempDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tempDir, "segments_1"), commit(SEGMENTS_VERSION_70), 0644); err != nil {
+		t.Fatalf("Failed to create segments file: %v", err)
+	}
+	infos, err := parseSegmentsFile(tempDir, "segments_1")
+	if err != nil {
+		t.Fatalf("parseSegmentsFile() error = %v", err)
+	}
+	if infos.FormatVersion != SEGMENTS_VERSION_70 || infos.UserData["k"] != "v" {
+		t.Errorf("parseSegmentsFile() = %+v, want format 7 with user data", infos)
+	}
+	if infos.LuceneVersion.String() != "7.7.0" || infos.IndexCreatedVersionMajor != 7 || infos.Version != 3 || infos.Counter != 5 || infos.Generation != 1 {
+		t.Errorf("parseSegmentsFile() header = %+v, want 7.7.0, created 7, version 3, counter 5, generation 1", infos)
+	}
+	if infos.MinSegmentLuceneVersion != nil {
+		t.Errorf("parseSegmentsFile() min segment version = %v, want nil without segments", infos.MinSegmentLuceneVersion)
+	}
 
-	// Verify default values
-	if docCount != 0 {
-		t.Errorf("parseSegmentSI() docCount = %v, want 0 for error case", docCount)
+	// 7.2 格式：counter 为 vLong，段条目中还没有 softDelCount
+	var v72 bytes.Buffer
+	writeIndexHeader(&v72, SEGMENTS_CODEC_NAME, SEGMENTS_VERSION_72, "")
+	v72.Write([]byte{7, 3, 1, 7})
+	binary.Write(&v72, binary.BigEndian, int64(3))
+	writeVLongBytes(&v72, 300)
+	binary.Write(&v72, binary.BigEndian, int32(1))
+	v72.Write([]byte{7, 3, 1})
+	writeSegmentEntry(&v72, SEGMENTS_VERSION_72, "_1", 2)
+	writeVIntBytes(&v72, 1)
+	writeString(&v72, "k")
+	writeString(&v72, "v")
+	if err := os.WriteFile(filepath.Join(tempDir, "segments_3"), v72.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to create segments file: %v", err)
+	}
+	writeCompoundSI(t, tempDir, "_1", 7)
+	infos, err = parseSegmentsFile(tempDir, "segments_3")
+	if err != nil {
+		t.Fatalf("parseSegmentsFile() error = %v", err)
+	}
+	if infos.Counter != 300 || len(infos.Segments) != 1 || infos.UserData["k"] != "v" {
+		t.Fatalf("parseSegmentsFile() = %+v, want counter 300, one segment and user data", infos)
+	}
+	if s := infos.Segments[0]; s.DelCount != 2 || s.SoftDelCount != 0 || s.FieldInfosGen != -1 || s.DVGen != -1 || s.MaxDoc != 7 {
+		t.Errorf("parseSegmentsFile() segment = %+v, want 2 deletes and no soft deletes", s)
+	}
+
+	if err := os.WriteFile(filepath.Join(tempDir, "segments_2"), commit(6), 0644); err != nil {
+		t.Fatalf("Failed to create segments file: %v", err)
+	}
+	if _, err := parseSegmentsFile(tempDir, "segments_2"); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("parseSegmentsFile() error = %v, want %v", err, ErrUnsupportedFormat)
+	}
+}
+
+// TestBuildReportPartial tests that a segments_N truncated inside a segment entry yields a partial report alongside the error
+func TestBuildReportPartial(t *testing.T) {
+	tempDir := t.TempDir()
+	var buf bytes.Buffer
+	buf.Write(segmentsHeader(1))
+	buf.Write([]byte{9, 0, 0}) // min segment version
+	writeVIntBytes(&buf, 2)
+	buf.WriteString("_0")
+	buf.Write(make([]byte, ID_LENGTH))
+	writeVIntBytes(&buf, 9)
+	buf.WriteString("Lucene103")
+	if err := os.WriteFile(filepath.Join(tempDir, "segments_1"), buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to create segments file: %v", err)
+	}
+
+	report, err := buildReport(tempDir)
+	if err == nil {
+		t.Fatal("buildReport() should fail when segments_N is truncated")
+	}
+	if report == nil || !report.Partial {
+		t.Fatalf("buildReport() should return a partial report, got %+v", report)
+	}
+}
+
+// TestParseSegmentsFileMissingSI tests that a missing .si is recorded on its segment while later segments and the user data are still read
+func TestParseSegmentsFileMissingSI(t *testing.T) {
+	tempDir := t.TempDir()
+	var buf bytes.Buffer
+	buf.Write(segmentsHeader(2))
+	buf.Write([]byte{9, 0, 0}) // min segment version
+	writeSegmentEntry(&buf, SEGMENTS_VERSION_74, "_0", 0)
+	writeSegmentEntry(&buf, SEGMENTS_VERSION_74, "_1", 0)
+	writeVIntBytes(&buf, 1)
+	writeString(&buf, "translog_uuid")
+	writeString(&buf, "abc")
+
+	if err := os.WriteFile(filepath.Join(tempDir, "segments_1"), buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to create segments file: %v", err)
+	}
+	writeCompoundSI(t, tempDir, "_1", 7)
+
+	infos, err := parseSegmentsFile(tempDir, "segments_1")
+	if err != nil {
+		t.Fatalf("parseSegmentsFile() error = %v", err)
+	}
+	if len(infos.Segments) != 2 || infos.UserData["translog_uuid"] != "abc" {
+		t.Fatalf("parseSegmentsFile() = %d segments, user data %v, want 2 segments and the user data", len(infos.Segments), infos.UserData)
 	}
-	if isCompound != false {
-		t.Errorf("parseSegmentSI() isCompound = %v, want false for error case", isCompound)
+	missing, ok := infos.Segments[0], infos.Segments[1]
+	if missing.siErr == nil || len(missing.Errors) != 1 || !strings.Contains(missing.Errors[0], "_0.si") || !reflect.DeepEqual(missing.Files, []string{"_0.si"}) {
+		t.Errorf("segment _0 = %+v, want the .si error", missing)
 	}
-	if diag != nil {
-		t.Errorf("parseSegmentSI() diag = %v, want nil for error case", diag)
+	if ok.siErr != nil || ok.MaxDoc != 7 || !ok.Compound || ok.LuceneVersion != "10.3.2" || len(ok.Files) != 3 {
+		t.Errorf("segment _1 = %+v, want it decoded from _1.si", ok)
 	}
 }
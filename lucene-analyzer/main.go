@@ -8,6 +8,7 @@ import (
 	"encoding/json"
 	"errors"
 	"flag"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
@@ -74,6 +75,13 @@ type InfoResponse struct {
 	Hostname string `json:"hostname"`
 }
 
+// ErrorResponse is the structured body returned when a shard cannot be fully analyzed
+type ErrorResponse struct {
+	Error         string      `json:"error"`
+	ParseError    *ParseError `json:"parse_error,omitempty"`
+	PartialReport *Report     `json:"partial_report,omitempty"`
+}
+
 // ---------- HTTP handlers ----------
 
 func healthzHandler(w http.ResponseWriter, r *http.Request) {
@@ -114,6 +122,181 @@ func analyzeHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer os.RemoveAll(tempDir)
 
+	indexDir, ok := receiveIndex(w, r, tempDir)
+	if !ok {
+		return
+	}
+
+	// Build the report
+	report, err := buildReport(indexDir)
+	if report != nil && !queryBool(r, "commits") {
+		report.Commits = nil
+	}
+	if err != nil {
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) && report == nil {
+			http.Error(w, "Failed to analyze Lucene shard: "+err.Error(), http.StatusInternalServerError)
+			errorCount.WithLabelValues("build_report").Inc()
+			return
+		}
+		// Report exactly where decoding stopped, together with whatever was parsed before it
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:         "Failed to analyze Lucene shard: " + err.Error(),
+			ParseError:    parseErr,
+			PartialReport: report,
+		})
+		errorCount.WithLabelValues("parse_index").Inc()
+		return
+	}
+
+	// Optionally walk every term for the per-field postings statistics
+	if queryBool(r, "postings_stats") {
+		addPostingsStats(report)
+	}
+
+	// Optionally decode doc values for the bounds .dvm does not record
+	if queryBool(r, "doc_values_range") {
+		addDocValuesRanges(report)
+	}
+
+	// Optionally sample decoded documents of one segment
+	if queryBool(r, "docs") {
+		from, err := queryInt(r, "from", 0)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		size, err := queryInt(r, "size", DOCS_DEFAULT_SIZE)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		report.Docs, err = sampleDocs(report, r.URL.Query().Get("segment"), from, size)
+		if err != nil {
+			http.Error(w, "Failed to sample documents: "+err.Error(), http.StatusBadRequest)
+			errorCount.WithLabelValues("sample_docs").Inc()
+			return
+		}
+	}
+
+	// Optionally list the most frequent terms of one field
+	if field := r.URL.Query().Get("top_terms"); field != "" {
+		size, err := queryInt(r, "top_terms_size", TOP_TERMS_DEFAULT_SIZE)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		report.TopTerms, err = topTerms(report.IndexPath, field, size)
+		if err != nil {
+			http.Error(w, "Failed to collect top terms: "+err.Error(), http.StatusBadRequest)
+			errorCount.WithLabelValues("top_terms").Inc()
+			return
+		}
+	}
+
+	// Return the report as JSON
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		errorCount.WithLabelValues("encode_json").Inc()
+	}
+}
+
+// recoverHandler streams the live documents of an uploaded shard as _bulk NDJSON.
+// Counts of recovered and skipped documents follow in the X-Recover-Stats trailer.
+func recoverHandler(w http.ResponseWriter, r *http.Request) {
+	tempDir, err := os.MkdirTemp("", "lucene-shard-")
+	if err != nil {
+		http.Error(w, "Failed to create temporary directory", http.StatusInternalServerError)
+		return
+	}
+	defer os.RemoveAll(tempDir)
+
+	indexDir, ok := receiveIndex(w, r, tempDir)
+	if !ok {
+		return
+	}
+	// segments_N、.si 和各段的存储字段必须先于响应体打开，否则无法返回错误状态码
+	rr, err := openRecoverReader(indexDir)
+	if err != nil {
+		status := http.StatusBadRequest
+		var parseErr *ParseError
+		if errors.As(err, &parseErr) {
+			status = http.StatusUnprocessableEntity
+		}
+		http.Error(w, "Failed to recover documents: "+err.Error(), status)
+		errorCount.WithLabelValues("recover").Inc()
+		return
+	}
+	defer rr.Close()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Trailer", "X-Recover-Stats, X-Recover-Error")
+	w.WriteHeader(http.StatusOK)
+	stats, err := rr.write(r.URL.Query().Get("index"), w)
+	if err != nil {
+		w.Header().Set("X-Recover-Error", err.Error())
+		errorCount.WithLabelValues("recover").Inc()
+	}
+	if stats != nil {
+		b, _ := json.Marshal(stats)
+		w.Header().Set("X-Recover-Stats", string(b))
+	}
+}
+
+// termsHandler streams the terms of one field of an uploaded shard as NDJSON,
+// merged over all segments in term order and optionally filtered by prefix.
+// The number of terms written follows in the X-Terms-Count trailer.
+func termsHandler(w http.ResponseWriter, r *http.Request) {
+	field := r.URL.Query().Get("field")
+	if field == "" {
+		http.Error(w, "Missing field parameter", http.StatusBadRequest)
+		return
+	}
+	limit, err := queryInt(r, "limit", 0)
+	if err != nil || limit < 0 {
+		http.Error(w, fmt.Sprintf("Invalid limit %q", r.URL.Query().Get("limit")), http.StatusBadRequest)
+		return
+	}
+
+	tempDir, err := os.MkdirTemp("", "lucene-shard-")
+	if err != nil {
+		http.Error(w, "Failed to create temporary directory", http.StatusInternalServerError)
+		return
+	}
+	defer os.RemoveAll(tempDir)
+
+	indexDir, ok := receiveIndex(w, r, tempDir)
+	if !ok {
+		return
+	}
+	terms, err := openFieldTerms(indexDir, field, []byte(r.URL.Query().Get("prefix")))
+	if err != nil {
+		http.Error(w, "Failed to list terms: "+err.Error(), http.StatusBadRequest)
+		errorCount.WithLabelValues("terms").Inc()
+		return
+	}
+	defer terms.Close()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Trailer", "X-Terms-Count, X-Terms-Error")
+	w.WriteHeader(http.StatusOK)
+	n, err := writeTerms(terms, limit, w)
+	if err != nil {
+		w.Header().Set("X-Terms-Error", err.Error())
+		errorCount.WithLabelValues("terms").Inc()
+	}
+	w.Header().Set("X-Terms-Count", strconv.FormatInt(n, 10))
+}
+
+// receiveIndex extracts the uploaded shard archive into tempDir and returns
+// the Lucene index directory inside it. On failure it writes the error
+// response itself and returns false.
+func receiveIndex(w http.ResponseWriter, r *http.Request, tempDir string) (string, bool) {
+	var err error
+
 	// Read the uploaded file
 	var fileContent []byte
 	var fileExt string
@@ -124,14 +307,14 @@ func analyzeHandler(w http.ResponseWriter, r *http.Request) {
 		// Parse multipart form
 		if err := r.ParseMultipartForm(10 << 20); err != nil {
 			http.Error(w, "Failed to parse form", http.StatusBadRequest)
-			return
+			return "", false
 		}
 
 		// Get file from form
 		file, header, err := r.FormFile("archive")
 		if err != nil {
 			http.Error(w, "Failed to get file", http.StatusBadRequest)
-			return
+			return "", false
 		}
 		defer file.Close()
 
@@ -139,7 +322,7 @@ func analyzeHandler(w http.ResponseWriter, r *http.Request) {
 		fileContent, err = io.ReadAll(file)
 		if err != nil {
 			http.Error(w, "Failed to read file", http.StatusInternalServerError)
-			return
+			return "", false
 		}
 
 		// Get file extension
@@ -149,7 +332,7 @@ func analyzeHandler(w http.ResponseWriter, r *http.Request) {
 		fileContent, err = io.ReadAll(r.Body)
 		if err != nil {
 			http.Error(w, "Failed to read request body", http.StatusInternalServerError)
-			return
+			return "", false
 		}
 		defer r.Body.Close()
 
@@ -177,7 +360,7 @@ func analyzeHandler(w http.ResponseWriter, r *http.Request) {
 	// Validate file extension
 	if fileExt != ".zip" && fileExt != ".tar" && fileExt != ".tar.gz" {
 		http.Error(w, "Unsupported file format. Please upload tar, tar.gz, or zip files.", http.StatusBadRequest)
-		return
+		return "", false
 	}
 
 	// Extract the archive
@@ -186,7 +369,7 @@ func analyzeHandler(w http.ResponseWriter, r *http.Request) {
 		reader, err := zip.NewReader(bytes.NewReader(fileContent), int64(len(fileContent)))
 		if err != nil {
 			http.Error(w, "Failed to process zip file", http.StatusBadRequest)
-			return
+			return "", false
 		}
 
 		for _, f := range reader.File {
@@ -219,7 +402,7 @@ func analyzeHandler(w http.ResponseWriter, r *http.Request) {
 			if err != nil {
 				http.Error(w, "Failed to create gzip reader: "+err.Error(), http.StatusBadRequest)
 				errorCount.WithLabelValues("create_gzip_reader").Inc()
-				return
+				return "", false
 			}
 			defer gzipReader.Close()
 			reader = tar.NewReader(gzipReader)
@@ -236,7 +419,7 @@ func analyzeHandler(w http.ResponseWriter, r *http.Request) {
 			if err != nil {
 				http.Error(w, "Failed to read tar header: "+err.Error(), http.StatusInternalServerError)
 				errorCount.WithLabelValues("read_tar_header").Inc()
-				return
+				return "", false
 			}
 
 			path := filepath.Join(tempDir, header.Name)
@@ -245,20 +428,20 @@ func analyzeHandler(w http.ResponseWriter, r *http.Request) {
 				if err := os.MkdirAll(path, 0755); err != nil {
 					http.Error(w, "Failed to create directory: "+err.Error(), http.StatusInternalServerError)
 					errorCount.WithLabelValues("mkdir").Inc()
-					return
+					return "", false
 				}
 			case tar.TypeReg:
 				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
 					http.Error(w, "Failed to create directory: "+err.Error(), http.StatusInternalServerError)
 					errorCount.WithLabelValues("mkdir").Inc()
-					return
+					return "", false
 				}
 
 				dst, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
 				if err != nil {
 					http.Error(w, "Failed to create file: "+err.Error(), http.StatusInternalServerError)
 					errorCount.WithLabelValues("create_file").Inc()
-					return
+					return "", false
 				}
 
 				_, err = io.Copy(dst, reader)
@@ -266,7 +449,7 @@ func analyzeHandler(w http.ResponseWriter, r *http.Request) {
 					dst.Close() // 关闭文件句柄
 					http.Error(w, "Failed to copy file: "+err.Error(), http.StatusInternalServerError)
 					errorCount.WithLabelValues("copy_file").Inc()
-					return
+					return "", false
 				}
 
 				dst.Close() // 直接关闭文件，不要使用defer，否则在循环中会导致文件句柄泄漏
@@ -279,23 +462,28 @@ func analyzeHandler(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		http.Error(w, "Failed to find Lucene index directory: "+err.Error(), http.StatusBadRequest)
 		errorCount.WithLabelValues("find_index_dir").Inc()
-		return
+		return "", false
 	}
+	return indexDir, true
+}
 
-	// Build the report
-	report, err := buildReport(indexDir)
-	if err != nil {
-		http.Error(w, "Failed to analyze Lucene shard: "+err.Error(), http.StatusInternalServerError)
-		errorCount.WithLabelValues("build_report").Inc()
-		return
-	}
+// queryBool reports whether the query parameter name is set to a true value (1, true, ...)
+func queryBool(r *http.Request, name string) bool {
+	v, _ := strconv.ParseBool(r.URL.Query().Get(name))
+	return v
+}
 
-	// Return the report as JSON
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(report); err != nil {
-		errorCount.WithLabelValues("encode_json").Inc()
+// queryInt parses the integer query parameter name, returning def when it is absent
+func queryInt(r *http.Request, name string, def int) (int, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", name, v)
 	}
+	return n, nil
 }
 
 func findLuceneIndexDir(rootDir string) (string, error) {
@@ -365,7 +553,54 @@ func (w *statusResponseWriter) WriteHeader(code int) {
 
 // ---------- main function ----------
 
+// runRecover implements the recover subcommand: it writes the live documents
+// of the index found under the given directory as _bulk NDJSON.
+func runRecover(args []string) int {
+	fs := flag.NewFlagSet("recover", flag.ExitOnError)
+	index := fs.String("index", "", "Target index name written into every action line")
+	output := fs.String("o", "-", "Output file, - for stdout")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s recover [-index name] [-o file] <shard or index directory>\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	indexDir, err := findLuceneIndexDir(fs.Arg(0))
+	if err != nil {
+		log.Printf("Failed to find Lucene index directory: %v", err)
+		return 1
+	}
+	out := os.Stdout
+	if *output != "-" {
+		if out, err = os.Create(*output); err != nil {
+			log.Printf("Failed to create output file: %v", err)
+			return 1
+		}
+		defer out.Close()
+	}
+
+	stats, err := recoverDocuments(indexDir, *index, out)
+	if stats != nil {
+		b, _ := json.MarshalIndent(stats, "", "  ")
+		fmt.Fprintln(os.Stderr, string(b))
+	}
+	if err != nil {
+		log.Printf("Failed to recover documents: %v", err)
+		return 1
+	}
+	return 0
+}
+
 func main() {
+	// Subcommands run offline and exit
+	if len(os.Args) > 1 && os.Args[1] == "recover" {
+		os.Exit(runRecover(os.Args[2:]))
+	}
+
 	// Parse command line flags
 	port := flag.String("port", "8080", "Port to listen on")
 	flag.Parse()
@@ -410,6 +645,8 @@ func main() {
 	http.HandleFunc("/info", metricsMiddleware(infoHandler))
 	http.Handle("/metrics", promhttp.Handler())
 	http.HandleFunc("/analyze", metricsMiddleware(analyzeHandler))
+	http.HandleFunc("/recover", metricsMiddleware(recoverHandler))
+	http.HandleFunc("/terms", metricsMiddleware(termsHandler))
 
 	// Start the server
 	log.Printf("Starting Lucene Shard Analyzer Service on port %s", *port)
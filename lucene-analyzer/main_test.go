@@ -1,11 +1,16 @@
 package main
 
 import (
+	"archive/zip"
 	"bytes"
 	"encoding/binary"
+	"encoding/json"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -160,3 +165,70 @@ func writeVLongBytes(buf *bytes.Buffer, i int64) {
 		buf.Write([]byte{b | 0x80})
 	}
 }
+
+// TestAnalyzeHandlerParseError tests that a malformed index yields a structured 422 response
+func TestAnalyzeHandlerParseError(t *testing.T) {
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	f, err := zw.Create("shard/0/index/segments_1")
+	if err != nil {
+		t.Fatalf("Failed to create zip entry: %v", err)
+	}
+	f.Write(segmentsHeader(1))
+	zw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/analyze", bytes.NewReader(archive.Bytes()))
+	req.Header.Set("Content-Type", "application/zip")
+	rec := httptest.NewRecorder()
+	analyzeHandler(rec, req)
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("analyzeHandler() status = %d, want %d: %s", rec.Code, http.StatusUnprocessableEntity, rec.Body.String())
+	}
+	var body struct {
+		Error      string `json:"error"`
+		ParseError struct {
+			File   string `json:"file"`
+			Offset int64  `json:"offset"`
+			Field  string `json:"field"`
+			Cause  string `json:"cause"`
+		} `json:"parse_error"`
+		PartialReport *Report `json:"partial_report"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode error body: %v", err)
+	}
+	if body.ParseError.File != "segments_1" || body.ParseError.Field != "minSegmentLuceneVersion.major" {
+		t.Errorf("parse_error = %+v, want segments_1/minSegmentLuceneVersion.major", body.ParseError)
+	}
+	if body.ParseError.Offset != int64(len(segmentsHeader(1))) || body.ParseError.Cause == "" {
+		t.Errorf("parse_error = %+v, want offset %d with a cause", body.ParseError, len(segmentsHeader(1)))
+	}
+	if body.PartialReport == nil || !body.PartialReport.Partial {
+		t.Errorf("partial_report = %+v, want a report flagged as partial", body.PartialReport)
+	}
+}
+
+// TestRecoverHandlerParseError tests that a malformed index fails with 422 before any document is streamed
+func TestRecoverHandlerParseError(t *testing.T) {
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	f, err := zw.Create("shard/0/index/segments_1")
+	if err != nil {
+		t.Fatalf("Failed to create zip entry: %v", err)
+	}
+	f.Write(segmentsHeader(1))
+	zw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/recover", bytes.NewReader(archive.Bytes()))
+	req.Header.Set("Content-Type", "application/zip")
+	rec := httptest.NewRecorder()
+	recoverHandler(rec, req)
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("recoverHandler() status = %d, want %d: %s", rec.Code, http.StatusUnprocessableEntity, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct == "application/x-ndjson" || !strings.Contains(rec.Body.String(), "segments_1") {
+		t.Errorf("recoverHandler() content type %q, body %q", ct, rec.Body.String())
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import "fmt"
+
+// ---------- norms metadata (.nvm) per Lucene90NormsFormat ----------
+
+const (
+	NORMS_META_CODEC    = "Lucene90NormsMetadata"
+	NORMS_META_CODEC_80 = "Lucene80NormsMetadata" // Lucene 8.x, big-endian
+	NORMS_META_CODEC_70 = "Lucene70NormsMetadata" // Lucene 7.x, no jump tables; skipped
+	NORMS_VERSION       = 0
+)
+
+// FieldNorms describes the norms of one field.
+type FieldNorms struct {
+	DocCount     int32 `json:"doc_count"`      // documents with a norm
+	BytesPerNorm int8  `json:"bytes_per_norm"` // 0 when every document has the same norm
+	Constant     bool  `json:"constant"`
+	SizeBytes    int64 `json:"size_bytes"` // norms and the docs-with-norms set in .nvd
+}
+
+// parseNorms reads <seg>.nvm and fills fields[].norms. Segments written by
+// Lucene 7 (Elasticsearch 6) use an older entry layout and are skipped.
+// Meta: Header, <FieldNumber(int), DocsWithFieldOffset(long), DocsWithFieldLength(long),
+// JumpTableEntryCount(short), DenseRankPower(byte), NumDocsWithField(int), BytesPerNorm(byte), NormsOffset(long)>*, -1, Footer
+func parseNorms(segDir Directory, s *SegInfoSummary) error {
+	hasNorms := false
+	for _, fi := range s.Fields {
+		hasNorms = hasNorms || fi.HasNorms
+	}
+	if !hasNorms {
+		return nil
+	}
+
+	in, err := segDir.OpenInput(s.SegName + ".nvm")
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	hdr, err := checkIndexHeaderOf(in,
+		HeaderFormat{Codec: NORMS_META_CODEC, MinVersion: NORMS_VERSION, MaxVersion: NORMS_VERSION},
+		HeaderFormat{Codec: NORMS_META_CODEC_80, MinVersion: NORMS_VERSION, MaxVersion: NORMS_VERSION, BigEndian: true},
+		HeaderFormat{Codec: NORMS_META_CODEC_70, MinVersion: NORMS_VERSION, MaxVersion: NORMS_VERSION, BigEndian: true})
+	if err != nil {
+		return err
+	}
+	if hdr.Codec == NORMS_META_CODEC_70 {
+		return nil
+	}
+	dataLength, err := segDir.FileLength(s.SegName + ".nvd")
+	if err != nil {
+		return err
+	}
+
+	byNumber := make(map[int32]int, len(s.Fields))
+	for i, fi := range s.Fields {
+		byNumber[fi.Number] = i
+	}
+	for i := 0; ; i++ {
+		m := &metaReader{in: in, prefix: fmt.Sprintf("fields[%d].", i)}
+		number := m.int("fieldNumber")
+		if m.err != nil {
+			return m.err
+		}
+		if number == -1 {
+			return nil
+		}
+		idx, ok := byNumber[number]
+		if !ok {
+			return in.fail(m.prefix+"fieldNumber", fmt.Errorf("unknown field number %d", number))
+		}
+		fi := &s.Fields[idx]
+		if !fi.HasNorms {
+			return in.fail(m.prefix+"fieldNumber", fmt.Errorf("field %s has no norms", fi.Name))
+		}
+		m.prefix = fi.Name + "."
+		e := docValuesEntry{}
+		m.docsWithField(&e)
+		n := &FieldNorms{DocCount: m.int("numDocsWithField")}
+		n.BytesPerNorm = int8(m.byte("bytesPerNorm"))
+		normsOffset := m.long("normsOffset")
+		if m.err != nil {
+			return m.err
+		}
+		switch n.BytesPerNorm {
+		case 0, 1, 2, 4, 8:
+		default:
+			return in.fail(m.prefix+"bytesPerNorm", fmt.Errorf("invalid bytes per norm %d", n.BytesPerNorm))
+		}
+		if n.DocCount < 0 || n.DocCount > s.MaxDoc {
+			return in.fail(m.prefix+"numDocsWithField", fmt.Errorf("invalid doc count %d (maxDoc %d)", n.DocCount, s.MaxDoc))
+		}
+		// bytesPerNorm 为 0 时 normsOffset 存放的是常量 norm 值
+		n.Constant = n.BytesPerNorm == 0
+		n.SizeBytes = e.dataBytes + int64(n.DocCount)*int64(n.BytesPerNorm)
+		if !n.Constant && normsOffset+int64(n.DocCount)*int64(n.BytesPerNorm) > dataLength-FOOTER_LENGTH {
+			return in.fail(m.prefix+"normsOffset", fmt.Errorf("norms at %d end beyond %s.nvd (%d bytes)", normsOffset, s.SegName, dataLength))
+		}
+		if e.docs.offset >= 0 && e.docs.offset+e.docs.length > dataLength-FOOTER_LENGTH {
+			return in.fail(m.prefix+"docsWithFieldOffset", fmt.Errorf("docs with norms at %d end beyond %s.nvd (%d bytes)", e.docs.offset, s.SegName, dataLength))
+		}
+		fi.Norms = n
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeNormsEntry writes the .nvm entry of one field
+func writeNormsEntry(buf *bytes.Buffer, order binary.ByteOrder, number int32, docsOffset, docsLength int64, numDocs int32, bytesPerNorm byte, normsOffset int64) {
+	binary.Write(buf, order, number)
+	binary.Write(buf, order, []int64{docsOffset, docsLength})
+	binary.Write(buf, order, int16(-1)) // jumpTableEntryCount
+	buf.WriteByte(9)                    // denseRankPower
+	binary.Write(buf, order, numDocs)
+	buf.WriteByte(bytesPerNorm)
+	binary.Write(buf, order, normsOffset)
+}
+
+// TestParseNorms tests constant and per-document norms in Lucene90 and big-endian Lucene80 files
+func TestParseNorms(t *testing.T) {
+	tests := []struct {
+		codec string
+		order binary.ByteOrder
+	}{
+		{NORMS_META_CODEC, binary.LittleEndian},
+		{NORMS_META_CODEC_80, binary.BigEndian},
+	}
+	for _, tt := range tests {
+		var meta bytes.Buffer
+		writeIndexHeader(&meta, tt.codec, NORMS_VERSION, "")
+		writeNormsEntry(&meta, tt.order, 1, -1, 0, 10, 0, 7)   // title: 所有文档的 norm 相同
+		writeNormsEntry(&meta, tt.order, 2, 50, 20, 6, 1, 100) // body: 6 个文档，每个 1 字节
+		binary.Write(&meta, tt.order, int32(-1))
+
+		tempDir := t.TempDir()
+		files := map[string][]byte{"_0.nvm": withFooter(meta.Bytes()), "_0.nvd": make([]byte, 140)}
+		for name, content := range files {
+			if err := os.WriteFile(filepath.Join(tempDir, name), content, 0644); err != nil {
+				t.Fatalf("Failed to create %s: %v", name, err)
+			}
+		}
+		s := &SegInfoSummary{SegName: "_0", MaxDoc: 10, Fields: []FieldInfo{
+			{Name: "id", Number: 0},
+			{Name: "title", Number: 1, HasNorms: true},
+			{Name: "body", Number: 2, HasNorms: true},
+		}}
+		if err := parseNorms(FSDirectory{tempDir}, s); err != nil {
+			t.Fatalf("parseNorms(%s) error = %v", tt.codec, err)
+		}
+		if n := s.Fields[0].Norms; n != nil {
+			t.Errorf("parseNorms(%s) id norms = %+v, want none", tt.codec, n)
+		}
+		if n := s.Fields[1].Norms; n == nil || *n != (FieldNorms{DocCount: 10, Constant: true}) {
+			t.Errorf("parseNorms(%s) title norms = %+v", tt.codec, n)
+		}
+		if n := s.Fields[2].Norms; n == nil || *n != (FieldNorms{DocCount: 6, BytesPerNorm: 1, SizeBytes: 26}) {
+			t.Errorf("parseNorms(%s) body norms = %+v", tt.codec, n)
+		}
+	}
+
+	// norms 超出 .nvd 的数据部分
+	var meta bytes.Buffer
+	writeIndexHeader(&meta, NORMS_META_CODEC, NORMS_VERSION, "")
+	writeNormsEntry(&meta, binary.LittleEndian, 0, -1, 0, 10, 2, 100)
+	binary.Write(&meta, binary.LittleEndian, int32(-1))
+	tempDir := t.TempDir()
+	os.WriteFile(filepath.Join(tempDir, "_0.nvm"), withFooter(meta.Bytes()), 0644)
+	os.WriteFile(filepath.Join(tempDir, "_0.nvd"), make([]byte, 120), 0644)
+	s := &SegInfoSummary{SegName: "_0", MaxDoc: 10, Fields: []FieldInfo{{Name: "body", Number: 0, HasNorms: true}}}
+	if err := parseNorms(FSDirectory{tempDir}, s); err == nil || !strings.Contains(err.Error(), "beyond") {
+		t.Errorf("parseNorms() error = %v, want norms beyond the data file", err)
+	}
+
+	// Lucene 7 的 .nvm 跳过，不影响段的解析
+	meta.Reset()
+	writeIndexHeader(&meta, NORMS_META_CODEC_70, NORMS_VERSION, "")
+	writeNormsEntry(&meta, binary.BigEndian, 0, -1, 0, 10, 1, 0)
+	os.WriteFile(filepath.Join(tempDir, "_0.nvm"), withFooter(meta.Bytes()), 0644)
+	s.Fields[0].Norms = nil
+	if err := parseNorms(FSDirectory{tempDir}, s); err != nil || s.Fields[0].Norms != nil {
+		t.Errorf("parseNorms(%s) = %+v, %v, want skipped", NORMS_META_CODEC_70, s.Fields[0].Norms, err)
+	}
+}
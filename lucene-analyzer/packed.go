@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// ---------- DirectReader / DirectMonotonicReader (org.apache.lucene.util.packed) ----------
+
+// directGet returns value index of a DirectWriter array of bpv-bit values
+// that starts at offset. Values are packed little-endian, bit by bit, so
+// every supported width (1, 2, 4, 8, 12, ..., 64) decodes the same way.
+func directGet(in *DataInput, offset int64, bpv int, index int64) (int64, error) {
+	if bpv == 0 {
+		return 0, nil
+	}
+	bitPos := index * int64(bpv)
+	shift := uint(bitPos & 7)
+	n := (int(shift) + bpv + 7) / 8
+	if err := in.SeekTo(offset + bitPos>>3); err != nil {
+		return 0, err
+	}
+	var b [8]byte
+	if err := in.ReadBytes(b[:n]); err != nil {
+		return 0, err
+	}
+	var v uint64
+	for i := n - 1; i >= 0; i-- {
+		v = v<<8 | uint64(b[i])
+	}
+	v >>= shift
+	if bpv < 64 {
+		v &= 1<<uint(bpv) - 1
+	}
+	return int64(v), nil
+}
+
+// directScan calls fn with the n values of a DirectWriter array that starts
+// at the current position of in. Eight values always fill bpv whole bytes,
+// so the array is read sequentially eight values at a time.
+func directScan(in *DataInput, bpv int, n int64, fn func(v uint64)) error {
+	if bpv < 0 || bpv > 64 {
+		return fmt.Errorf("invalid bits per value %d", bpv)
+	}
+	for ; n > 0 && bpv > 0; n -= 8 {
+		k := int(min(n, 8))
+		b, err := in.next((k*bpv + 7) / 8)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < k; i++ {
+			bitPos := i * bpv
+			var v uint64
+			for j := (bitPos + bpv - 1) >> 3; j >= bitPos>>3; j-- {
+				v = v<<8 | uint64(b[j])
+			}
+			v >>= uint(bitPos & 7)
+			if bpv < 64 {
+				v &= 1<<uint(bpv) - 1
+			}
+			fn(v)
+		}
+	}
+	for ; n > 0; n-- {
+		fn(0)
+	}
+	return nil
+}
+
+type monotonicBlock struct {
+	min    int64
+	avgInc float32
+	offset int64 // relative to the start of the data
+	bpv    int
+}
+
+// DirectMonotonic is the metadata of a DirectMonotonicWriter array: values
+// are stored per block of 2^blockShift as min + avgInc*i + packed delta.
+type DirectMonotonic struct {
+	numValues  int64
+	blockShift uint
+	blocks     []monotonicBlock
+}
+
+// readDirectMonotonicMeta: <Min(long), AvgInc(int float bits), Offset(long), BitsPerValue(byte)>NumBlocks
+func readDirectMonotonicMeta(meta *DataInput, field string, numValues int64, blockShift int32) (*DirectMonotonic, error) {
+	if numValues < 0 {
+		return nil, meta.fail(field, fmt.Errorf("invalid value count %d", numValues))
+	}
+	if blockShift < 2 || blockShift > 22 {
+		return nil, meta.fail(field, fmt.Errorf("invalid block shift %d", blockShift))
+	}
+	m := &DirectMonotonic{numValues: numValues, blockShift: uint(blockShift)}
+	numBlocks := (numValues + 1<<m.blockShift - 1) >> m.blockShift
+	for i := int64(0); i < numBlocks; i++ {
+		var b monotonicBlock
+		var err error
+		if b.min, err = meta.ReadLong(); err != nil {
+			return nil, meta.fail(fmt.Sprintf("%s.blocks[%d].min", field, i), err)
+		}
+		avg, err := meta.ReadInt()
+		if err != nil {
+			return nil, meta.fail(fmt.Sprintf("%s.blocks[%d].avgInc", field, i), err)
+		}
+		b.avgInc = math.Float32frombits(uint32(avg))
+		if b.offset, err = meta.ReadLong(); err != nil {
+			return nil, meta.fail(fmt.Sprintf("%s.blocks[%d].offset", field, i), err)
+		}
+		bpv, err := meta.ReadByte()
+		if err != nil {
+			return nil, meta.fail(fmt.Sprintf("%s.blocks[%d].bitsPerValue", field, i), err)
+		}
+		if bpv > 64 {
+			return nil, meta.fail(fmt.Sprintf("%s.blocks[%d].bitsPerValue", field, i), fmt.Errorf("invalid bits per value %d", bpv))
+		}
+		b.bpv = int(bpv)
+		m.blocks = append(m.blocks, b)
+	}
+	return m, nil
+}
+
+// get returns value index; data is positioned anywhere, base is where the
+// array's data starts within it.
+func (m *DirectMonotonic) get(data *DataInput, base, index int64) (int64, error) {
+	if index < 0 || index >= m.numValues {
+		return 0, fmt.Errorf("index %d out of range [0, %d)", index, m.numValues)
+	}
+	b := m.blocks[index>>m.blockShift]
+	i := index & (1<<m.blockShift - 1)
+	delta, err := directGet(data, base+b.offset, b.bpv, i)
+	if err != nil {
+		return 0, err
+	}
+	return b.min + int64(b.avgInc*float32(i)) + delta, nil
+}
+
+// values decodes the whole array.
+func (m *DirectMonotonic) values(data *DataInput, base int64) ([]int64, error) {
+	out := make([]int64, m.numValues)
+	for i := range out {
+		v, err := m.get(data, base, int64(i))
+		if err != nil {
+			return nil, err
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+// ---------- PackedInts / BlockPackedWriter (org.apache.lucene.util.packed) ----------
+
+// readPackedInts reads n bpv-bit values written by a header-less PackedInts
+// writer in PACKED format: values are packed big-endian, most significant
+// bit first, into ceil(n*bpv/8) bytes.
+func readPackedInts(in *DataInput, n int, bpv int) ([]int64, error) {
+	if bpv < 1 || bpv > 64 {
+		return nil, fmt.Errorf("invalid bits per value %d", bpv)
+	}
+	if n < 0 || int64(n)*int64(bpv) > (in.Length()-in.Pos())*8 {
+		return nil, fmt.Errorf("invalid value count %d", n)
+	}
+	b, err := in.next((n*bpv + 7) / 8)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]int64, n)
+	for i := range values {
+		var v uint64
+		for bit := i * bpv; bit < (i+1)*bpv; bit++ {
+			v = v<<1 | uint64(b[bit>>3]>>(7-uint(bit&7))&1)
+		}
+		values[i] = int64(v)
+	}
+	return values, nil
+}
+
+// readBlockPackedInts reads n values written by a BlockPackedWriter with the
+// given block size. Each block: Token (bitsPerValue<<1 | minIsZero),
+// [zigZag(Min)-1 as vLong], the deltas from Min packed with bitsPerValue bits.
+func readBlockPackedInts(in *DataInput, n, blockSize int) ([]int64, error) {
+	values := make([]int64, 0, n)
+	for len(values) < n {
+		token, err := in.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		bpv := int(token >> 1)
+		if bpv > 64 {
+			return nil, fmt.Errorf("invalid bits per value %d", bpv)
+		}
+		var minValue int64
+		if token&1 == 0 {
+			v, err := in.ReadVLong()
+			if err != nil {
+				return nil, err
+			}
+			minValue = zigZagDecode(v + 1)
+		}
+		k := min(blockSize, n-len(values))
+		deltas := make([]int64, k)
+		if bpv > 0 {
+			if deltas, err = readPackedInts(in, k, bpv); err != nil {
+				return nil, err
+			}
+		}
+		for _, d := range deltas {
+			values = append(values, minValue+d)
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"slices"
+	"testing"
+)
+
+// writeLinearMonotonic writes DirectMonotonic metadata of a single block whose values are exactly min + inc*i
+func writeLinearMonotonic(buf *bytes.Buffer, min int64, inc float32) {
+	le := binary.LittleEndian
+	binary.Write(buf, le, min)
+	binary.Write(buf, le, math.Float32bits(inc))
+	binary.Write(buf, le, int64(0))
+	buf.WriteByte(0)
+}
+
+// packLE packs values little-endian with bpv bits each, as DirectWriter does
+func packLE(values []uint64, bpv int) []byte {
+	out := make([]byte, (len(values)*bpv+7)/8+8)
+	for i, v := range values {
+		for bit := 0; bit < bpv; bit++ {
+			if v>>uint(bit)&1 != 0 {
+				pos := i*bpv + bit
+				out[pos/8] |= 1 << uint(pos%8)
+			}
+		}
+	}
+	return out
+}
+
+// TestDirectGet tests DirectReader decoding for aligned and unaligned widths
+func TestDirectGet(t *testing.T) {
+	for _, bpv := range []int{1, 2, 4, 8, 12, 20, 24, 28, 40, 64} {
+		values := make([]uint64, 9)
+		for i := range values {
+			values[i] = (uint64(i)*0x9E3779B97F4A7C15 + 7) >> uint(64-bpv)
+		}
+		in := newTestInput(append([]byte{0xAA, 0xBB}, packLE(values, bpv)...))
+		for i, want := range values {
+			got, err := directGet(in, 2, bpv, int64(i))
+			if err != nil {
+				t.Fatalf("directGet(bpv=%d, %d) error = %v", bpv, i, err)
+			}
+			if uint64(got) != want {
+				t.Errorf("directGet(bpv=%d, %d) = %#x, want %#x", bpv, i, got, want)
+			}
+		}
+	}
+}
+
+// TestDirectScan tests sequential DirectReader decoding, including a partial last group of eight
+func TestDirectScan(t *testing.T) {
+	for _, bpv := range []int{0, 1, 2, 4, 8, 12, 20, 28, 40, 64} {
+		values := make([]uint64, 13)
+		for i := range values {
+			if bpv > 0 {
+				values[i] = (uint64(i)*0x9E3779B97F4A7C15 + 7) >> uint(64-bpv)
+			}
+		}
+		var got []uint64
+		in := newTestInput(packLE(values, bpv))
+		if err := directScan(in, bpv, int64(len(values)), func(v uint64) { got = append(got, v) }); err != nil {
+			t.Fatalf("directScan(bpv=%d) error = %v", bpv, err)
+		}
+		if len(got) != len(values) {
+			t.Fatalf("directScan(bpv=%d) returned %d values, want %d", bpv, len(got), len(values))
+		}
+		for i, want := range values {
+			if got[i] != want {
+				t.Errorf("directScan(bpv=%d)[%d] = %#x, want %#x", bpv, i, got[i], want)
+			}
+		}
+		if want := int64((len(values)*bpv + 7) / 8); in.Pos() != want {
+			t.Errorf("directScan(bpv=%d) stopped at %d, want %d", bpv, in.Pos(), want)
+		}
+	}
+	if err := directScan(newTestInput(make([]byte, 4)), 8, 5, func(uint64) {}); err == nil {
+		t.Errorf("directScan() past the end succeeded")
+	}
+}
+
+// TestDirectMonotonic tests min + avgInc*i + delta decoding across blocks
+func TestDirectMonotonic(t *testing.T) {
+	var meta bytes.Buffer
+	le := func(v any) { binary.Write(&meta, binary.LittleEndian, v) }
+	// block 0: 10, 20, 31, 40 -> avgInc 10, min 10, deltas 0 0 1 0 in one bit each
+	le(int64(10))
+	le(math.Float32bits(10))
+	le(int64(0))
+	meta.WriteByte(1)
+	// block 1: 50, constant
+	le(int64(50))
+	le(math.Float32bits(0))
+	le(int64(1))
+	meta.WriteByte(0)
+
+	m, err := readDirectMonotonicMeta(newTestInput(meta.Bytes()), "values", 5, 2)
+	if err != nil {
+		t.Fatalf("readDirectMonotonicMeta() error = %v", err)
+	}
+	data := newTestInput([]byte{0xFF, 0x04, 0x00})
+	got, err := m.values(data, 1)
+	if err != nil {
+		t.Fatalf("values() error = %v", err)
+	}
+	want := []int64{10, 20, 31, 40, 50}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("values()[%d] = %d, want %d", i, got[i], want[i])
+		}
+	}
+	if _, err := m.get(data, 1, 5); err == nil {
+		t.Errorf("get() past the last value should fail")
+	}
+}
+
+// TestReadBlockPackedInts tests big-endian PackedInts and BlockPackedWriter blocks with negative and constant values
+func TestReadBlockPackedInts(t *testing.T) {
+	// 5, 3, 4 with 3 bits each: 101 011 100
+	if got, err := readPackedInts(newTestInput([]byte{0xAE, 0x00}), 3, 3); err != nil || !slices.Equal(got, []int64{5, 3, 4}) {
+		t.Errorf("readPackedInts() = %v, %v, want [5 3 4]", got, err)
+	}
+	if _, err := readPackedInts(newTestInput([]byte{0xAE}), 3, 3); err == nil {
+		t.Errorf("readPackedInts() past the end succeeded")
+	}
+
+	// block 0: min -3, deltas 0 2 3 5 in 3 bits；block 1: constant 7
+	in := newTestInput([]byte{3 << 1, 4, 0x09, 0xD0, 0, 13, 0xFF})
+	got, err := readBlockPackedInts(in, 6, 4)
+	if err != nil || !slices.Equal(got, []int64{-3, -1, 0, 2, 7, 7}) {
+		t.Errorf("readBlockPackedInts() = %v, %v, want [-3 -1 0 2 7 7]", got, err)
+	}
+	if in.Pos() != 6 {
+		t.Errorf("readBlockPackedInts() Pos() = %d, want 6", in.Pos())
+	}
+}
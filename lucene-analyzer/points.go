@@ -0,0 +1,249 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net"
+	"time"
+)
+
+// ---------- points metadata (.kdm) per Lucene90PointsFormat / BKDWriter ----------
+
+const (
+	POINTS_META_CODEC    = "Lucene90PointsFormatMeta"
+	POINTS_META_CODEC_86 = "Lucene86PointsFormatMeta" // Lucene 8.6 - 8.11, big-endian
+	POINTS_VERSION_START = 0
+	POINTS_VERSION_CURR  = 1
+
+	BKD_CODEC             = "BKD"
+	BKD_VERSION_META_FILE = 9
+	BKD_VERSION_CURRENT   = 10
+)
+
+// pointTypeBytes maps the Elasticsearch field types rendered from points to
+// their encoded width. Other types (half_float, scaled_float, unsigned_long,
+// geo and range fields) stay hex.
+var pointTypeBytes = map[string]int32{
+	"byte": 4, "short": 4, "integer": 4, "long": 8,
+	"float": 4, "double": 8, "date": 8, "date_nanos": 8, "ip": 16,
+}
+
+// FieldPoints describes the BKD tree of one field.
+type FieldPoints struct {
+	NumDims         int32    `json:"num_dims"`
+	NumIndexDims    int32    `json:"num_index_dims"`
+	BytesPerDim     int32    `json:"bytes_per_dim"`
+	PointCount      int64    `json:"point_count"`
+	DocCount        int32    `json:"doc_count"`
+	NumLeaves       int32    `json:"num_leaves"`
+	MaxPointsInLeaf int32    `json:"max_points_in_leaf"`
+	IndexSizeBytes  int32    `json:"index_size_bytes"`     // packed inner nodes in .kdi
+	ValueType       string   `json:"value_type,omitempty"` // int, long, float, double, date, date_nanos or ip, from the mappings
+	Min             []string `json:"min"`                  // per index dimension, hex unless value_type is set
+	Max             []string `json:"max"`
+
+	minPacked, maxPacked []byte
+}
+
+// parsePoints reads <seg>.kdm and fills fields[].points. Segments written
+// before Lucene 8.6 keep their points in .dim/.dii and are skipped.
+func parsePoints(segDir Directory, s *SegInfoSummary) error {
+	hasPoints := false
+	for _, fi := range s.Fields {
+		hasPoints = hasPoints || fi.PointDimensionCount > 0
+	}
+	if !hasPoints {
+		return nil
+	}
+	if _, err := segDir.FileLength(s.SegName + ".dim"); err == nil {
+		return nil
+	}
+
+	in, err := segDir.OpenInput(s.SegName + ".kdm")
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if _, err := checkIndexHeaderOf(in,
+		HeaderFormat{Codec: POINTS_META_CODEC, MinVersion: POINTS_VERSION_START, MaxVersion: POINTS_VERSION_CURR},
+		HeaderFormat{Codec: POINTS_META_CODEC_86, MinVersion: POINTS_VERSION_START, MaxVersion: POINTS_VERSION_START, BigEndian: true}); err != nil {
+		return err
+	}
+
+	byNumber := make(map[int32]int, len(s.Fields))
+	for i, fi := range s.Fields {
+		byNumber[fi.Number] = i
+	}
+	for {
+		number, err := in.ReadInt()
+		if err != nil {
+			return in.fail("field", err)
+		}
+		if number == -1 {
+			break
+		}
+		idx, ok := byNumber[number]
+		if !ok {
+			return in.fail("field", fmt.Errorf("unknown field number %d", number))
+		}
+		fi := &s.Fields[idx]
+		if fi.Points, err = readBKDMeta(in, fi, s.MaxDoc); err != nil {
+			return err
+		}
+	}
+
+	// .kdi 和 .kdd 的总长度
+	for _, ext := range []string{".kdi", ".kdd"} {
+		want, err := in.ReadLong()
+		if err != nil {
+			return in.fail(ext[1:]+"Length", err)
+		}
+		got, err := segDir.FileLength(s.SegName + ext)
+		if err != nil {
+			return err
+		}
+		if got != want {
+			return in.fail(ext[1:]+"Length", fmt.Errorf("%s%s is %d bytes, expected %d", s.SegName, ext, got, want))
+		}
+	}
+	return nil
+}
+
+// readBKDMeta reads the metadata BKDWriter writes for one field.
+// BKDMeta: Header, NumDims, NumIndexDims, MaxPointsInLeaf, BytesPerDim, NumLeaves, MinPackedValue, MaxPackedValue,
+// PointCount, DocCount, NumIndexBytes, MinLeafBlockFP, IndexStartFP
+func readBKDMeta(in *DataInput, fi *FieldInfo, maxDoc int32) (*FieldPoints, error) {
+	if _, err := checkHeader(in, BKD_CODEC, BKD_VERSION_META_FILE, BKD_VERSION_CURRENT); err != nil {
+		return nil, err
+	}
+	m := &metaReader{in: in, prefix: fi.Name + "."}
+	p := &FieldPoints{}
+	p.NumDims = m.vInt("numDims")
+	p.NumIndexDims = m.vInt("numIndexDims")
+	p.MaxPointsInLeaf = m.vInt("maxPointsInLeaf")
+	p.BytesPerDim = m.vInt("bytesPerDim")
+	p.NumLeaves = m.vInt("numLeaves")
+	if m.err != nil {
+		return nil, m.err
+	}
+	if p.NumDims != fi.PointDimensionCount || p.NumIndexDims != fi.PointIndexDimensionCount || p.BytesPerDim != fi.PointNumBytes {
+		return nil, in.fail(m.prefix+"numDims", fmt.Errorf("BKD tree has %d/%d dimensions of %d bytes, field infos say %d/%d of %d",
+			p.NumDims, p.NumIndexDims, p.BytesPerDim, fi.PointDimensionCount, fi.PointIndexDimensionCount, fi.PointNumBytes))
+	}
+	if p.MaxPointsInLeaf <= 0 || p.NumLeaves <= 0 {
+		return nil, in.fail(m.prefix+"numLeaves", fmt.Errorf("invalid %d leaves of at most %d points", p.NumLeaves, p.MaxPointsInLeaf))
+	}
+
+	minPacked := make([]byte, p.NumIndexDims*p.BytesPerDim)
+	maxPacked := make([]byte, len(minPacked))
+	if err := in.ReadBytes(minPacked); err != nil {
+		return nil, in.fail(m.prefix+"minPackedValue", err)
+	}
+	if err := in.ReadBytes(maxPacked); err != nil {
+		return nil, in.fail(m.prefix+"maxPackedValue", err)
+	}
+	p.PointCount = m.vLong("pointCount")
+	p.DocCount = m.vInt("docCount")
+	p.IndexSizeBytes = m.vInt("numIndexBytes")
+	m.long("minLeafBlockFP")
+	m.long("indexStartFP")
+	if m.err != nil {
+		return nil, m.err
+	}
+	if p.DocCount <= 0 || p.DocCount > maxDoc || p.PointCount < int64(p.DocCount) {
+		return nil, in.fail(m.prefix+"docCount", fmt.Errorf("invalid docCount %d for %d points (maxDoc %d)", p.DocCount, p.PointCount, maxDoc))
+	}
+	if p.PointCount > int64(p.NumLeaves)*int64(p.MaxPointsInLeaf) {
+		return nil, in.fail(m.prefix+"pointCount", fmt.Errorf("%d points do not fit in %d leaves of %d", p.PointCount, p.NumLeaves, p.MaxPointsInLeaf))
+	}
+
+	p.minPacked, p.maxPacked = minPacked, maxPacked
+	p.setValueType(inferPointType(fi.Name, p.NumDims, p.BytesPerDim))
+	return p, nil
+}
+
+// inferPointType returns the type of a point field when the encoding alone
+// determines it: 16 byte values are IP addresses and _seq_no is a long.
+// 4 and 8 byte values may be integers, floats or dates and are typed later
+// from the mappings, see applyMappedPointTypes.
+func inferPointType(name string, numDims, bytesPerDim int32) string {
+	switch {
+	case numDims != 1:
+		return ""
+	case bytesPerDim == 16:
+		return "ip"
+	case name == "_seq_no" && bytesPerDim == 8:
+		return "long"
+	}
+	return ""
+}
+
+// setValueType renders min and max per index dimension as valueType.
+func (p *FieldPoints) setValueType(valueType string) {
+	p.ValueType, p.Min, p.Max = valueType, nil, nil
+	n := int(p.BytesPerDim)
+	for d := 0; d < int(p.NumIndexDims); d++ {
+		p.Min = append(p.Min, pointValueString(valueType, p.minPacked[d*n:(d+1)*n]))
+		p.Max = append(p.Max, pointValueString(valueType, p.maxPacked[d*n:(d+1)*n]))
+	}
+}
+
+// applyMappedPointTypes types the single-dimension points of every segment
+// from the field types in the index mappings, when the mapped type's
+// encoding matches the points' width.
+func applyMappedPointTypes(segments []SegInfoSummary, mappings map[string]any) {
+	for i := range segments {
+		for j := range segments[i].Fields {
+			fi := &segments[i].Fields[j]
+			p := fi.Points
+			if p == nil || p.NumDims != 1 || p.ValueType != "" {
+				continue
+			}
+			typ := mappedFieldType(mappings, fi.Name)
+			if pointTypeBytes[typ] != p.BytesPerDim {
+				continue
+			}
+			switch typ {
+			case "byte", "short", "integer":
+				typ = "int"
+			}
+			p.setValueType(typ)
+		}
+	}
+}
+
+func pointValueString(valueType string, b []byte) string {
+	switch valueType {
+	case "int":
+		return fmt.Sprint(sortableInt(b))
+	case "float":
+		v := sortableInt(b)
+		v ^= v >> 31 & math.MaxInt32
+		return fmt.Sprint(math.Float32frombits(uint32(v)))
+	case "long":
+		return fmt.Sprint(sortableLong(b))
+	case "double":
+		v := sortableLong(b)
+		v ^= v >> 63 & math.MaxInt64
+		return fmt.Sprint(math.Float64frombits(uint64(v)))
+	case "date":
+		return time.UnixMilli(sortableLong(b)).UTC().Format("2006-01-02T15:04:05.000Z")
+	case "date_nanos":
+		return time.Unix(0, sortableLong(b)).UTC().Format("2006-01-02T15:04:05.000000000Z")
+	case "ip":
+		return net.IP(b).String()
+	}
+	return hex.EncodeToString(b)
+}
+
+// sortableInt decodes NumericUtils.intToSortableBytes.
+func sortableInt(b []byte) int32 {
+	return int32(binary.BigEndian.Uint32(b) ^ 1<<31)
+}
+
+// sortableLong decodes NumericUtils.longToSortableBytes.
+func sortableLong(b []byte) int64 {
+	return int64(binary.BigEndian.Uint64(b) ^ 1<<63)
+}
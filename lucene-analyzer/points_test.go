@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// writeBKDMeta writes the per-field BKD metadata for a single-dimension field
+func writeBKDMeta(buf *bytes.Buffer, field int32, minPacked, maxPacked []byte, pointCount, docCount int) {
+	binary.Write(buf, binary.LittleEndian, field)
+	binary.Write(buf, binary.BigEndian, int32(CODEC_MAGIC))
+	writeString(buf, BKD_CODEC)
+	binary.Write(buf, binary.BigEndian, int32(BKD_VERSION_CURRENT))
+	writeVIntBytes(buf, 1)   // numDims
+	writeVIntBytes(buf, 1)   // numIndexDims
+	writeVIntBytes(buf, 512) // maxPointsInLeaf
+	writeVIntBytes(buf, len(minPacked))
+	writeVIntBytes(buf, 1) // numLeaves
+	buf.Write(minPacked)
+	buf.Write(maxPacked)
+	writeVLongBytes(buf, int64(pointCount))
+	writeVIntBytes(buf, docCount)
+	writeVIntBytes(buf, 0) // numIndexBytes
+	binary.Write(buf, binary.LittleEndian, []int64{50, 51})
+}
+
+func sortableLongBytes(v int64) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(v)^1<<63)
+}
+
+// TestParsePoints tests .kdm parsing for a date field and an ip field
+func TestParsePoints(t *testing.T) {
+	var buf bytes.Buffer
+	writeIndexHeader(&buf, POINTS_META_CODEC, POINTS_VERSION_CURR, "")
+	writeBKDMeta(&buf, 1, sortableLongBytes(1700000000000), sortableLongBytes(1700000060000), 12, 10)
+	writeBKDMeta(&buf, 2, net.ParseIP("10.0.0.1").To16(), net.ParseIP("10.0.3.255").To16(), 10, 10)
+	binary.Write(&buf, binary.LittleEndian, int32(-1))
+	binary.Write(&buf, binary.LittleEndian, []int64{100, 200})
+
+	tempDir := t.TempDir()
+	files := map[string][]byte{"_0.kdm": withFooter(buf.Bytes()), "_0.kdi": make([]byte, 100), "_0.kdd": make([]byte, 200)}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tempDir, name), content, 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+	s := &SegInfoSummary{SegName: "_0", MaxDoc: 10, Fields: []FieldInfo{
+		{Name: "message", Number: 0},
+		{Name: "@timestamp", Number: 1, PointDimensionCount: 1, PointIndexDimensionCount: 1, PointNumBytes: 8},
+		{Name: "client_ip", Number: 2, PointDimensionCount: 1, PointIndexDimensionCount: 1, PointNumBytes: 16},
+	}}
+	if err := parsePoints(FSDirectory{tempDir}, s); err != nil {
+		t.Fatalf("parsePoints() error = %v", err)
+	}
+
+	ts, ip := s.Fields[1].Points, s.Fields[2].Points
+	if ts == nil || ip == nil || s.Fields[0].Points != nil {
+		t.Fatalf("parsePoints() points = %v, %v, %v", s.Fields[0].Points, ts, ip)
+	}
+	// 没有映射时 8 字节的值不确定类型，以十六进制输出
+	if ts.PointCount != 12 || ts.DocCount != 10 || ts.MaxPointsInLeaf != 512 || ts.ValueType != "" ||
+		!slices.Equal(ts.Min, []string{"8000018bcfe56800"}) || !slices.Equal(ts.Max, []string{"8000018bcfe65260"}) {
+		t.Errorf("@timestamp points = %+v", ts)
+	}
+	applyMappedPointTypes([]SegInfoSummary{*s}, map[string]any{"_doc": map[string]any{"properties": map[string]any{
+		"@timestamp": map[string]any{"type": "date"},
+	}}})
+	if ts.ValueType != "date" ||
+		!slices.Equal(ts.Min, []string{"2023-11-14T22:13:20.000Z"}) || !slices.Equal(ts.Max, []string{"2023-11-14T22:14:20.000Z"}) {
+		t.Errorf("@timestamp points with mappings = %+v", ts)
+	}
+	if ip.ValueType != "ip" || !slices.Equal(ip.Min, []string{"10.0.0.1"}) || !slices.Equal(ip.Max, []string{"10.0.3.255"}) {
+		t.Errorf("client_ip points = %+v", ip)
+	}
+
+	// 维度与 .fnm 不一致
+	s.Fields[2].PointNumBytes = 4
+	if err := parsePoints(FSDirectory{tempDir}, s); err == nil || !strings.Contains(err.Error(), "dimensions") {
+		t.Errorf("parsePoints() error = %v, want a dimensions mismatch", err)
+	}
+}
+
+// TestInferPointType tests the types known from the encoding alone
+func TestInferPointType(t *testing.T) {
+	tests := []struct {
+		name                 string
+		numDims, bytesPerDim int32
+		want                 string
+	}{
+		{"client_ip", 1, 16, "ip"},
+		{"_seq_no", 1, 8, "long"},
+		{"price", 1, 8, ""},
+		{"count", 1, 4, ""},
+		{"location", 2, 4, ""},
+	}
+	for _, tt := range tests {
+		if got := inferPointType(tt.name, tt.numDims, tt.bytesPerDim); got != tt.want {
+			t.Errorf("inferPointType(%s, %d, %d) = %q, want %q", tt.name, tt.numDims, tt.bytesPerDim, got, tt.want)
+		}
+	}
+}
+
+// TestPointValueString tests rendering of 1-dimensional point values per type
+func TestPointValueString(t *testing.T) {
+	sortableInt := func(v int32) []byte { return binary.BigEndian.AppendUint32(nil, uint32(v)^1<<31) }
+	tests := []struct {
+		valueType string
+		min, max  []byte
+		wantMin   string
+		wantMax   string
+	}{
+		{"int", sortableInt(-5), sortableInt(24), "-5", "24"},
+		{"float", sortableInt(0x3F800000), sortableInt(0x40490FDB), "1", "3.1415927"},
+		{"long", sortableLongBytes(1), sortableLongBytes(10218), "1", "10218"},
+		{"double", sortableLongBytes(0x3FF8000000000000), sortableLongBytes(0x4059000000000000), "1.5", "100"},
+		{"date_nanos", sortableLongBytes(1700000000123456789), sortableLongBytes(1700000000123456789),
+			"2023-11-14T22:13:20.123456789Z", "2023-11-14T22:13:20.123456789Z"},
+		{"ip", net.ParseIP("::1"), net.ParseIP("fe80::1"), "::1", "fe80::1"},
+		{"", []byte{0x80, 0x01}, []byte{0x80, 0x02}, "8001", "8002"},
+	}
+	for _, tt := range tests {
+		if lo, hi := pointValueString(tt.valueType, tt.min), pointValueString(tt.valueType, tt.max); lo != tt.wantMin || hi != tt.wantMax {
+			t.Errorf("pointValueString(%q) = %s, %s, want %s, %s", tt.valueType, lo, hi, tt.wantMin, tt.wantMax)
+		}
+	}
+}
+
+// TestApplyMappedPointTypes tests typing points from the mappings, including
+// ranges whose encoding alone would suggest another type
+func TestApplyMappedPointTypes(t *testing.T) {
+	sortableInt := func(v int32) []byte { return binary.BigEndian.AppendUint32(nil, uint32(v)^1<<31) }
+	sortableDouble := func(v float64) []byte { return sortableLongBytes(int64(math.Float64bits(v))) }
+	points := func(bytesPerDim int32, min, max []byte) *FieldPoints {
+		p := &FieldPoints{NumDims: 1, NumIndexDims: 1, BytesPerDim: bytesPerDim, minPacked: min, maxPacked: max}
+		p.setValueType("")
+		return p
+	}
+	segments := []SegInfoSummary{{SegName: "_0", Fields: []FieldInfo{
+		{Name: "rating", Points: points(8, sortableDouble(0), sortableDouble(5))},
+		{Name: "metrics.bytes", Points: points(4, sortableInt(10000000), sortableInt(50000000))},
+		{Name: "metrics.ratio", Points: points(4, sortableInt(0), sortableInt(0x3F800000))},
+		{Name: "title.length", Points: points(4, sortableInt(3), sortableInt(120))},
+		{Name: "price", Points: points(4, sortableInt(0), sortableInt(1000))}, // half_float 的宽度是 2 字节，不匹配
+		{Name: "unmapped", Points: points(8, sortableLongBytes(0), sortableLongBytes(7))},
+	}}}
+	mappings := map[string]any{"_doc": map[string]any{"properties": map[string]any{
+		"rating": map[string]any{"type": "double"},
+		"metrics": map[string]any{"properties": map[string]any{
+			"bytes": map[string]any{"type": "integer"},
+			"ratio": map[string]any{"type": "float"},
+		}},
+		"title": map[string]any{"type": "text", "fields": map[string]any{
+			"length": map[string]any{"type": "short"},
+		}},
+		"price": map[string]any{"type": "half_float"},
+	}}}
+	applyMappedPointTypes(segments, mappings)
+
+	tests := []struct {
+		valueType string
+		min, max  string
+	}{
+		{"double", "0", "5"},
+		{"int", "10000000", "50000000"},
+		{"float", "0", "1"},
+		{"int", "3", "120"},
+		{"", "80000000", "800003e8"},
+		{"", "8000000000000000", "8000000000000007"},
+	}
+	for i, tt := range tests {
+		fi := segments[0].Fields[i]
+		if p := fi.Points; p.ValueType != tt.valueType || !slices.Equal(p.Min, []string{tt.min}) || !slices.Equal(p.Max, []string{tt.max}) {
+			t.Errorf("%s points = %s %v %v, want %s [%s] [%s]", fi.Name, p.ValueType, p.Min, p.Max, tt.valueType, tt.min, tt.max)
+		}
+	}
+}
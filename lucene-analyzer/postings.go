@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ---------- postings (.doc/.pos/.pay) per field ----------
+
+// postingsMetaCodecs maps the postings formats that record the length of
+// .doc/.pos/.pay in a .psm file (Lucene 9.12+) to its header codec.
+var postingsMetaCodecs = map[string]string{
+	"Lucene912": "Lucene912PostingsWriterMeta",
+	"Lucene101": "Lucene101PostingsWriterMeta",
+	"Lucene103": "Lucene103PostingsWriterMeta",
+}
+
+var postingsExtensions = []string{".doc", ".pos", ".pay"}
+
+// postingsFiles are the fields sharing the .doc/.pos/.pay of one postings format.
+type postingsFiles struct {
+	format string
+	base   string
+	starts []postingsStart
+}
+
+// postingsStart is where the postings of a field start in .doc, .pos and .pay,
+// -1 for the files the field does not write to.
+type postingsStart struct {
+	field string
+	p     *FieldPostings
+	fp    [3]int64
+}
+
+// addPostingsStats runs parsePostingsStats on every segment of rep. It reads
+// every term of every field, so /analyze only does it with ?postings_stats=true;
+// errors are recorded on the segment.
+func addPostingsStats(rep *Report) {
+	dir := FSDirectory{rep.IndexPath}
+	for i := range rep.Segments {
+		s := &rep.Segments[i]
+		if s.siErr != nil {
+			continue // 已记录在 s.Errors 中
+		}
+		segDir, err := openSegmentDirectory(dir, s)
+		if err == nil {
+			err = parsePostingsStats(segDir, s)
+			segDir.Close()
+		}
+		if err != nil {
+			s.Errors = append(s.Errors, err.Error())
+		}
+	}
+}
+
+// parsePostingsStats walks the terms of every field read by parsePostings to
+// count singletons, and splits .doc/.pos/.pay among the fields using the file
+// pointers of each field's first term.
+func parsePostingsStats(segDir Directory, s *SegInfoSummary) error {
+	var groups []*postingsFiles
+	byBase := map[string]*postingsFiles{}
+	for i := range s.Fields {
+		fi := &s.Fields[i]
+		if fi.Postings == nil {
+			continue
+		}
+		format := fi.Attributes[PER_FIELD_POSTINGS_FORMAT_ATTR]
+		base := s.SegName + "_" + format + "_" + fi.Attributes[PER_FIELD_POSTINGS_SUFFIX_ATTR]
+		g, ok := byBase[base]
+		if !ok {
+			g = &postingsFiles{format: format, base: base}
+			byBase[base] = g
+			groups = append(groups, g)
+		}
+		start, err := scanFieldPostings(segDir, s, fi)
+		if err != nil {
+			return err
+		}
+		g.starts = append(g.starts, start)
+	}
+	for _, g := range groups {
+		if err := checkPostingsLengths(segDir, g); err != nil {
+			return err
+		}
+		if err := g.split(segDir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanFieldPostings fills the per-field postings statistics of fi.
+func scanFieldPostings(segDir Directory, s *SegInfoSummary, fi *FieldInfo) (postingsStart, error) {
+	p := fi.Postings
+	p.Stats = &PostingsStats{}
+	start := postingsStart{field: fi.Name, p: p, fp: [3]int64{-1, -1, -1}}
+	e, err := openTermsEnum(segDir, s, fi, nil)
+	if err != nil {
+		return start, err
+	}
+	defer e.Close()
+	for {
+		ok, err := e.next()
+		if err != nil {
+			return start, err
+		}
+		if !ok {
+			break
+		}
+		if e.count == 1 {
+			if err := e.readPostingsStart(&start); err != nil {
+				return start, err
+			}
+		}
+		if e.docFreq == 1 {
+			p.Stats.SingletonTerms++
+		}
+	}
+	p.Stats.SingletonPercent = percentOf(p.Stats.SingletonTerms, p.NumTerms)
+	if p.Stats.SingletonTerms == p.NumTerms {
+		// 单文档的 term 把 docID 内联在 term 元数据中，字段不写 .doc
+		start.fp[0] = -1
+	}
+	return start, nil
+}
+
+// readPostingsStart decodes the metadata of the field's first term. Descending
+// from the root block always lands on the first entry of a block, and the
+// first term of a block has absolute file pointers rather than deltas.
+// TermMeta: DocStartFP<<1, [SingletonDocID], [PosStartFP, [PayStartFP]], ...
+func (e *termsEnum) readPostingsStart(start *postingsStart) error {
+	f := &e.frames[len(e.frames)-1]
+	m := &metaReader{in: f.meta, prefix: e.field + ".firstTerm."}
+	code := m.vLong("docStartFP")
+	if m.err == nil && code&1 != 0 {
+		return f.meta.fail(m.prefix+"docStartFP", fmt.Errorf("first term of field %s has a relative doc start", e.field))
+	}
+	start.fp[0] = code >> 1
+	if e.docFreq == 1 {
+		m.vInt("singletonDocID")
+	}
+	if start.p.Positions {
+		start.fp[1] = m.vLong("posStartFP")
+		if start.p.Payloads || start.p.Offsets {
+			start.fp[2] = m.vLong("payStartFP")
+		}
+	}
+	return m.err
+}
+
+// split assigns each field the bytes of .doc/.pos/.pay from its start up to
+// the start of the next field; the fields of a format are written one after
+// the other in field name order and the last one ends before the footer.
+// Fields whose terms all have docFreq 1 write nothing to .doc and are left
+// out of its split; when several fields start at the same offset, the span
+// goes to the one written last.
+func (g *postingsFiles) split(segDir Directory) error {
+	for i, ext := range postingsExtensions {
+		var starts []postingsStart
+		for _, st := range g.starts {
+			if st.fp[i] >= 0 {
+				starts = append(starts, st)
+			}
+		}
+		if len(starts) == 0 {
+			continue
+		}
+		length, err := segDir.FileLength(g.base + ext)
+		if err != nil {
+			return err
+		}
+		sort.SliceStable(starts, func(a, b int) bool {
+			if starts[a].fp[i] != starts[b].fp[i] {
+				return starts[a].fp[i] < starts[b].fp[i]
+			}
+			return starts[a].field < starts[b].field
+		})
+		for j, st := range starts {
+			end := length - FOOTER_LENGTH
+			if j+1 < len(starts) {
+				end = starts[j+1].fp[i]
+			}
+			if end < st.fp[i] {
+				return fmt.Errorf("%s%s: postings start at %d, beyond the end of the file (%d bytes)", g.base, ext, st.fp[i], length)
+			}
+			switch ext {
+			case ".doc":
+				st.p.Stats.DocBytes = end - st.fp[i]
+			case ".pos":
+				st.p.Stats.PosBytes = end - st.fp[i]
+			case ".pay":
+				st.p.Stats.PayBytes = end - st.fp[i]
+			}
+		}
+	}
+	for _, st := range g.starts {
+		ps := st.p.Stats
+		ps.SizeBytes = ps.DocBytes + ps.PosBytes + ps.PayBytes
+	}
+	return nil
+}
+
+// checkPostingsLengths compares the lengths recorded in .psm with the postings files.
+// .psm: Header, MaxNumImpactsAtLevel0, MaxImpactNumBytesAtLevel0, MaxNumImpactsAtLevel1, MaxImpactNumBytesAtLevel1, DocLength, [PosLength, [PayLength]], Footer
+func checkPostingsLengths(segDir Directory, g *postingsFiles) error {
+	codec, ok := postingsMetaCodecs[g.format]
+	if !ok {
+		return nil
+	}
+	in, err := segDir.OpenInput(g.base + ".psm")
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if _, err := checkIndexHeader(in, codec, 0, 1); err != nil {
+		return err
+	}
+	for _, field := range []string{"maxNumImpactsAtLevel0", "maxImpactNumBytesAtLevel0", "maxNumImpactsAtLevel1", "maxImpactNumBytesAtLevel1"} {
+		if _, err := in.ReadInt(); err != nil {
+			return in.fail(field, err)
+		}
+	}
+	// .pos 和 .pay 只在段中有字段需要时才写入
+	for _, ext := range postingsExtensions {
+		if ext != ".doc" && in.Pos() >= in.Length()-FOOTER_LENGTH {
+			break
+		}
+		want, err := in.ReadLong()
+		if err != nil {
+			return in.fail(ext[1:]+"Length", err)
+		}
+		got, err := segDir.FileLength(g.base + ext)
+		if err != nil {
+			return err
+		}
+		if got != want {
+			return in.fail(ext[1:]+"Length", fmt.Errorf("%s%s is %d bytes, expected %d", g.base, ext, got, want))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestParsePostingsStats tests singleton counts and the split of .doc/.pos/.pay between two fields,
+// where the singleton-only _id starts at the same .doc offset as body
+func TestParsePostingsStats(t *testing.T) {
+	const suffix = "Lucene912_0"
+	var doc, pos, pay bytes.Buffer
+	writeIndexHeader(&doc, "Lucene912PostingsWriterDoc", 0, suffix)
+	writeIndexHeader(&pos, "Lucene912PostingsWriterPos", 0, suffix)
+	writeIndexHeader(&pay, "Lucene912PostingsWriterPay", 0, suffix)
+	bodyDoc, bodyPos, bodyPay := int64(doc.Len()), int64(pos.Len()), int64(pay.Len())
+	doc.Write(make([]byte, 30))
+	pos.Write(make([]byte, 20))
+	pay.Write(make([]byte, 10))
+
+	// body: a (docFreq 2, totalTermFreq 5), b 和 c 只出现一次；_id: x, y 都只出现一次，不写 .doc
+	var tim, bodyMeta, idMeta bytes.Buffer
+	writeIndexHeader(&tim, "BlockTreeTermsDict", TERMS_VERSION, suffix)
+	writeVLongBytes(&bodyMeta, bodyDoc<<1)
+	writeVLongBytes(&bodyMeta, bodyPos)
+	writeVLongBytes(&bodyMeta, bodyPay)
+	bodyMeta.Write([]byte{0x01, 0x02, 0x03}) // b 和 c 的元数据，不读取
+	bodyRoot := writeTermsBlock(&tim, []termsBlockEntry{{suffix: "a"}, {suffix: "b"}, {suffix: "c"}}, []byte{2 << 1, 3, 2<<1 | 1}, bodyMeta.Bytes(), true)
+	writeVLongBytes(&idMeta, bodyDoc<<1)
+	writeVIntBytes(&idMeta, 3) // singletonDocID
+	idMeta.WriteByte(0x03)
+	idRoot := writeTermsBlock(&tim, []termsBlockEntry{{suffix: "x"}, {suffix: "y"}}, []byte{2<<1 | 1}, idMeta.Bytes(), true)
+
+	files := map[string][]byte{
+		"_0_" + suffix + ".tim": withFooter(tim.Bytes()),
+		"_0_" + suffix + ".doc": withFooter(doc.Bytes()),
+		"_0_" + suffix + ".pos": withFooter(pos.Bytes()),
+		"_0_" + suffix + ".pay": withFooter(pay.Bytes()),
+	}
+	psm := func(docLength int) []byte {
+		var buf bytes.Buffer
+		writeIndexHeader(&buf, postingsMetaCodecs["Lucene912"], 0, suffix)
+		binary.Write(&buf, binary.LittleEndian, []int32{0, 0, 0, 0})
+		binary.Write(&buf, binary.LittleEndian, []int64{int64(docLength), int64(len(files["_0_"+suffix+".pos"])), int64(len(files["_0_"+suffix+".pay"]))})
+		return withFooter(buf.Bytes())
+	}
+	files["_0_"+suffix+".psm"] = psm(len(files["_0_"+suffix+".doc"]))
+	tempDir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tempDir, name), content, 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+
+	attrs := map[string]string{PER_FIELD_POSTINGS_FORMAT_ATTR: "Lucene912", PER_FIELD_POSTINGS_SUFFIX_ATTR: "0"}
+	newSegment := func() *SegInfoSummary {
+		return &SegInfoSummary{SegName: "_0", MaxDoc: 10, Fields: []FieldInfo{
+			{Name: "_id", Number: 0, IndexOptions: "DOCS", Attributes: attrs,
+				Postings: &FieldPostings{NumTerms: 2, SumTotalTermFreq: 2, SumDocFreq: 2, DocCount: 2, rootBlockFP: idRoot, rootNodeFP: -1}},
+			{Name: "body", Number: 1, IndexOptions: "DOCS_AND_FREQS_AND_POSITIONS_AND_OFFSETS", Attributes: attrs,
+				Postings: &FieldPostings{NumTerms: 3, SumTotalTermFreq: 7, SumDocFreq: 4, DocCount: 3, Positions: true, Offsets: true, rootBlockFP: bodyRoot, rootNodeFP: -1}},
+		}}
+	}
+	s := newSegment()
+	if err := parsePostingsStats(FSDirectory{tempDir}, s); err != nil {
+		t.Fatalf("parsePostingsStats() error = %v", err)
+	}
+	if p := s.Fields[0].Postings.Stats; p == nil || *p != (PostingsStats{SingletonTerms: 2, SingletonPercent: 100}) {
+		t.Errorf("_id postings stats = %+v", p)
+	}
+	if p := s.Fields[1].Postings.Stats; p == nil || *p != (PostingsStats{SingletonTerms: 2, SingletonPercent: 66.67, DocBytes: 30, PosBytes: 20, PayBytes: 10, SizeBytes: 60}) {
+		t.Errorf("body postings stats = %+v", p)
+	}
+
+	// .psm 中记录的 .doc 长度与文件不一致
+	os.WriteFile(filepath.Join(tempDir, "_0_"+suffix+".psm"), psm(len(files["_0_"+suffix+".doc"])+8), 0644)
+	if err := parsePostingsStats(FSDirectory{tempDir}, newSegment()); err == nil || !strings.Contains(err.Error(), "expected") {
+		t.Errorf("parsePostingsStats() error = %v, want a .doc length mismatch", err)
+	}
+}
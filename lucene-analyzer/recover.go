@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ---------- recover _source documents as _bulk NDJSON ----------
+
+// RecoverStats counts the documents written by recoverDocuments and the
+// reasons others were left out.
+type RecoverStats struct {
+	Segments       int      `json:"segments"`
+	Recovered      int64    `json:"recovered"`
+	Deleted        int64    `json:"deleted"`                   // cleared in .liv
+	SoftDeleted    int64    `json:"soft_deleted"`              // have a __soft_deletes value
+	NoSource       int64    `json:"no_source"`                 // nested documents, tombstones, or _source disabled
+	NotJSON        int64    `json:"not_json"`                  // _source in a binary encoding (SMILE, CBOR)
+	InvalidID      int64    `json:"invalid_id"`                // stored _id is not a valid Uid encoding
+	FailedSegments []string `json:"failed_segments,omitempty"` // "<segment>: <error>", skipped
+}
+
+type bulkAction struct {
+	Index struct {
+		Index   string `json:"_index,omitempty"`
+		ID      string `json:"_id"`
+		Routing string `json:"routing,omitempty"`
+	} `json:"index"`
+}
+
+// writeError marks a failure of the output rather than of the index.
+type writeError struct{ error }
+
+// recoverDocuments writes every live document of the latest commit to w as
+// _bulk index requests: an action line with _id (and routing), then the
+// compacted _source. index, if set, becomes the _index of every action.
+// Segments that cannot be decoded are skipped and listed in the stats;
+// only a failure to open the index or to write to w aborts the run.
+func recoverDocuments(indexDir, index string, w io.Writer) (*RecoverStats, error) {
+	rr, err := openRecoverReader(indexDir)
+	if err != nil {
+		return nil, err
+	}
+	defer rr.Close()
+	return rr.write(index, w)
+}
+
+// recoverReader holds every segment of the latest commit opened for
+// recovery, so that /recover can report a broken index with an error status
+// before writing the first document.
+type recoverReader struct {
+	segments []*segmentDocs
+	stats    RecoverStats
+}
+
+// segmentDocs is what decoding the documents of one segment needs: .fnm,
+// .liv, the soft deletes doc values and the stored fields.
+type segmentDocs struct {
+	s           *SegInfoSummary
+	segDir      Directory
+	live        liveDocsBits
+	softDeleted bitSet
+	reader      *storedFieldsReader
+}
+
+// openRecoverReader reads segments_N and opens the stored fields of every
+// segment. Segments that fail to open are listed in the stats and skipped;
+// it fails if segments_N cannot be read or no segment can be opened.
+func openRecoverReader(indexDir string) (*recoverReader, error) {
+	segFile, err := findLatestSegmentsFile(indexDir)
+	if err != nil {
+		return nil, err
+	}
+	infos, err := parseSegmentsFile(indexDir, segFile)
+	if err != nil {
+		return nil, err
+	}
+
+	rr := &recoverReader{}
+	dir := FSDirectory{indexDir}
+	for i := range infos.Segments {
+		s := &infos.Segments[i]
+		rr.stats.Segments++
+		d, err := openSegmentDocs(dir, s)
+		if err != nil {
+			rr.stats.FailedSegments = append(rr.stats.FailedSegments, s.SegName+": "+err.Error())
+			continue
+		}
+		rr.segments = append(rr.segments, d)
+	}
+	if len(rr.segments) == 0 && len(rr.stats.FailedSegments) > 0 {
+		return nil, fmt.Errorf("no segment can be recovered: %s", strings.Join(rr.stats.FailedSegments, "; "))
+	}
+	return rr, nil
+}
+
+// write writes the documents of every opened segment to w. A segment that
+// fails while its documents are read is listed in the stats; the documents
+// written before the failure stay in the output.
+func (rr *recoverReader) write(index string, w io.Writer) (*RecoverStats, error) {
+	stats := &rr.stats
+	bw := bufio.NewWriter(w)
+	for _, d := range rr.segments {
+		err := d.write(index, bw, stats)
+		var we writeError
+		if errors.As(err, &we) {
+			return stats, we.error
+		}
+		if err != nil {
+			stats.FailedSegments = append(stats.FailedSegments, d.s.SegName+": "+err.Error())
+		}
+	}
+	return stats, bw.Flush()
+}
+
+func (rr *recoverReader) Close() {
+	for _, d := range rr.segments {
+		d.Close()
+	}
+}
+
+func openSegmentDocs(dir Directory, s *SegInfoSummary) (_ *segmentDocs, err error) {
+	d := &segmentDocs{s: s}
+	if d.segDir, err = openSegmentDirectory(dir, s); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			d.Close()
+		}
+	}()
+	if err := loadFieldInfos(dir, d.segDir, s); err != nil {
+		return nil, err
+	}
+	if s.DelGen > 0 {
+		if d.live, err = readLiveDocs(dir, s); err != nil {
+			return nil, err
+		}
+	}
+	if d.softDeleted, err = readSoftDeletes(dir, d.segDir, s); err != nil {
+		return nil, err
+	}
+	if d.reader, err = openStoredFieldsReader(d.segDir, s); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *segmentDocs) Close() {
+	if d.reader != nil {
+		d.reader.Close()
+	}
+	d.segDir.Close()
+}
+
+// write writes the live documents of the segment to w and counts them in stats.
+func (d *segmentDocs) write(index string, w *bufio.Writer, stats *RecoverStats) error {
+	var action bulkAction
+	action.Index.Index = index
+	var source bytes.Buffer
+	for doc := 0; doc < int(d.s.MaxDoc); doc++ {
+		switch {
+		case !d.live.get(doc):
+			stats.Deleted++
+			continue
+		case d.softDeleted != nil && d.softDeleted.get(doc):
+			stats.SoftDeleted++
+			continue
+		}
+		fields, err := d.reader.document(doc)
+		if err != nil {
+			return err
+		}
+		ed := esDocument(doc, fields)
+		if ed.Source == nil {
+			stats.NoSource++
+			continue
+		}
+		// _bulk 要求每个 _source 占一行
+		source.Reset()
+		if ed.SourceEncoding != "" || json.Compact(&source, ed.Source) != nil {
+			stats.NotJSON++
+			continue
+		}
+		// 无法解码的 _id 不能用于重新索引
+		if ed.IDEncoding != "" {
+			stats.InvalidID++
+			continue
+		}
+		action.Index.ID, action.Index.Routing = ed.ID, ed.Routing
+		line, err := json.Marshal(action)
+		if err != nil {
+			return err
+		}
+		line = append(line, '\n')
+		source.WriteByte('\n')
+		if _, err := w.Write(line); err != nil {
+			return writeError{err}
+		}
+		if _, err := w.Write(source.Bytes()); err != nil {
+			return writeError{err}
+		}
+		stats.Recovered++
+	}
+	return nil
+}
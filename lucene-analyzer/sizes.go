@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ---------- on-disk size breakdown ----------
+
+// ExtensionSize is the share of one file extension (fdt, tim, dvd, ...) in a segment or shard.
+type ExtensionSize struct {
+	Extension string  `json:"extension"`
+	Files     int     `json:"files"`
+	SizeBytes int64   `json:"size_bytes"`
+	Percent   float64 `json:"percent"`
+}
+
+type sizeBreakdown map[string]*ExtensionSize
+
+// fileExtension returns the extension used for size accounting; segments_N files count as "segments".
+func fileExtension(name string) string {
+	if strings.HasPrefix(name, SEGMENTS_PREFIX) {
+		return SEGMENTS_PREFIX
+	}
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		return name[i+1:]
+	}
+	return ""
+}
+
+func (b sizeBreakdown) add(ext string, files int, size int64) {
+	e, ok := b[ext]
+	if !ok {
+		e = &ExtensionSize{Extension: ext}
+		b[ext] = e
+	}
+	e.Files += files
+	e.SizeBytes += size
+}
+
+// sorted returns the breakdown ordered by size, largest first, with percentages of total.
+func (b sizeBreakdown) sorted(total int64) []ExtensionSize {
+	out := make([]ExtensionSize, 0, len(b))
+	for _, e := range b {
+		es := *e
+		es.Percent = percentOf(es.SizeBytes, total)
+		out = append(out, es)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].SizeBytes != out[j].SizeBytes {
+			return out[i].SizeBytes > out[j].SizeBytes
+		}
+		return out[i].Extension < out[j].Extension
+	})
+	return out
+}
+
+// percentOf returns part/total as a percentage rounded to two decimals.
+func percentOf(part, total int64) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return math.Round(float64(part)*10000/float64(total)) / 100
+}
+
+// computeSegmentSizes 统计段内每个文件（包括 .liv 和字段更新文件）的大小
+// 复合段按 .cfs 内子文件的扩展名拆分，.cfs 自身的 header/footer/对齐填充计入 "cfs"
+func computeSegmentSizes(dir Directory, s *SegInfoSummary) error {
+	b := make(sizeBreakdown)
+	var total int64
+	for _, name := range commitFiles(*s) {
+		size, err := dir.FileLength(name)
+		if errors.Is(err, os.ErrNotExist) {
+			continue // 缺失的文件已在完整性校验中报告
+		}
+		if err != nil {
+			return err
+		}
+		total += size
+		if s.Compound && name == s.SegName+".cfs" {
+			var inner int64
+			for _, e := range s.CompoundFiles {
+				b.add(fileExtension(e.Name), 1, e.Length)
+				inner += e.Length
+			}
+			b.add("cfs", 1, size-inner)
+			continue
+		}
+		b.add(fileExtension(name), 1, size)
+	}
+	s.SizeBytes = total
+	s.SizeByExtension = b.sorted(total)
+	return nil
+}
+
+// summarizeSizes 汇总整个分片（segments_N 加所有段文件）的大小分布，并计算每个段的占比
+func summarizeSizes(rep *Report) {
+	b := make(sizeBreakdown)
+	var total int64
+	if size, err := (FSDirectory{rep.IndexPath}).FileLength(rep.SegmentsFile); err == nil {
+		b.add(SEGMENTS_PREFIX, 1, size)
+		total += size
+	}
+	for _, s := range rep.Segments {
+		total += s.SizeBytes
+		for _, e := range s.SizeByExtension {
+			b.add(e.Extension, e.Files, e.SizeBytes)
+		}
+	}
+	rep.TotalSizeBytes = total
+	rep.SizeByExtension = b.sorted(total)
+	for i := range rep.Segments {
+		rep.Segments[i].SizePercent = percentOf(rep.Segments[i].SizeBytes, total)
+	}
+}
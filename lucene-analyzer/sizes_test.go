@@ -0,0 +1,84 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestFileExtension tests the extension used for size accounting
+func TestFileExtension(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"_0.fdt", "fdt"},
+		{"_8rd_Lucene103_0.tim", "tim"},
+		{"_0_1.fnm", "fnm"},
+		{"segments_7y8", "segments"},
+		{"write.lock", "lock"},
+		{"noext", ""},
+	}
+	for _, tt := range tests {
+		if got := fileExtension(tt.name); got != tt.want {
+			t.Errorf("fileExtension(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestPercentOf tests rounding and the zero total case
+func TestPercentOf(t *testing.T) {
+	if got := percentOf(1, 3); got != 33.33 {
+		t.Errorf("percentOf(1, 3) = %v, want 33.33", got)
+	}
+	if got := percentOf(5, 0); got != 0 {
+		t.Errorf("percentOf(5, 0) = %v, want 0", got)
+	}
+}
+
+// TestComputeSegmentSizes tests that compound segments are broken down by sub-file extension
+// and that the live docs and field update files count toward the segment
+func TestComputeSegmentSizes(t *testing.T) {
+	tempDir := t.TempDir()
+	writeCompoundSegment(t, tempDir, "_3", []string{".fnm", ".fdt"}, [][]byte{make([]byte, 10), make([]byte, 300)})
+	for name, size := range map[string]int{"_3.si": 20, "_3_2.liv": 8, "_3_1.fnm": 40} {
+		if err := os.WriteFile(filepath.Join(tempDir, name), make([]byte, size), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+	cfs, err := openCompoundDirectory(FSDirectory{tempDir}, "_3")
+	if err != nil {
+		t.Fatalf("openCompoundDirectory() error = %v", err)
+	}
+	defer cfs.Close()
+
+	s := &SegInfoSummary{SegName: "_3", Compound: true, CompoundFiles: cfs.Entries(), DelGen: 2,
+		Files: []string{"_3.cfe", "_3.cfs", "_3.si", "_3_1.fnm", "_3_1_Lucene90_0.dvm"}}
+	if err := computeSegmentSizes(FSDirectory{tempDir}, s); err != nil {
+		t.Fatalf("computeSegmentSizes() error = %v", err)
+	}
+
+	var want int64
+	for _, name := range []string{"_3.cfe", "_3.cfs", "_3.si", "_3_2.liv", "_3_1.fnm"} {
+		fi, _ := os.Stat(filepath.Join(tempDir, name))
+		want += fi.Size()
+	}
+	if s.SizeBytes != want {
+		t.Errorf("computeSegmentSizes() SizeBytes = %d, want %d", s.SizeBytes, want)
+	}
+	got := make(map[string]int64)
+	var sum int64
+	for _, e := range s.SizeByExtension {
+		got[e.Extension] = e.SizeBytes
+		sum += e.SizeBytes
+	}
+	if got["fdt"] != 300 || got["fnm"] != 50 || got["si"] != 20 || got["liv"] != 8 || got["cfs"] <= 0 {
+		t.Errorf("computeSegmentSizes() breakdown = %+v", s.SizeByExtension)
+	}
+	if sum != s.SizeBytes {
+		t.Errorf("computeSegmentSizes() breakdown sums to %d, want %d", sum, s.SizeBytes)
+	}
+	if s.SizeByExtension[0].Extension != "fdt" {
+		t.Errorf("computeSegmentSizes() largest extension = %s, want fdt", s.SizeByExtension[0].Extension)
+	}
+}
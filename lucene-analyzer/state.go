@@ -0,0 +1,395 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ---------- Elasticsearch/OpenSearch _state files (MetadataStateFormat) ----------
+
+const (
+	STATE_DIR            = "_state"
+	STATE_FILE_CODEC     = "state"
+	STATE_FILE_VERSION   = 1
+	STATE_FILE_EXTENSION = ".st"
+
+	INDEX_STATE_PREFIX      = "state-"            // <index uuid>/_state 与 <shard>/_state
+	RETENTION_LEASES_PREFIX = "retention-leases-" // <shard>/_state
+
+	// CompressedXContent 的 DeflateCompressor 头，后接 raw deflate 数据
+	COMPRESSED_XCONTENT_HEADER = "DFL\x00"
+)
+
+// IndexMetadata is the index-level state the cluster keeps next to the shard
+// directories, as written by IndexMetadata.toXContent.
+type IndexMetadata struct {
+	File              string              `json:"file"` // relative to the index directory, e.g. _state/state-4.st
+	Name              string              `json:"name"`
+	UUID              string              `json:"uuid,omitempty"`
+	State             string              `json:"state,omitempty"` // open/close
+	Version           int64               `json:"version"`
+	MappingVersion    int64               `json:"mapping_version,omitempty"`
+	SettingsVersion   int64               `json:"settings_version,omitempty"`
+	AliasesVersion    int64               `json:"aliases_version,omitempty"`
+	NumberOfShards    int64               `json:"number_of_shards"`
+	NumberOfReplicas  int64               `json:"number_of_replicas"`
+	RoutingNumShards  int64               `json:"routing_num_shards,omitempty"`
+	CreationDate      string              `json:"creation_date,omitempty"`
+	PrimaryTerms      []int64             `json:"primary_terms,omitempty"`
+	InSyncAllocations map[string][]string `json:"in_sync_allocations,omitempty"` // shard -> allocation IDs
+	Settings          map[string]any      `json:"settings,omitempty"`            // flattened, e.g. index.number_of_shards
+	Mappings          map[string]any      `json:"mappings,omitempty"`
+	Aliases           map[string]any      `json:"aliases,omitempty"`
+}
+
+// ShardState is the shard-level state: whether the copy on this node was the
+// primary and which allocation it belongs to.
+type ShardState struct {
+	File            string           `json:"file"` // relative to the index directory, e.g. 0/_state/state-1.st
+	Primary         bool             `json:"primary"`
+	IndexUUID       string           `json:"index_uuid,omitempty"`
+	AllocationID    string           `json:"allocation_id,omitempty"`
+	RetentionLeases *RetentionLeases `json:"retention_leases,omitempty"`
+}
+
+// RetentionLeases keep operations above a sequence number in the shard's
+// history, mostly for peer recovery and cross-cluster replication.
+type RetentionLeases struct {
+	File        string           `json:"file"`
+	PrimaryTerm int64            `json:"primary_term"`
+	Version     int64            `json:"version"`
+	Leases      []RetentionLease `json:"leases"`
+}
+
+type RetentionLease struct {
+	ID             string `json:"id"`
+	RetainingSeqNo int64  `json:"retaining_sequence_number"`
+	Timestamp      string `json:"timestamp,omitempty"`
+	Source         string `json:"source,omitempty"`
+}
+
+// latestStateFile returns the prefix-N.st file with the highest generation in
+// dir, or "" when there is none. Older generations are left behind when the
+// node stops before cleaning them up.
+func latestStateFile(dir, prefix string) (string, error) {
+	fis, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	latest, maxGen := "", int64(-1)
+	for _, fi := range fis {
+		name := fi.Name()
+		if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, STATE_FILE_EXTENSION) {
+			continue
+		}
+		gen, err := strconv.ParseInt(strings.TrimSuffix(name[len(prefix):], STATE_FILE_EXTENSION), 10, 64)
+		if err != nil {
+			continue
+		}
+		if gen > maxGen {
+			latest, maxGen = name, gen
+		}
+	}
+	return latest, nil
+}
+
+// readStateFile decodes the state file root/file; errors name it by file.
+// Header, XContentType (BE int), XContent, Footer
+func readStateFile(root, file string) (map[string]any, error) {
+	if c := verifyFileChecksum(root, file); !c.ChecksumOK {
+		return nil, fmt.Errorf("%s: %s", file, c.Error)
+	}
+	in, err := OpenDataInput(filepath.Join(root, file))
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	in.name = file
+	if _, err := checkHeader(in, STATE_FILE_CODEC, STATE_FILE_VERSION, STATE_FILE_VERSION); err != nil {
+		return nil, err
+	}
+	typ, err := in.ReadBEInt()
+	if err != nil {
+		return nil, in.fail("xContentType", err)
+	}
+	body, err := in.Slice(file, in.Pos(), in.Length()-FOOTER_LENGTH-in.Pos())
+	if err != nil {
+		return nil, in.fail("xContent", err)
+	}
+	v, err := decodeXContent(body, typ)
+	if err != nil {
+		return nil, err
+	}
+	obj, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s: expected an object, got %T", file, v)
+	}
+	return obj, nil
+}
+
+// readShardState reads <shard>/_state; both results are nil when shardPath
+// is not a shard directory of an Elasticsearch/OpenSearch data path. Like
+// those of readIndexMetadata, its files are named relative to the index
+// directory that holds the shard.
+func readShardState(shardPath string) (*ShardState, error) {
+	root, stateDir := filepath.Dir(shardPath), filepath.Join(filepath.Base(shardPath), STATE_DIR)
+	name, err := latestStateFile(filepath.Join(root, stateDir), INDEX_STATE_PREFIX)
+	if name == "" || err != nil {
+		return nil, err
+	}
+	obj, err := readStateFile(root, filepath.Join(stateDir, name))
+	if err != nil {
+		return nil, err
+	}
+	st := &ShardState{
+		File:      filepath.Join(stateDir, name),
+		Primary:   boolValue(obj["primary"]),
+		IndexUUID: stringValue(obj["index_uuid"]),
+	}
+	if alloc, ok := obj["allocation_id"].(map[string]any); ok {
+		st.AllocationID = stringValue(alloc["id"])
+	}
+
+	if name, err = latestStateFile(filepath.Join(root, stateDir), RETENTION_LEASES_PREFIX); name == "" || err != nil {
+		return st, err
+	}
+	if obj, err = readStateFile(root, filepath.Join(stateDir, name)); err != nil {
+		return st, err
+	}
+	rl := &RetentionLeases{
+		File:        filepath.Join(stateDir, name),
+		PrimaryTerm: intValue(obj["primary_term"]),
+		Version:     intValue(obj["version"]),
+		Leases:      []RetentionLease{},
+	}
+	leases, _ := obj["leases"].([]any)
+	for _, l := range leases {
+		m, _ := l.(map[string]any)
+		lease := RetentionLease{ID: stringValue(m["id"]), RetainingSeqNo: intValue(m["retaining_sequence_number"]), Source: stringValue(m["source"])}
+		if _, ok := m["timestamp"]; ok {
+			lease.Timestamp = formatMillis(intValue(m["timestamp"]))
+		}
+		rl.Leases = append(rl.Leases, lease)
+	}
+	st.RetentionLeases = rl
+	return st, nil
+}
+
+// readIndexMetadata reads <index uuid>/_state, whose single top-level key is
+// the index name.
+func readIndexMetadata(indexPath string) (*IndexMetadata, error) {
+	name, err := latestStateFile(filepath.Join(indexPath, STATE_DIR), INDEX_STATE_PREFIX)
+	if name == "" || err != nil {
+		return nil, err
+	}
+	file := filepath.Join(STATE_DIR, name)
+	obj, err := readStateFile(indexPath, file)
+	if err != nil {
+		return nil, err
+	}
+	if len(obj) != 1 {
+		return nil, fmt.Errorf("%s: expected a single index, got %d keys", file, len(obj))
+	}
+	md := &IndexMetadata{File: file}
+	var body map[string]any
+	for k, v := range obj {
+		md.Name = k
+		body, _ = v.(map[string]any)
+	}
+	md.State = stringValue(body["state"])
+	md.Version = intValue(body["version"])
+	md.MappingVersion = intValue(body["mapping_version"])
+	md.SettingsVersion = intValue(body["settings_version"])
+	md.AliasesVersion = intValue(body["aliases_version"])
+	md.RoutingNumShards = intValue(body["routing_num_shards"])
+
+	md.Settings = map[string]any{}
+	flattenSettings("", body["settings"], md.Settings)
+	md.UUID = stringValue(md.Settings["index.uuid"])
+	md.NumberOfShards = intValue(md.Settings["index.number_of_shards"])
+	md.NumberOfReplicas = intValue(md.Settings["index.number_of_replicas"])
+	if v, ok := md.Settings["index.creation_date"]; ok {
+		md.CreationDate = formatMillis(intValue(v))
+	}
+
+	// primary_terms 是按分片排列的数组，旧版本写成以分片号为键的对象
+	switch terms := body["primary_terms"].(type) {
+	case []any:
+		for _, t := range terms {
+			md.PrimaryTerms = append(md.PrimaryTerms, intValue(t))
+		}
+	case map[string]any:
+		md.PrimaryTerms = make([]int64, len(terms))
+		for k, t := range terms {
+			if shard, err := strconv.Atoi(k); err == nil && shard >= 0 && shard < len(terms) {
+				md.PrimaryTerms[shard] = intValue(t)
+			}
+		}
+	}
+	if alloc, ok := body["in_sync_allocations"].(map[string]any); ok {
+		md.InSyncAllocations = map[string][]string{}
+		for shard, ids := range alloc {
+			list, _ := ids.([]any)
+			md.InSyncAllocations[shard] = []string{}
+			for _, id := range list {
+				md.InSyncAllocations[shard] = append(md.InSyncAllocations[shard], stringValue(id))
+			}
+			sort.Strings(md.InSyncAllocations[shard])
+		}
+	}
+	if md.Mappings, err = decodeMappings(body["mappings"]); err != nil {
+		return md, fmt.Errorf("%s: mappings: %w", file, err)
+	}
+	md.Aliases, _ = body["aliases"].(map[string]any)
+	return md, nil
+}
+
+// decodeMappings merges the mapping entries, keyed by type name (_doc).
+// Each entry is either an object or a CompressedXContent blob.
+func decodeMappings(v any) (map[string]any, error) {
+	var entries []any
+	switch m := v.(type) {
+	case nil:
+		return nil, nil
+	case []any:
+		entries = m
+	default:
+		entries = []any{m}
+	}
+	mappings := map[string]any{}
+	for _, e := range entries {
+		if b, ok := e.([]byte); ok {
+			var err error
+			if e, err = decompressXContent(b); err != nil {
+				return nil, err
+			}
+		}
+		m, ok := e.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("unexpected mapping entry of type %T", e)
+		}
+		for k, v := range m {
+			mappings[k] = v
+		}
+	}
+	return mappings, nil
+}
+
+// mappedFieldType returns the type of a Lucene field name such as
+// measurements.cpu.number in the mappings, following object properties and
+// multi-fields, or "" when the field is not mapped.
+func mappedFieldType(mappings map[string]any, field string) string {
+	for _, m := range mappings {
+		node, _ := m.(map[string]any)
+		for _, part := range strings.Split(field, ".") {
+			props, _ := node["properties"].(map[string]any)
+			fields, _ := node["fields"].(map[string]any)
+			if next, ok := props[part].(map[string]any); ok {
+				node = next
+			} else {
+				node, _ = fields[part].(map[string]any)
+			}
+		}
+		if typ, _ := node["type"].(string); typ != "" {
+			return typ
+		}
+	}
+	return ""
+}
+
+// decompressXContent inflates a CompressedXContent and decodes the JSON or
+// SMILE document inside.
+func decompressXContent(b []byte) (any, error) {
+	if bytes.HasPrefix(b, []byte(COMPRESSED_XCONTENT_HEADER)) {
+		r := flate.NewReader(bytes.NewReader(b[len(COMPRESSED_XCONTENT_HEADER):]))
+		defer r.Close()
+		var err error
+		if b, err = io.ReadAll(r); err != nil {
+			return nil, err
+		}
+	}
+	in := NewDataInput("mappings", bytes.NewReader(b), int64(len(b)))
+	if bytes.HasPrefix(b, []byte(SMILE_HEADER)) {
+		return decodeXContent(in, XCONTENT_SMILE)
+	}
+	return decodeXContent(in, XCONTENT_JSON)
+}
+
+// flattenSettings turns nested settings into dotted keys; the state files
+// usually already hold them flat.
+func flattenSettings(prefix string, v any, out map[string]any) {
+	m, ok := v.(map[string]any)
+	if !ok {
+		if prefix != "" {
+			out[prefix] = v
+		}
+		return
+	}
+	for k, child := range m {
+		if prefix != "" {
+			k = prefix + "." + k
+		}
+		flattenSettings(k, child, out)
+	}
+}
+
+// ---------- XContent value accessors ----------
+
+func stringValue(v any) string {
+	switch s := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return s
+	}
+	return fmt.Sprint(v)
+}
+
+// intValue accepts numbers as well as numeric strings, as settings are
+// always written as strings.
+func intValue(v any) int64 {
+	switch n := v.(type) {
+	case int64:
+		return n
+	case float64:
+		if n >= math.MinInt64 && n <= math.MaxInt64 {
+			return int64(n)
+		}
+	case json.Number:
+		i, _ := n.Int64()
+		return i
+	case string:
+		i, _ := strconv.ParseInt(n, 10, 64)
+		return i
+	}
+	return 0
+}
+
+func boolValue(v any) bool {
+	switch b := v.(type) {
+	case bool:
+		return b
+	case string:
+		return b == "true"
+	}
+	return false
+}
+
+func formatMillis(ms int64) string {
+	return time.UnixMilli(ms).UTC().Format("2006-01-02T15:04:05.000Z")
+}
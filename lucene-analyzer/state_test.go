@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeStateFile writes a MetadataStateFormat file with the given XContent body
+func writeStateFile(t *testing.T, dir, name string, typ int32, body []byte) {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, int32(CODEC_MAGIC))
+	writeString(&buf, STATE_FILE_CODEC)
+	binary.Write(&buf, binary.BigEndian, []int32{STATE_FILE_VERSION, typ})
+	buf.Write(body)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), withFooter(buf.Bytes()), 0644); err != nil {
+		t.Fatalf("Failed to create %s: %v", name, err)
+	}
+}
+
+// TestReadShardState tests the latest generation of the shard state and the retention leases
+func TestReadShardState(t *testing.T) {
+	shardPath := filepath.Join(t.TempDir(), "0")
+	if st, err := readShardState(shardPath); st != nil || err != nil {
+		t.Fatalf("readShardState() = %+v, %v, want nil without _state", st, err)
+	}
+
+	dir := filepath.Join(shardPath, STATE_DIR)
+	writeStateFile(t, dir, "state-2.st", XCONTENT_JSON, []byte(`{"primary":false,"index_uuid":"old"}`))
+	writeStateFile(t, dir, "state-10.st", XCONTENT_JSON, []byte(`{"primary":true,"index_uuid":"uuid1","allocation_id":{"id":"alloc1"}}`))
+	// CBOR: {"primary_term": 3, "version": 7, "leases": [{"id": "peer_recovery/n1", "retaining_sequence_number": 42, "timestamp": 1767598906000, "source": "peer recovery"}]}
+	var leases bytes.Buffer
+	leases.Write([]byte{0xA3, 0x6C})
+	leases.WriteString("primary_term")
+	leases.Write([]byte{0x03, 0x67})
+	leases.WriteString("version")
+	leases.Write([]byte{0x07, 0x66})
+	leases.WriteString("leases")
+	leases.Write([]byte{0x81, 0xA4, 0x62, 'i', 'd', 0x70})
+	leases.WriteString("peer_recovery/n1")
+	leases.Write([]byte{0x78, 0x19})
+	leases.WriteString("retaining_sequence_number")
+	leases.Write([]byte{0x18, 42, 0x69})
+	leases.WriteString("timestamp")
+	leases.Write([]byte{0x1B, 0, 0, 0x01, 0x9B, 0x8D, 0x1A, 0xDA, 0x90, 0x66})
+	leases.WriteString("source")
+	leases.WriteByte(0x6D)
+	leases.WriteString("peer recovery")
+	writeStateFile(t, dir, "retention-leases-3.st", XCONTENT_CBOR, leases.Bytes())
+
+	st, err := readShardState(shardPath)
+	if err != nil {
+		t.Fatalf("readShardState() error = %v", err)
+	}
+	want := &ShardState{File: filepath.Join("0", STATE_DIR, "state-10.st"), Primary: true, IndexUUID: "uuid1", AllocationID: "alloc1",
+		RetentionLeases: &RetentionLeases{File: filepath.Join("0", STATE_DIR, "retention-leases-3.st"), PrimaryTerm: 3, Version: 7, Leases: []RetentionLease{
+			{ID: "peer_recovery/n1", RetainingSeqNo: 42, Timestamp: "2026-01-05T07:41:46.000Z", Source: "peer recovery"},
+		}}}
+	if !reflect.DeepEqual(st, want) {
+		t.Errorf("readShardState() = %+v, want %+v", st, want)
+	}
+
+	// 校验和不匹配
+	path := filepath.Join(dir, "state-10.st")
+	data, _ := os.ReadFile(path)
+	data[len(data)-FOOTER_LENGTH-2] ^= 0xFF
+	os.WriteFile(path, data, 0644)
+	if _, err := readShardState(shardPath); err == nil || !strings.Contains(err.Error(), filepath.Join("0", STATE_DIR, "state-10.st")+": checksum failed") {
+		t.Errorf("readShardState() error = %v, want a checksum failure of 0/_state/state-10.st", err)
+	}
+}
+
+// TestReadIndexMetadata tests nested settings, primary terms and in-sync allocations
+func TestReadIndexMetadata(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "uuid1")
+	writeStateFile(t, filepath.Join(indexPath, STATE_DIR), "state-4.st", XCONTENT_JSON, []byte(`{"logs":{
+		"version":5,"mapping_version":2,"settings_version":1,"aliases_version":3,"routing_num_shards":1024,"state":"open",
+		"settings":{"index":{"number_of_shards":"2","number_of_replicas":"1","uuid":"uuid1","creation_date":"1767597754217"}},
+		"mappings":[{"_doc":{"properties":{"ts":{"type":"long"}}}}],
+		"aliases":{"logs-read":{}},
+		"primary_terms":[3,1],
+		"in_sync_allocations":{"0":["b","a"],"1":[]}}}`))
+
+	md, err := readIndexMetadata(indexPath)
+	if err != nil {
+		t.Fatalf("readIndexMetadata() error = %v", err)
+	}
+	want := &IndexMetadata{
+		File: filepath.Join(STATE_DIR, "state-4.st"), Name: "logs", UUID: "uuid1", State: "open",
+		Version: 5, MappingVersion: 2, SettingsVersion: 1, AliasesVersion: 3,
+		NumberOfShards: 2, NumberOfReplicas: 1, RoutingNumShards: 1024, CreationDate: "2026-01-05T07:22:34.217Z",
+		PrimaryTerms:      []int64{3, 1},
+		InSyncAllocations: map[string][]string{"0": {"a", "b"}, "1": {}},
+		Settings: map[string]any{"index.number_of_shards": "2", "index.number_of_replicas": "1", "index.uuid": "uuid1",
+			"index.creation_date": "1767597754217"},
+		Mappings: map[string]any{"_doc": map[string]any{"properties": map[string]any{"ts": map[string]any{"type": "long"}}}},
+		Aliases:  map[string]any{"logs-read": map[string]any{}},
+	}
+	if !reflect.DeepEqual(md, want) {
+		t.Errorf("readIndexMetadata() = %+v, want %+v", md, want)
+	}
+}
+
+// TestDecodeMappings tests mappings stored as CompressedXContent
+func TestDecodeMappings(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString(COMPRESSED_XCONTENT_HEADER)
+	w, _ := flate.NewWriter(&buf, flate.BestCompression)
+	w.Write([]byte(`{"_doc":{"dynamic":"strict"}}`))
+	w.Close()
+
+	got, err := decodeMappings([]any{buf.Bytes()})
+	if err != nil {
+		t.Fatalf("decodeMappings() error = %v", err)
+	}
+	if want := map[string]any{"_doc": map[string]any{"dynamic": "strict"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("decodeMappings() = %v, want %v", got, want)
+	}
+	if _, err := decodeMappings([]any{[]byte("DFL\x00garbage")}); err == nil {
+		t.Errorf("decodeMappings() error = nil, want a deflate error")
+	}
+}
@@ -0,0 +1,574 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// ---------- stored fields metadata (.fdm/.fdx) per Lucene90CompressingStoredFieldsFormat ----------
+
+const (
+	STORED_FIELDS_MODE_ATTR  = "Lucene90StoredFieldsFormat.mode"
+	STORED_FIELDS_META_CODEC = "Lucene90FieldsIndexMeta"
+	STORED_FIELDS_IDX_CODEC  = "Lucene90FieldsIndexIdx"
+	STORED_FIELDS_FAST_CODEC = "Lucene90StoredFieldsFastData" // BEST_SPEED, LZ4
+	STORED_FIELDS_HIGH_CODEC = "Lucene90StoredFieldsHighData" // BEST_COMPRESSION, DEFLATE
+	STORED_FIELDS_VERSION    = 1
+	FIELDS_INDEX_VERSION     = 0
+
+	// Lucene90StoredFieldsFormat 中每个 chunk 的最大文档数
+	STORED_FIELDS_FAST_MAX_DOCS = 1024
+	STORED_FIELDS_HIGH_MAX_DOCS = 4096
+)
+
+// ValueStats summarizes a distribution of non-negative values.
+type ValueStats struct {
+	Min int64   `json:"min"`
+	Max int64   `json:"max"`
+	Avg float64 `json:"avg"`
+}
+
+func newValueStats(values []int64) ValueStats {
+	if len(values) == 0 {
+		return ValueStats{}
+	}
+	st := ValueStats{Min: values[0], Max: values[0]}
+	var sum int64
+	for _, v := range values {
+		st.Min, st.Max = min(st.Min, v), max(st.Max, v)
+		sum += v
+	}
+	st.Avg = math.Round(float64(sum)*100/float64(len(values))) / 100
+	return st
+}
+
+// StoredFieldsInfo reports how a segment's stored fields are chunked and compressed.
+type StoredFieldsInfo struct {
+	Mode                 string     `json:"mode"`       // BEST_SPEED or BEST_COMPRESSION, from the .si attribute
+	DataCodec            string     `json:"data_codec"` // .fdt header codec
+	ChunkSize            int32      `json:"chunk_size"` // bytes buffered before a chunk is compressed
+	MaxDocsPerChunk      int        `json:"max_docs_per_chunk"`
+	NumChunks            int64      `json:"num_chunks"`
+	DirtyChunks          int64      `json:"dirty_chunks"` // flushed before they were full
+	DirtyDocs            int64      `json:"dirty_docs"`
+	DocsPerChunk         ValueStats `json:"docs_per_chunk"`
+	ChunkBytes           ValueStats `json:"chunk_compressed_bytes"`
+	CompressedBytes      int64      `json:"compressed_bytes"` // all chunks in .fdt
+	AvgBytesPerDoc       float64    `json:"avg_compressed_bytes_per_doc"`
+	TooDirty             bool       `json:"too_dirty"`             // the next merge recompresses instead of copying chunks
+	RecompressibleChunks int64      `json:"recompressible_chunks"` // dirty chunks a recompressing merge would refill
+	Note                 string     `json:"note,omitempty"`
+}
+
+// storedFieldsMeta is the decoded .fdm plus the chunk index from .fdx.
+type storedFieldsMeta struct {
+	dataCodec   string
+	chunkSize   int32
+	numDocs     int32
+	docStarts   []int64 // first doc of each chunk, plus numDocs
+	pointers    []int64 // .fdt offset of each chunk, plus the end of the last one
+	numChunks   int64
+	dirtyChunks int64
+	dirtyDocs   int64
+}
+
+// .fdm: Header, ChunkSize, NumDocs, BlockShift, NumChunks+1, DocsStart, DocsMeta, PointersStart, PointersMeta, PointersEnd, MaxPointer, NumChunks, NumDirtyChunks, NumDirtyDocs, Footer
+func readStoredFieldsMeta(dir Directory, segName string) (*storedFieldsMeta, error) {
+	meta, err := dir.OpenInput(segName + ".fdm")
+	if err != nil {
+		return nil, err
+	}
+	defer meta.Close()
+	if _, err := checkIndexHeader(meta, STORED_FIELDS_META_CODEC, STORED_FIELDS_VERSION, STORED_FIELDS_VERSION); err != nil {
+		return nil, err
+	}
+
+	m := &storedFieldsMeta{}
+	if m.chunkSize, err = meta.ReadVInt(); err != nil {
+		return nil, meta.fail("chunkSize", err)
+	}
+	index, err := readFieldsIndexMeta(meta)
+	if err != nil {
+		return nil, err
+	}
+	m.numDocs = index.numDocs
+	if m.numChunks, err = meta.ReadVLong(); err != nil {
+		return nil, meta.fail("numChunks", err)
+	}
+	if m.numChunks != index.docs.numValues-1 {
+		return nil, meta.fail("numChunks", fmt.Errorf("chunk count %d does not match %d index entries", m.numChunks, index.docs.numValues))
+	}
+	if m.dirtyChunks, err = meta.ReadVLong(); err != nil {
+		return nil, meta.fail("numDirtyChunks", err)
+	}
+	if m.dirtyDocs, err = meta.ReadVLong(); err != nil {
+		return nil, meta.fail("numDirtyDocs", err)
+	}
+
+	fdt, err := dir.OpenInput(segName + ".fdt")
+	if err != nil {
+		return nil, err
+	}
+	defer fdt.Close()
+	hdr, err := checkIndexHeaderOf(fdt,
+		HeaderFormat{Codec: STORED_FIELDS_FAST_CODEC, MinVersion: STORED_FIELDS_VERSION, MaxVersion: STORED_FIELDS_VERSION},
+		HeaderFormat{Codec: STORED_FIELDS_HIGH_CODEC, MinVersion: STORED_FIELDS_VERSION, MaxVersion: STORED_FIELDS_VERSION})
+	if err != nil {
+		return nil, err
+	}
+	m.dataCodec = hdr.Codec
+
+	if m.docStarts, m.pointers, err = index.read(dir, segName+".fdx", STORED_FIELDS_IDX_CODEC); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// fieldsIndexMeta is the part of .fdm/.tvm written by FieldsIndexWriter.
+type fieldsIndexMeta struct {
+	numDocs       int32
+	docsStart     int64
+	docs          *DirectMonotonic // first doc of each chunk, plus numDocs
+	pointersStart int64
+	pointers      *DirectMonotonic // data file offset of each chunk, plus the end of the last one
+	maxPointer    int64
+}
+
+// FieldsIndex meta: NumDocs, BlockShift, NumChunks+1, DocsStart, DocsMeta, PointersStart, PointersMeta, PointersEnd, MaxPointer
+func readFieldsIndexMeta(meta *DataInput) (*fieldsIndexMeta, error) {
+	m := &fieldsIndexMeta{}
+	var err error
+	if m.numDocs, err = meta.ReadInt(); err != nil {
+		return nil, meta.fail("numDocs", err)
+	}
+	blockShift, err := meta.ReadInt()
+	if err != nil {
+		return nil, meta.fail("blockShift", err)
+	}
+	numIndexValues, err := meta.ReadInt()
+	if err != nil {
+		return nil, meta.fail("numChunks", err)
+	}
+	if m.docsStart, err = meta.ReadLong(); err != nil {
+		return nil, meta.fail("docsStart", err)
+	}
+	if m.docs, err = readDirectMonotonicMeta(meta, "docs", int64(numIndexValues), blockShift); err != nil {
+		return nil, err
+	}
+	if m.pointersStart, err = meta.ReadLong(); err != nil {
+		return nil, meta.fail("startPointersStart", err)
+	}
+	if m.pointers, err = readDirectMonotonicMeta(meta, "startPointers", int64(numIndexValues), blockShift); err != nil {
+		return nil, err
+	}
+	if _, err := meta.ReadLong(); err != nil {
+		return nil, meta.fail("startPointersEnd", err)
+	}
+	if m.maxPointer, err = meta.ReadLong(); err != nil {
+		return nil, meta.fail("maxPointer", err)
+	}
+	return m, nil
+}
+
+// read decodes the chunk index from the .fdx/.tvx file name.
+func (m *fieldsIndexMeta) read(dir Directory, name, codec string) (docStarts, pointers []int64, err error) {
+	idx, err := dir.OpenInput(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer idx.Close()
+	if _, err := checkIndexHeader(idx, codec, FIELDS_INDEX_VERSION, FIELDS_INDEX_VERSION); err != nil {
+		return nil, nil, err
+	}
+	if docStarts, err = m.docs.values(idx, m.docsStart); err != nil {
+		return nil, nil, idx.fail("docs", err)
+	}
+	if pointers, err = m.pointers.values(idx, m.pointersStart); err != nil {
+		return nil, nil, idx.fail("startPointers", err)
+	}
+	if n := len(docStarts); n > 0 && docStarts[n-1] != int64(m.numDocs) {
+		return nil, nil, idx.fail("docs", fmt.Errorf("docs don't add up: %d != %d", docStarts[n-1], m.numDocs))
+	}
+	return docStarts, pointers, nil
+}
+
+// parseStoredFields 解析 .fdm/.fdx 并统计 chunk 的压缩情况
+// 只处理 Lucene90StoredFieldsFormat，更早的格式（无该属性）返回 nil
+func parseStoredFields(dir Directory, s *SegInfoSummary) (*StoredFieldsInfo, error) {
+	mode, ok := s.Attributes[STORED_FIELDS_MODE_ATTR]
+	if !ok {
+		return nil, nil
+	}
+	m, err := readStoredFieldsMeta(dir, s.SegName)
+	if err != nil {
+		return nil, err
+	}
+	if m.numDocs != s.MaxDoc {
+		return nil, fmt.Errorf("%s.fdm: numDocs %d does not match segment maxDoc %d", s.SegName, m.numDocs, s.MaxDoc)
+	}
+
+	info := &StoredFieldsInfo{
+		Mode:            mode,
+		DataCodec:       m.dataCodec,
+		ChunkSize:       m.chunkSize,
+		MaxDocsPerChunk: STORED_FIELDS_FAST_MAX_DOCS,
+		NumChunks:       m.numChunks,
+		DirtyChunks:     m.dirtyChunks,
+		DirtyDocs:       m.dirtyDocs,
+	}
+	if m.dataCodec == STORED_FIELDS_HIGH_CODEC {
+		info.MaxDocsPerChunk = STORED_FIELDS_HIGH_MAX_DOCS
+	}
+	docs := make([]int64, m.numChunks)
+	bytes := make([]int64, m.numChunks)
+	for i := range docs {
+		docs[i] = m.docStarts[i+1] - m.docStarts[i]
+		bytes[i] = m.pointers[i+1] - m.pointers[i]
+	}
+	info.DocsPerChunk = newValueStats(docs)
+	info.ChunkBytes = newValueStats(bytes)
+	if m.numChunks > 0 {
+		info.CompressedBytes = m.pointers[m.numChunks] - m.pointers[0]
+	}
+	if m.numDocs > 0 {
+		info.AvgBytesPerDoc = math.Round(float64(info.CompressedBytes)*100/float64(m.numDocs)) / 100
+	}
+
+	// 与 Lucene90CompressingStoredFieldsWriter.tooDirty 一致：脏文档足够填满一个 chunk 且脏 chunk 超过 1%
+	info.TooDirty = m.dirtyDocs > int64(info.MaxDocsPerChunk) && m.dirtyChunks*100 > m.numChunks
+	info.RecompressibleChunks = m.dirtyChunks
+	if info.TooDirty {
+		info.Note = "the next merge will recompress this segment instead of copying its chunks"
+	}
+	return info, nil
+}
+
+// ---------- reading documents from .fdt chunks ----------
+
+const (
+	STORED_FIELD_TYPE_BITS = 3
+	STORED_FIELD_TYPE_MASK = 1<<STORED_FIELD_TYPE_BITS - 1
+
+	// TLong 编码的时间单位
+	TLONG_SECOND = 1000
+	TLONG_HOUR   = 60 * 60 * TLONG_SECOND
+	TLONG_DAY    = 24 * TLONG_HOUR
+)
+
+var storedFieldTypeNames = []string{"string", "binary", "int", "float", "long", "double"}
+
+// StoredField is one decoded stored field value of a document.
+type StoredField struct {
+	Name   string `json:"name"`
+	Number int32  `json:"number"`
+	Type   string `json:"type"` // string, binary, int, float, long, double
+	Value  any    `json:"value"`
+}
+
+// storedFieldsReader decodes documents chunk by chunk, keeping the most
+// recently decompressed chunk so that sequential reads stay cheap.
+type storedFieldsReader struct {
+	meta  *storedFieldsMeta
+	fdt   *DataInput
+	names map[int32]string
+
+	chunk           int // index of the decompressed chunk, -1 if none
+	numStoredFields []int64
+	offsets         []int64 // start of each doc within data, plus the end
+	data            []byte
+}
+
+func openStoredFieldsReader(dir Directory, s *SegInfoSummary) (*storedFieldsReader, error) {
+	meta, err := readStoredFieldsMeta(dir, s.SegName)
+	if err != nil {
+		return nil, err
+	}
+	fdt, err := dir.OpenInput(s.SegName + ".fdt")
+	if err != nil {
+		return nil, err
+	}
+	r := &storedFieldsReader{meta: meta, fdt: fdt, names: make(map[int32]string, len(s.Fields)), chunk: -1}
+	for _, fi := range s.Fields {
+		r.names[fi.Number] = fi.Name
+	}
+	return r, nil
+}
+
+func (r *storedFieldsReader) Close() error {
+	return r.fdt.Close()
+}
+
+// document returns the stored fields of doc in the order they were written.
+func (r *storedFieldsReader) document(doc int) ([]StoredField, error) {
+	if doc < 0 || doc >= int(r.meta.numDocs) {
+		return nil, fmt.Errorf("doc %d out of range [0, %d)", doc, r.meta.numDocs)
+	}
+	chunk := sort.Search(int(r.meta.numChunks), func(i int) bool { return r.meta.docStarts[i+1] > int64(doc) })
+	if chunk != r.chunk {
+		if err := r.loadChunk(chunk); err != nil {
+			r.chunk = -1
+			return nil, err
+		}
+	}
+	i := doc - int(r.meta.docStarts[chunk])
+	start, end := r.offsets[i], r.offsets[i+1]
+	name := fmt.Sprintf("%s[doc %d]", r.fdt.Name(), doc)
+	in := NewDataInput(name, bytes.NewReader(r.data[start:end]), end-start)
+
+	fields := make([]StoredField, 0, r.numStoredFields[i])
+	for j := int64(0); j < r.numStoredFields[i]; j++ {
+		infoAndBits, err := in.ReadVLong()
+		if err != nil {
+			return nil, in.fail(fmt.Sprintf("fields[%d].infoAndBits", j), err)
+		}
+		f := StoredField{Number: int32(infoAndBits >> STORED_FIELD_TYPE_BITS)}
+		f.Name = r.names[f.Number]
+		typ := int(infoAndBits & STORED_FIELD_TYPE_MASK)
+		if typ >= len(storedFieldTypeNames) {
+			return nil, in.fail(fmt.Sprintf("fields[%d].type", j), fmt.Errorf("unknown stored field type %d", typ))
+		}
+		f.Type = storedFieldTypeNames[typ]
+		if f.Value, err = readStoredValue(in, typ); err != nil {
+			return nil, in.fail(fmt.Sprintf("fields[%d].value", j), err)
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// Chunk: DocBase, ChunkDocs<<2|Dirty<<1|Sliced, NumStoredFields, DocLengths, CompressedDocs
+func (r *storedFieldsReader) loadChunk(chunk int) error {
+	in, m := r.fdt, r.meta
+	field := func(name string) string { return fmt.Sprintf("chunks[%d].%s", chunk, name) }
+	if err := in.SeekTo(m.pointers[chunk]); err != nil {
+		return in.fail(field("docBase"), err)
+	}
+	docBase, err := in.ReadVInt()
+	if err != nil {
+		return in.fail(field("docBase"), err)
+	}
+	token, err := in.ReadVInt()
+	if err != nil {
+		return in.fail(field("token"), err)
+	}
+	chunkDocs, sliced := int(token>>2), token&1 != 0
+	if int64(docBase) != m.docStarts[chunk] || int64(chunkDocs) != m.docStarts[chunk+1]-m.docStarts[chunk] {
+		return in.fail(field("token"), fmt.Errorf("chunk holds docs [%d, %d), index says [%d, %d)", docBase, int(docBase)+chunkDocs, m.docStarts[chunk], m.docStarts[chunk+1]))
+	}
+
+	if chunkDocs == 1 {
+		n, err := in.ReadVInt()
+		if err != nil {
+			return in.fail(field("numStoredFields"), err)
+		}
+		length, err := in.ReadVInt()
+		if err != nil {
+			return in.fail(field("length"), err)
+		}
+		r.numStoredFields, r.offsets = []int64{int64(n)}, []int64{0, int64(length)}
+	} else {
+		if r.numStoredFields, err = readStoredFieldsInts(in, chunkDocs); err != nil {
+			return in.fail(field("numStoredFields"), err)
+		}
+		lengths, err := readStoredFieldsInts(in, chunkDocs)
+		if err != nil {
+			return in.fail(field("lengths"), err)
+		}
+		r.offsets = make([]int64, chunkDocs+1)
+		for i, l := range lengths {
+			r.offsets[i+1] = r.offsets[i] + l
+		}
+	}
+
+	total := int(r.offsets[chunkDocs])
+	decompress := decompressLZ4WithPresetDict
+	if m.dataCodec == STORED_FIELDS_HIGH_CODEC {
+		decompress = decompressDeflateWithPresetDict
+	}
+	r.data = r.data[:0]
+	// 超过 2 * chunkSize 的 chunk 按 chunkSize 分片压缩
+	sliceSize := total
+	if sliced {
+		sliceSize = int(m.chunkSize)
+	}
+	for done := 0; done < total; done += sliceSize {
+		b, err := decompress(in, min(sliceSize, total-done))
+		if err != nil {
+			return err
+		}
+		r.data = append(r.data, b...)
+	}
+	if in.Pos() > m.pointers[chunk+1] {
+		return in.fail(field("data"), fmt.Errorf("chunk data ends at %d, past the next chunk at %d", in.Pos(), m.pointers[chunk+1]))
+	}
+	r.chunk = chunk
+	return nil
+}
+
+// readStoredFieldsInts reverses StoredFieldsInts.writeInts: a bits-per-value
+// byte (0 = all equal, 8, 16 or 32), then blocks of 128 values interleaved
+// into longs, then the remainder one value at a time.
+func readStoredFieldsInts(in *DataInput, count int) ([]int64, error) {
+	bpv, err := in.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]int64, count)
+	if bpv == 0 {
+		v, err := in.ReadVInt()
+		if err != nil {
+			return nil, err
+		}
+		for i := range values {
+			values[i] = int64(v)
+		}
+		return values, nil
+	}
+	if bpv != 8 && bpv != 16 && bpv != 32 {
+		return nil, fmt.Errorf("unsupported number of bits per value %d", bpv)
+	}
+	perLong := 64 / int(bpv)
+	longs := 128 / perLong
+	mask := int64(1)<<bpv - 1
+	k := 0
+	for ; k+128 <= count; k += 128 {
+		for i := 0; i < longs; i++ {
+			l, err := in.ReadLong()
+			if err != nil {
+				return nil, err
+			}
+			for j := 0; j < perLong; j++ {
+				values[k+j*longs+i] = l >> (64 - int(bpv)*(j+1)) & mask
+			}
+		}
+	}
+	for ; k < count; k++ {
+		var v int64
+		switch bpv {
+		case 8:
+			b, e := in.ReadByte()
+			v, err = int64(b), e
+		case 16:
+			s, e := in.ReadShort()
+			v, err = int64(s), e
+		case 32:
+			i, e := in.ReadInt()
+			v, err = int64(i), e
+		}
+		if err != nil {
+			return nil, err
+		}
+		values[k] = v & mask
+	}
+	return values, nil
+}
+
+func readStoredValue(in *DataInput, typ int) (any, error) {
+	switch storedFieldTypeNames[typ] {
+	case "string":
+		return in.ReadString()
+	case "binary":
+		n, err := in.ReadVInt()
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 || int64(n) > in.Length()-in.Pos() {
+			return nil, fmt.Errorf("invalid binary length %d", n)
+		}
+		b := make([]byte, n)
+		return b, in.ReadBytes(b)
+	case "int":
+		return in.ReadZInt()
+	case "float":
+		return readZFloat(in)
+	case "long":
+		return readTLong(in)
+	default:
+		return readZDouble(in)
+	}
+}
+
+// readZFloat: 0xFF + raw bits for negative values, 0x80|v+1 for small
+// integers in [-1, 125], otherwise the 4 bytes of a positive float.
+func readZFloat(in *DataInput) (float32, error) {
+	b, err := in.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case b == 0xFF:
+		bits, err := in.ReadInt()
+		return math.Float32frombits(uint32(bits)), err
+	case b&0x80 != 0:
+		return float32(int(b&0x7F) - 1), nil
+	}
+	s, err := in.ReadShort()
+	if err != nil {
+		return 0, err
+	}
+	low, err := in.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	return math.Float32frombits(uint32(b)<<24 | uint32(uint16(s))<<8 | uint32(low)), nil
+}
+
+// readZDouble: like readZFloat, plus 0xFE for doubles that are exact floats.
+func readZDouble(in *DataInput) (float64, error) {
+	b, err := in.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case b == 0xFF:
+		bits, err := in.ReadLong()
+		return math.Float64frombits(uint64(bits)), err
+	case b == 0xFE:
+		bits, err := in.ReadInt()
+		return float64(math.Float32frombits(uint32(bits))), err
+	case b&0x80 != 0:
+		return float64(int(b&0x7F) - 1), nil
+	}
+	i, err := in.ReadInt()
+	if err != nil {
+		return 0, err
+	}
+	s, err := in.ReadShort()
+	if err != nil {
+		return 0, err
+	}
+	low, err := in.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	return math.Float64frombits(uint64(b)<<56 | uint64(uint32(i))<<24 | uint64(uint16(s))<<8 | uint64(low)), nil
+}
+
+// readTLong: header byte with 2 bits of time unit, a continuation bit and
+// the 5 low bits of the zig-zag encoded value, followed by a vLong.
+func readTLong(in *DataInput) (int64, error) {
+	header, err := in.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	bits := int64(header & 0x1F)
+	if header&0x20 != 0 {
+		high, err := in.ReadVLong()
+		if err != nil {
+			return 0, err
+		}
+		bits |= high << 5
+	}
+	l := zigZagDecode(bits)
+	switch header & 0xC0 {
+	case 0x40:
+		l *= TLONG_SECOND
+	case 0x80:
+		l *= TLONG_HOUR
+	case 0xC0:
+		l *= TLONG_DAY
+	}
+	return l, nil
+}
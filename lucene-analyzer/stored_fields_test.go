@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// writeStoredFieldsInts mirrors StoredFieldsInts.writeInts for bpv 8, 16 and 32
+func writeStoredFieldsInts(buf *bytes.Buffer, values []int64, bpv int) {
+	buf.WriteByte(byte(bpv))
+	perLong, longs := 64/bpv, 128/(64/bpv)
+	k := 0
+	for ; k+128 <= len(values); k += 128 {
+		for i := 0; i < longs; i++ {
+			var l uint64
+			for j := 0; j < perLong; j++ {
+				l |= uint64(values[k+j*longs+i]) << (64 - bpv*(j+1))
+			}
+			binary.Write(buf, binary.LittleEndian, l)
+		}
+	}
+	for ; k < len(values); k++ {
+		switch bpv {
+		case 8:
+			buf.WriteByte(byte(values[k]))
+		case 16:
+			binary.Write(buf, binary.LittleEndian, uint16(values[k]))
+		case 32:
+			binary.Write(buf, binary.LittleEndian, uint32(values[k]))
+		}
+	}
+}
+
+// TestReadStoredFieldsInts tests the constant form and the 8/16/32-bit block layouts
+func TestReadStoredFieldsInts(t *testing.T) {
+	var constant bytes.Buffer
+	constant.WriteByte(0)
+	writeVIntBytes(&constant, 7)
+	got, err := readStoredFieldsInts(newTestInput(constant.Bytes()), 3)
+	if err != nil || len(got) != 3 || got[0] != 7 || got[2] != 7 {
+		t.Errorf("readStoredFieldsInts() = %v, %v, want [7 7 7]", got, err)
+	}
+
+	for _, bpv := range []int{8, 16, 32} {
+		// 一个完整的 128 值块加上 5 个余数
+		values := make([]int64, 133)
+		for i := range values {
+			values[i] = int64(i*37) & (1<<bpv - 1)
+		}
+		values[0] = 1<<bpv - 1
+		var buf bytes.Buffer
+		writeStoredFieldsInts(&buf, values, bpv)
+		in := newTestInput(buf.Bytes())
+		got, err := readStoredFieldsInts(in, len(values))
+		if err != nil {
+			t.Fatalf("readStoredFieldsInts(bpv=%d) error = %v", bpv, err)
+		}
+		for i := range values {
+			if got[i] != values[i] {
+				t.Errorf("readStoredFieldsInts(bpv=%d)[%d] = %d, want %d", bpv, i, got[i], values[i])
+				break
+			}
+		}
+		if in.Pos() != int64(buf.Len()) {
+			t.Errorf("readStoredFieldsInts(bpv=%d) Pos() = %d, want %d", bpv, in.Pos(), buf.Len())
+		}
+	}
+}
+
+// TestReadStoredValues tests the compact float, double and timestamp encodings
+func TestReadStoredValues(t *testing.T) {
+	floats := []struct {
+		input []byte
+		want  float32
+	}{
+		{[]byte{0x86}, 5},
+		{[]byte{0x80}, -1},
+		{[]byte{0x40, 0xF5, 0x48, 0xC3}, 3.14},
+		{[]byte{0xFF, 0x00, 0x00, 0x20, 0xC0}, -2.5},
+	}
+	for _, tt := range floats {
+		if got, err := readZFloat(newTestInput(tt.input)); err != nil || got != tt.want {
+			t.Errorf("readZFloat(% x) = %v, %v, want %v", tt.input, got, err, tt.want)
+		}
+	}
+
+	doubles := []struct {
+		input []byte
+		want  float64
+	}{
+		{[]byte{0x81}, 0},
+		{[]byte{0xFE, 0x00, 0x00, 0xC0, 0x3F}, 1.5},
+		{[]byte{0x3F, 0x99, 0x99, 0x99, 0xB9, 0x99, 0x99, 0x9A}, 0.1},
+		{[]byte{0xFF, 0x9A, 0x99, 0x99, 0x99, 0x99, 0x99, 0xB9, 0xBF}, -0.1},
+	}
+	for _, tt := range doubles {
+		if got, err := readZDouble(newTestInput(tt.input)); err != nil || got != tt.want {
+			t.Errorf("readZDouble(% x) = %v, %v, want %v", tt.input, got, err, tt.want)
+		}
+	}
+
+	var seconds bytes.Buffer
+	seconds.WriteByte(0x60) // seconds, more bits follow, low bits 0
+	writeVLongBytes(&seconds, 106250000)
+	longs := []struct {
+		input []byte
+		want  int64
+	}{
+		{seconds.Bytes(), 1700000000000},
+		{[]byte{0xC1}, -86400000},
+		{[]byte{0x06}, 3},
+		{[]byte{0x81}, -3600000},
+	}
+	for _, tt := range longs {
+		if got, err := readTLong(newTestInput(tt.input)); err != nil || got != tt.want {
+			t.Errorf("readTLong(% x) = %v, %v, want %v", tt.input, got, err, tt.want)
+		}
+	}
+}
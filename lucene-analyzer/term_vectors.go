@@ -0,0 +1,309 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// ---------- term vectors (.tvm/.tvx/.tvd) per Lucene90CompressingTermVectorsFormat ----------
+
+const (
+	TERM_VECTORS_META_CODEC = "Lucene90TermVectorsIndexMeta"
+	TERM_VECTORS_IDX_CODEC  = "Lucene90TermVectorsIndexIdx"
+	TERM_VECTORS_DATA_CODEC = "Lucene90TermVectorsData"
+	TERM_VECTORS_VERSION    = 0
+
+	TERM_VECTORS_PACKED_BLOCK_SIZE = 64 // BlockPackedWriter 的块大小
+	TERM_VECTORS_MAX_DOCS_CHUNK    = 128
+
+	// 每个字段在 chunk 中的 flags
+	TERM_VECTORS_POSITIONS  = 0x01
+	TERM_VECTORS_OFFSETS    = 0x02
+	TERM_VECTORS_PAYLOADS   = 0x04
+	TERM_VECTORS_FLAGS_BITS = 3
+
+	// 超过段大小的该比例时建议去掉 term_vector
+	TERM_VECTORS_EXPENSIVE_PERCENT = 10
+)
+
+// TermVectorsInfo reports the size and content of a segment's term vectors.
+type TermVectorsInfo struct {
+	ChunkSize       int32             `json:"chunk_size"`
+	NumChunks       int64             `json:"num_chunks"`
+	DirtyChunks     int64             `json:"dirty_chunks"`
+	DirtyDocs       int64             `json:"dirty_docs"`
+	DocsPerChunk    ValueStats        `json:"docs_per_chunk"`
+	ChunkBytes      ValueStats        `json:"chunk_compressed_bytes"`
+	CompressedBytes int64             `json:"compressed_bytes"` // all chunks in .tvd
+	AvgBytesPerDoc  float64           `json:"avg_compressed_bytes_per_doc"`
+	SizeBytes       int64             `json:"size_bytes"`   // .tvd, .tvx and .tvm
+	SizePercent     float64           `json:"size_percent"` // of the segment
+	Fields          []TermVectorField `json:"fields"`
+	Note            string            `json:"note,omitempty"`
+}
+
+// TermVectorField describes what the term vectors of one field store.
+type TermVectorField struct {
+	Name      string `json:"name"`
+	DocCount  int64  `json:"doc_count"` // documents with term vectors for this field
+	NumTerms  int64  `json:"num_terms"` // summed over those documents
+	Positions bool   `json:"positions"`
+	Offsets   bool   `json:"offsets"`
+	Payloads  bool   `json:"payloads"`
+}
+
+// parseTermVectors 解析 .tvm/.tvx 以及 .tvd 中每个 chunk 未压缩的头部
+// .tvm: Header, PackedIntsVersion, ChunkSize, FieldsIndex meta, NumChunks, NumDirtyChunks, NumDirtyDocs, Footer
+// 与 stored fields 一样，只处理 Lucene90 及之后的 codec（.si 中带有 stored fields mode 属性）
+func parseTermVectors(segDir Directory, s *SegInfoSummary) (*TermVectorsInfo, error) {
+	if _, ok := s.Attributes[STORED_FIELDS_MODE_ATTR]; !ok {
+		return nil, nil
+	}
+	hasVectors := false
+	for _, fi := range s.Fields {
+		hasVectors = hasVectors || fi.HasTermVectors
+	}
+	if !hasVectors {
+		return nil, nil
+	}
+
+	meta, err := segDir.OpenInput(s.SegName + ".tvm")
+	if err != nil {
+		return nil, err
+	}
+	defer meta.Close()
+	if _, err := checkIndexHeader(meta, TERM_VECTORS_META_CODEC, TERM_VECTORS_VERSION, TERM_VECTORS_VERSION); err != nil {
+		return nil, err
+	}
+	if _, err := meta.ReadVInt(); err != nil {
+		return nil, meta.fail("packedIntsVersion", err)
+	}
+	info := &TermVectorsInfo{}
+	if info.ChunkSize, err = meta.ReadVInt(); err != nil {
+		return nil, meta.fail("chunkSize", err)
+	}
+	index, err := readFieldsIndexMeta(meta)
+	if err != nil {
+		return nil, err
+	}
+	if index.numDocs != s.MaxDoc {
+		return nil, meta.fail("numDocs", fmt.Errorf("numDocs %d does not match segment maxDoc %d", index.numDocs, s.MaxDoc))
+	}
+	if info.NumChunks, err = meta.ReadVLong(); err != nil {
+		return nil, meta.fail("numChunks", err)
+	}
+	if info.NumChunks != index.docs.numValues-1 {
+		return nil, meta.fail("numChunks", fmt.Errorf("chunk count %d does not match %d index entries", info.NumChunks, index.docs.numValues))
+	}
+	if info.DirtyChunks, err = meta.ReadVLong(); err != nil {
+		return nil, meta.fail("numDirtyChunks", err)
+	}
+	if info.DirtyDocs, err = meta.ReadVLong(); err != nil {
+		return nil, meta.fail("numDirtyDocs", err)
+	}
+	docStarts, pointers, err := index.read(segDir, s.SegName+".tvx", TERM_VECTORS_IDX_CODEC)
+	if err != nil {
+		return nil, err
+	}
+
+	tvd, err := segDir.OpenInput(s.SegName + ".tvd")
+	if err != nil {
+		return nil, err
+	}
+	defer tvd.Close()
+	if _, err := checkIndexHeader(tvd, TERM_VECTORS_DATA_CODEC, TERM_VECTORS_VERSION, TERM_VECTORS_VERSION); err != nil {
+		return nil, err
+	}
+	if index.maxPointer > tvd.Length()-FOOTER_LENGTH {
+		return nil, meta.fail("maxPointer", fmt.Errorf("chunks end at %d, beyond %s (%d bytes)", index.maxPointer, tvd.Name(), tvd.Length()))
+	}
+
+	fields := map[int32]*TermVectorField{}
+	docs := make([]int64, info.NumChunks)
+	bytes := make([]int64, info.NumChunks)
+	for i := range docs {
+		docs[i] = docStarts[i+1] - docStarts[i]
+		bytes[i] = pointers[i+1] - pointers[i]
+		if err := readTermVectorsChunkHeader(tvd, i, docStarts[i], pointers[i], fields); err != nil {
+			return nil, err
+		}
+	}
+	info.DocsPerChunk = newValueStats(docs)
+	info.ChunkBytes = newValueStats(bytes)
+	if info.NumChunks > 0 {
+		info.CompressedBytes = pointers[info.NumChunks] - pointers[0]
+	}
+	if s.MaxDoc > 0 {
+		info.AvgBytesPerDoc = math.Round(float64(info.CompressedBytes)*100/float64(s.MaxDoc)) / 100
+	}
+	for _, ext := range []string{".tvd", ".tvx", ".tvm"} {
+		size, err := segDir.FileLength(s.SegName + ext)
+		if err != nil {
+			return nil, err
+		}
+		info.SizeBytes += size
+	}
+
+	info.Fields = make([]TermVectorField, 0, len(fields))
+	for _, fi := range s.Fields {
+		if f, ok := fields[fi.Number]; ok {
+			f.Name = fi.Name
+			info.Fields = append(info.Fields, *f)
+			delete(fields, fi.Number)
+		}
+	}
+	if len(fields) > 0 {
+		numbers := make([]int, 0, len(fields))
+		for n := range fields {
+			numbers = append(numbers, int(n))
+		}
+		sort.Ints(numbers)
+		return nil, fmt.Errorf("%s: term vectors of unknown field numbers %v", tvd.Name(), numbers)
+	}
+	return info, nil
+}
+
+// readTermVectorsChunkHeader 读取 chunk 中压缩数据之前的部分，累加每个字段的文档数、词项数与 flags
+// Chunk: DocBase, ChunkDocs<<1|Dirty, NumFields, FieldNums, FieldNumOffs, Flags, NumTerms, ...（其余部分不读取）
+func readTermVectorsChunkHeader(in *DataInput, chunk int, docBase, pointer int64, fields map[int32]*TermVectorField) error {
+	field := func(name string) string { return fmt.Sprintf("chunks[%d].%s", chunk, name) }
+	if err := in.SeekTo(pointer); err != nil {
+		return in.fail(field("docBase"), err)
+	}
+	base, err := in.ReadVInt()
+	if err != nil {
+		return in.fail(field("docBase"), err)
+	}
+	if int64(base) != docBase {
+		return in.fail(field("docBase"), fmt.Errorf("chunk starts at doc %d, index says %d", base, docBase))
+	}
+	token, err := in.ReadVInt()
+	if err != nil {
+		return in.fail(field("token"), err)
+	}
+	chunkDocs := int(token >> 1)
+	if chunkDocs <= 0 {
+		return in.fail(field("token"), fmt.Errorf("invalid chunk doc count %d", chunkDocs))
+	}
+
+	var numFields []int64
+	if chunkDocs == 1 {
+		n, err := in.ReadVInt()
+		if err != nil {
+			return in.fail(field("numFields"), err)
+		}
+		numFields = []int64{int64(n)}
+	} else if numFields, err = readBlockPackedInts(in, chunkDocs, TERM_VECTORS_PACKED_BLOCK_SIZE); err != nil {
+		return in.fail(field("numFields"), err)
+	}
+	totalFields := 0
+	for _, n := range numFields {
+		if n < 0 {
+			return in.fail(field("numFields"), fmt.Errorf("invalid field count %d", n))
+		}
+		totalFields += int(n)
+	}
+	if totalFields == 0 {
+		return nil
+	}
+
+	// 去重并排序后的字段编号
+	b, err := in.ReadByte()
+	if err != nil {
+		return in.fail(field("fieldNums"), err)
+	}
+	numDistinct := int(b>>5) + 1
+	if numDistinct == 8 {
+		n, err := in.ReadVInt()
+		if err != nil {
+			return in.fail(field("fieldNums"), err)
+		}
+		numDistinct += int(n)
+	}
+	fieldNums, err := readPackedInts(in, numDistinct, int(b&0x1F))
+	if err != nil {
+		return in.fail(field("fieldNums"), err)
+	}
+	fieldNumOffs, err := readPackedInts(in, totalFields, packedBitsRequired(int64(numDistinct-1)))
+	if err != nil {
+		return in.fail(field("fieldNumOffs"), err)
+	}
+	for _, off := range fieldNumOffs {
+		if off >= int64(numDistinct) {
+			return in.fail(field("fieldNumOffs"), fmt.Errorf("field index %d out of range [0, %d)", off, numDistinct))
+		}
+	}
+
+	// flags 可以按字段（所有文档相同）或按每个字段实例存储
+	perField, err := in.ReadVInt()
+	if err != nil {
+		return in.fail(field("flags"), err)
+	}
+	var flags []int64
+	switch perField {
+	case 0:
+		distinct, err := readPackedInts(in, numDistinct, TERM_VECTORS_FLAGS_BITS)
+		if err != nil {
+			return in.fail(field("flags"), err)
+		}
+		flags = make([]int64, totalFields)
+		for i, off := range fieldNumOffs {
+			flags[i] = distinct[off]
+		}
+	case 1:
+		if flags, err = readPackedInts(in, totalFields, TERM_VECTORS_FLAGS_BITS); err != nil {
+			return in.fail(field("flags"), err)
+		}
+	default:
+		return in.fail(field("flags"), fmt.Errorf("invalid flags mode %d", perField))
+	}
+
+	bpv, err := in.ReadVInt()
+	if err != nil {
+		return in.fail(field("numTerms"), err)
+	}
+	numTerms, err := readPackedInts(in, totalFields, int(bpv))
+	if err != nil {
+		return in.fail(field("numTerms"), err)
+	}
+
+	for i, off := range fieldNumOffs {
+		number := int32(fieldNums[off])
+		f, ok := fields[number]
+		if !ok {
+			f = &TermVectorField{}
+			fields[number] = f
+		}
+		f.DocCount++
+		f.NumTerms += numTerms[i]
+		f.Positions = f.Positions || flags[i]&TERM_VECTORS_POSITIONS != 0
+		f.Offsets = f.Offsets || flags[i]&TERM_VECTORS_OFFSETS != 0
+		f.Payloads = f.Payloads || flags[i]&TERM_VECTORS_PAYLOADS != 0
+	}
+	return nil
+}
+
+// packedBitsRequired mirrors PackedInts.bitsRequired: at least one bit.
+func packedBitsRequired(maxValue int64) int {
+	n := 1
+	for maxValue>>n > 0 {
+		n++
+	}
+	return n
+}
+
+// recommend 在 term vectors 占段大小的比例较高时给出建议，需在统计完段大小后调用
+func (tv *TermVectorsInfo) recommend(segmentBytes int64) {
+	tv.SizePercent = percentOf(tv.SizeBytes, segmentBytes)
+	if tv.SizePercent < TERM_VECTORS_EXPENSIVE_PERCENT {
+		return
+	}
+	names := make([]string, len(tv.Fields))
+	for i, f := range tv.Fields {
+		names[i] = f.Name
+	}
+	tv.Note = fmt.Sprintf("term vectors take %.2f%% of this segment; unless highlighting or the term vectors API needs them, "+
+		"consider removing term_vector from the mappings of: %s", tv.SizePercent, strings.Join(names, ", "))
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestParseTermVectors tests the chunk index and chunk headers of two term vectors chunks
+func TestParseTermVectors(t *testing.T) {
+	le := binary.LittleEndian
+	const chunkLength = 24
+	chunks := [][]byte{
+		// docs 0-1：body(1) 在两个文档中有 positions+offsets，tags(2) 在 doc 0 中只有 positions；flags 按字段存储
+		{0, 2 << 1, 0x02, 0x01, 0x80, 0x22, 0x60, 0x40, 0, 0x64, 3, 0xAE, 0x00},
+		// docs 2-3（dirty）：只有 doc 2 的 tags 带 positions+payloads；flags 按字段实例存储
+		{2, 2<<1 | 1, 0x03, 0x80, 0x02, 0x80, 0x00, 1, 0xA0, 2, 0x80},
+	}
+
+	var tvd bytes.Buffer
+	writeIndexHeader(&tvd, TERM_VECTORS_DATA_CODEC, TERM_VECTORS_VERSION, "")
+	firstChunk := int64(tvd.Len())
+	for _, c := range chunks {
+		tvd.Write(c)
+		tvd.Write(make([]byte, chunkLength-len(c)))
+	}
+
+	var tvx bytes.Buffer
+	writeIndexHeader(&tvx, TERM_VECTORS_IDX_CODEC, FIELDS_INDEX_VERSION, "")
+
+	var tvm bytes.Buffer
+	writeIndexHeader(&tvm, TERM_VECTORS_META_CODEC, TERM_VECTORS_VERSION, "")
+	writeVIntBytes(&tvm, 2)    // packedIntsVersion
+	writeVIntBytes(&tvm, 4096) // chunkSize
+	binary.Write(&tvm, le, []int32{4, 10, 3})
+	binary.Write(&tvm, le, int64(tvx.Len()))
+	writeLinearMonotonic(&tvm, 0, 2)
+	binary.Write(&tvm, le, int64(tvx.Len()))
+	writeLinearMonotonic(&tvm, firstChunk, chunkLength)
+	binary.Write(&tvm, le, []int64{int64(tvx.Len()), firstChunk + 2*chunkLength})
+	writeVLongBytes(&tvm, 2) // numChunks
+	writeVLongBytes(&tvm, 1) // numDirtyChunks
+	writeVLongBytes(&tvm, 2) // numDirtyDocs
+
+	tempDir := t.TempDir()
+	files := map[string][]byte{"_0.tvm": withFooter(tvm.Bytes()), "_0.tvx": withFooter(tvx.Bytes()), "_0.tvd": withFooter(tvd.Bytes())}
+	var total int64
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tempDir, name), content, 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+		total += int64(len(content))
+	}
+	s := &SegInfoSummary{SegName: "_0", MaxDoc: 4, Attributes: map[string]string{STORED_FIELDS_MODE_ATTR: "BEST_SPEED"}, Fields: []FieldInfo{
+		{Name: "id", Number: 0},
+		{Name: "body", Number: 1, HasTermVectors: true},
+		{Name: "tags", Number: 2, HasTermVectors: true},
+	}}
+	tv, err := parseTermVectors(FSDirectory{tempDir}, s)
+	if err != nil {
+		t.Fatalf("parseTermVectors() error = %v", err)
+	}
+	if tv == nil || tv.ChunkSize != 4096 || tv.NumChunks != 2 || tv.DirtyChunks != 1 || tv.DirtyDocs != 2 ||
+		tv.DocsPerChunk != (ValueStats{2, 2, 2}) || tv.CompressedBytes != 2*chunkLength || tv.AvgBytesPerDoc != 12 || tv.SizeBytes != total {
+		t.Fatalf("parseTermVectors() = %+v", tv)
+	}
+	want := []TermVectorField{
+		{Name: "body", DocCount: 2, NumTerms: 9, Positions: true, Offsets: true},
+		{Name: "tags", DocCount: 2, NumTerms: 5, Positions: true, Payloads: true},
+	}
+	if len(tv.Fields) != len(want) || tv.Fields[0] != want[0] || tv.Fields[1] != want[1] {
+		t.Errorf("parseTermVectors() fields = %+v, want %+v", tv.Fields, want)
+	}
+
+	tv.recommend(total * 20)
+	if tv.SizePercent != 5 || tv.Note != "" {
+		t.Errorf("recommend() = %v%%, %q, want 5%% and no note", tv.SizePercent, tv.Note)
+	}
+	tv.recommend(total * 2)
+	if tv.SizePercent != 50 || !strings.Contains(tv.Note, "body, tags") {
+		t.Errorf("recommend() = %v%%, %q, want a note naming body and tags", tv.SizePercent, tv.Note)
+	}
+
+	// 没有字段存储 term vectors
+	s.Fields[1].HasTermVectors, s.Fields[2].HasTermVectors = false, false
+	if tv, err := parseTermVectors(FSDirectory{tempDir}, s); tv != nil || err != nil {
+		t.Errorf("parseTermVectors() = %+v, %v, want nil", tv, err)
+	}
+
+	// chunk 的 docBase 与 .tvx 不一致
+	s.Fields[1].HasTermVectors = true
+	data := files["_0.tvd"]
+	data[firstChunk+chunkLength] = 3
+	os.WriteFile(filepath.Join(tempDir, "_0.tvd"), data, 0644)
+	if _, err := parseTermVectors(FSDirectory{tempDir}, s); err == nil || !strings.Contains(err.Error(), "index says 2") {
+		t.Errorf("parseTermVectors() error = %v, want a docBase mismatch", err)
+	}
+}
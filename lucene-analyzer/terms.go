@@ -0,0 +1,633 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ---------- terms dictionary metadata (.tmd) per Lucene90/Lucene103 BlockTreeTermsWriter ----------
+
+const (
+	TERMS_META_CODEC               = "BlockTreeTermsMeta"
+	TERMS_VERSION                  = 0
+	TERMS_VERSION_MSB_VLONG_OUTPUT = 1 // Lucene 9.10: FST outputs are MSB-first vLongs
+	TERMS_VERSION_CURR             = 2 // Lucene 9.12: continuous FST arcs
+
+	FST_CODEC                    = "FST"
+	FST_VERSION_START            = 6
+	FST_VERSION_NO_NODE_ARC_CNTS = 7
+	FST_VERSION_CURRENT          = 9
+
+	POSTINGS_BLOCK_SIZE = 128
+
+	PER_FIELD_POSTINGS_FORMAT_ATTR = "PerFieldPostingsFormat.format"
+	PER_FIELD_POSTINGS_SUFFIX_ATTR = "PerFieldPostingsFormat.suffix"
+
+	TERMS_INDEX_FST  = "fst"
+	TERMS_INDEX_TRIE = "trie"
+
+	BLOCK_OUTPUT_FLAGS_BITS = 2 // fst/trie outputs: fp << 2 | hasTerms << 1 | isFloor
+
+	// .tim 块中后缀的压缩方式
+	SUFFIX_NO_COMPRESSION  = 0
+	SUFFIX_LOWERCASE_ASCII = 1
+	SUFFIX_LZ4             = 2
+
+	// trie 节点头部的低两位
+	TRIE_SIGN_NO_CHILDREN   = 0
+	TRIE_SIGN_SINGLE_OUTPUT = 1 // one child, with output
+	TRIE_SIGN_MULTI_CHILD   = 3
+)
+
+// postingsFormats maps the block-tree based postings formats to the codec
+// of the postings writer header embedded in .tmd. Lucene103 replaced the FST
+// terms index with a trie.
+var postingsFormats = map[string]string{
+	"Lucene90":      "Lucene90PostingsWriterTerms",
+	"Lucene99":      "Lucene99PostingsWriterTerms",
+	"Lucene912":     "Lucene912PostingsWriterTerms",
+	"Lucene101":     "Lucene101PostingsWriterTerms",
+	"Lucene103":     "Lucene103PostingsWriterTerms",
+	"ES812Postings": "ES812PostingsWriterTerms",
+}
+
+// FieldPostings holds the statistics the terms dictionary keeps per field.
+type FieldPostings struct {
+	NumTerms         int64  `json:"num_terms"`
+	SumTotalTermFreq int64  `json:"sum_total_term_freq"` // equals sum_doc_freq when frequencies are omitted
+	SumDocFreq       int64  `json:"sum_doc_freq"`
+	DocCount         int32  `json:"doc_count"`
+	MinTerm          string `json:"min_term"`
+	MaxTerm          string `json:"max_term"`
+	TermsEncoding    string `json:"terms_encoding,omitempty"` // "hex" when min/max terms are not printable text
+	IndexType        string `json:"index_type"`               // fst (Lucene 9.0 - 10.2) or trie (Lucene 10.3+)
+	IndexSizeBytes   int64  `json:"index_size_bytes"`         // the field's terms index in .tip
+
+	Positions          bool    `json:"positions"`
+	Offsets            bool    `json:"offsets"`
+	Payloads           bool    `json:"payloads"`
+	AvgPositionsPerDoc float64 `json:"avg_positions_per_doc,omitempty"` // sum_total_term_freq / doc_count, with positions only
+
+	Stats *PostingsStats `json:"stats,omitempty"` // only with ?postings_stats=true
+
+	// 枚举词项的入口，不输出
+	rootBlockFP int64 // fst: root block in .tim, decoded from the root code
+	rootNodeFP  int64 // trie: root node in .tip, -1 for fst
+}
+
+// PostingsStats come from walking every term of the field, see parsePostingsStats.
+type PostingsStats struct {
+	SingletonTerms   int64   `json:"singleton_terms"`   // docFreq == 1: the doc ID is inlined in .tim, nothing goes to .doc
+	SingletonPercent float64 `json:"singleton_percent"` // of num_terms
+	DocBytes         int64   `json:"doc_bytes"`
+	PosBytes         int64   `json:"pos_bytes,omitempty"`
+	PayBytes         int64   `json:"pay_bytes,omitempty"` // payloads and offsets
+	SizeBytes        int64   `json:"size_bytes"`          // doc_bytes + pos_bytes + pay_bytes
+}
+
+// parsePostings reads the .tmd of every postings format used by the
+// segment's fields and fills fields[].postings. Fields of formats that are
+// not block-tree based (completion, bloom filters, ...) are left out.
+func parsePostings(segDir Directory, s *SegInfoSummary) error {
+	done := map[string]bool{}
+	for _, fi := range s.Fields {
+		format, suffix := fi.Attributes[PER_FIELD_POSTINGS_FORMAT_ATTR], fi.Attributes[PER_FIELD_POSTINGS_SUFFIX_ATTR]
+		if fi.IndexOptions == "NONE" || postingsFormats[format] == "" || suffix == "" || done[format+"_"+suffix] {
+			continue
+		}
+		done[format+"_"+suffix] = true
+		if err := parseTermsMeta(segDir, s, format, format+"_"+suffix); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readVLongOutput reads a block code stored as an FST output, which is a
+// vLong before TERMS_VERSION_MSB_VLONG_OUTPUT and an MSB-first vLong from it on.
+func readVLongOutput(in *DataInput, version int32) (int64, error) {
+	if version < TERMS_VERSION_MSB_VLONG_OUTPUT {
+		return in.ReadVLong()
+	}
+	in.mark = in.pos
+	var v int64
+	for i := 0; i < 10; i++ {
+		b, err := in.readByte()
+		if err != nil {
+			return 0, err
+		}
+		v = v<<7 | int64(b&0x7F)
+		if b&0x80 == 0 {
+			return v, nil
+		}
+	}
+	return 0, fmt.Errorf("MSB vLong longer than 10 bytes")
+}
+
+// parseTermsMeta 解析一个 .tmd 文件
+// Meta: Header, PostingsHeader, BlockSize, NumFields, <FieldStats, IndexMeta>NumFields, IndexLength, TermsLength, Footer
+// FieldStats: Field, NumTerms, [RootCode], SumTotalTermFreq, [SumDocFreq], DocCount, MinTerm, MaxTerm
+// IndexMeta: IndexStartFP, then FST metadata (Lucene90) or RootFP, IndexEndFP (Lucene103)
+func parseTermsMeta(segDir Directory, s *SegInfoSummary, format, segmentSuffix string) error {
+	base := s.SegName + "_" + segmentSuffix
+	in, err := segDir.OpenInput(base + ".tmd")
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	hdr, err := checkIndexHeader(in, TERMS_META_CODEC, TERMS_VERSION, TERMS_VERSION_CURR)
+	if err != nil {
+		return err
+	}
+	if _, err := checkIndexHeader(in, postingsFormats[format], 0, 1); err != nil {
+		return err
+	}
+	blockSize, err := in.ReadVInt()
+	if err != nil {
+		return in.fail("blockSize", err)
+	}
+	if blockSize != POSTINGS_BLOCK_SIZE {
+		return in.fail("blockSize", fmt.Errorf("index-time block size %d, expected %d", blockSize, POSTINGS_BLOCK_SIZE))
+	}
+	numFields, err := in.ReadVInt()
+	if err != nil {
+		return in.fail("numFields", err)
+	}
+	if numFields < 0 {
+		return in.fail("numFields", fmt.Errorf("invalid field count %d", numFields))
+	}
+
+	byNumber := make(map[int32]int, len(s.Fields))
+	for i, fi := range s.Fields {
+		byNumber[fi.Number] = i
+	}
+	trie := format == "Lucene103"
+	for i := int32(0); i < numFields; i++ {
+		m := &metaReader{in: in, prefix: fmt.Sprintf("fields[%d].", i)}
+		number := m.vInt("field")
+		numTerms := m.vLong("numTerms")
+		if m.err != nil {
+			return m.err
+		}
+		idx, ok := byNumber[number]
+		if !ok {
+			return in.fail(m.prefix+"field", fmt.Errorf("unknown field number %d", number))
+		}
+		fi := &s.Fields[idx]
+		if numTerms <= 0 {
+			return in.fail(m.prefix+"numTerms", fmt.Errorf("illegal numTerms %d for field %s", numTerms, fi.Name))
+		}
+		p := &FieldPostings{NumTerms: numTerms, rootNodeFP: -1}
+		if !trie {
+			// 根块的 code: fp << 2 | hasTerms << 1 | isFloor，后接 floor 数据
+			if code := m.bytesRef("rootCode"); m.err == nil {
+				v, err := readVLongOutput(NewDataInput("rootCode", bytes.NewReader(code), int64(len(code))), hdr.Version)
+				if err != nil {
+					return in.fail(m.prefix+"rootCode", err)
+				}
+				p.rootBlockFP = int64(uint64(v) >> BLOCK_OUTPUT_FLAGS_BITS)
+			}
+		}
+		p.SumTotalTermFreq = m.vLong("sumTotalTermFreq")
+		p.SumDocFreq = p.SumTotalTermFreq
+		if fi.IndexOptions != "DOCS" {
+			p.SumDocFreq = m.vLong("sumDocFreq")
+		}
+		p.DocCount = m.vInt("docCount")
+		minTerm, maxTerm := m.bytesRef("minTerm"), m.bytesRef("maxTerm")
+		indexStart := m.vLong("indexStartFP")
+		if m.err != nil {
+			return m.err
+		}
+		if p.DocCount < 0 || p.DocCount > s.MaxDoc {
+			return in.fail(m.prefix+"docCount", fmt.Errorf("invalid docCount %d for field %s (maxDoc %d)", p.DocCount, fi.Name, s.MaxDoc))
+		}
+		if p.SumDocFreq < int64(p.DocCount) || p.SumTotalTermFreq < p.SumDocFreq {
+			return in.fail(m.prefix+"sumDocFreq", fmt.Errorf("inconsistent statistics for field %s: sumTotalTermFreq %d, sumDocFreq %d, docCount %d", fi.Name, p.SumTotalTermFreq, p.SumDocFreq, p.DocCount))
+		}
+		p.MinTerm, p.MaxTerm, p.TermsEncoding = termStrings(fi.Name, minTerm, maxTerm)
+		p.Positions = fi.IndexOptions == "DOCS_AND_FREQS_AND_POSITIONS" || fi.IndexOptions == "DOCS_AND_FREQS_AND_POSITIONS_AND_OFFSETS"
+		p.Offsets = fi.IndexOptions == "DOCS_AND_FREQS_AND_POSITIONS_AND_OFFSETS"
+		p.Payloads = p.Positions && fi.HasPayloads
+		if p.Positions && p.DocCount > 0 {
+			p.AvgPositionsPerDoc = math.Round(float64(p.SumTotalTermFreq)*100/float64(p.DocCount)) / 100
+		}
+
+		if trie {
+			p.IndexType = TERMS_INDEX_TRIE
+			p.rootNodeFP = indexStart + m.vLong("rootFP")
+			if end := m.vLong("indexEndFP"); m.err == nil {
+				p.IndexSizeBytes = end - indexStart
+			}
+		} else {
+			p.IndexType = TERMS_INDEX_FST
+			p.IndexSizeBytes, err = readFSTMetadata(in, m.prefix+"index")
+			if err != nil {
+				return err
+			}
+		}
+		if m.err != nil {
+			return m.err
+		}
+		if indexStart < 0 || p.IndexSizeBytes < 0 {
+			return in.fail(m.prefix+"indexStartFP", fmt.Errorf("invalid terms index [%d, %d) for field %s", indexStart, indexStart+p.IndexSizeBytes, fi.Name))
+		}
+		fi.Postings = p
+	}
+
+	// .tip 和 .tim 的总长度，用于校验文件未被截断
+	for _, ext := range []string{".tip", ".tim"} {
+		want, err := in.ReadLong()
+		if err != nil {
+			return in.fail(ext[1:]+"Length", err)
+		}
+		got, err := segDir.FileLength(base + ext)
+		if err != nil {
+			return err
+		}
+		if got != want {
+			return in.fail(ext[1:]+"Length", fmt.Errorf("%s%s is %d bytes, expected %d", base, ext, got, want))
+		}
+	}
+	return nil
+}
+
+// readFSTMetadata reads FST.readMetadata and returns the size of the FST.
+// FSTMeta: Header, HasEmptyOutput, [EmptyOutputLength, EmptyOutput], InputType, StartNode, [3 x vLong], NumBytes
+func readFSTMetadata(in *DataInput, field string) (int64, error) {
+	hdr, err := checkHeader(in, FST_CODEC, FST_VERSION_START, FST_VERSION_CURRENT)
+	if err != nil {
+		return 0, err
+	}
+	m := &metaReader{in: in, prefix: field + "."}
+	if m.byte("hasEmptyOutput") == 1 {
+		// 空输出即根块的 code
+		n := m.vInt("emptyOutputLength")
+		if m.err == nil {
+			if n < 0 {
+				return 0, in.fail(m.prefix+"emptyOutputLength", fmt.Errorf("invalid length %d", n))
+			}
+			if err := in.SkipBytes(int64(n)); err != nil {
+				return 0, in.fail(m.prefix+"emptyOutput", err)
+			}
+		}
+	}
+	if t := m.byte("inputType"); m.err == nil && t > 2 {
+		return 0, in.fail(m.prefix+"inputType", fmt.Errorf("invalid input type %d", t))
+	}
+	m.vLong("startNode")
+	if hdr.Version < FST_VERSION_NO_NODE_ARC_CNTS {
+		m.vLong("nodeCount")
+		m.vLong("arcCount")
+		m.vLong("arcWithOutputCount")
+	}
+	numBytes := m.vLong("numBytes")
+	return numBytes, m.err
+}
+
+func (m *metaReader) bytesRef(field string) []byte {
+	if m.err != nil {
+		return nil
+	}
+	s, err := m.in.ReadString()
+	if err != nil {
+		m.err = m.in.fail(m.prefix+field, err)
+	}
+	return []byte(s)
+}
+
+// termStrings renders the min/max terms: _id terms are decoded as
+// Elasticsearch ids, printable UTF-8 is kept, anything else (encoded
+// numbers, binary) is hex encoded.
+func termStrings(field string, minTerm, maxTerm []byte) (string, string, string) {
+	if field == ES_ID_FIELD {
+		min, errMin := decodeUid(minTerm)
+		max, errMax := decodeUid(maxTerm)
+		if errMin == nil && errMax == nil {
+			return min, max, ""
+		}
+		return hex.EncodeToString(minTerm), hex.EncodeToString(maxTerm), "hex"
+	}
+	if printable(minTerm) && printable(maxTerm) {
+		return string(minTerm), string(maxTerm), ""
+	}
+	return hex.EncodeToString(minTerm), hex.EncodeToString(maxTerm), "hex"
+}
+
+func printable(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	for _, r := range string(b) {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// termString renders a single term the way termStrings renders min/max terms.
+func termString(field string, term []byte) (string, string) {
+	if field == ES_ID_FIELD {
+		if id, err := decodeUid(term); err == nil {
+			return id, ""
+		}
+		return hex.EncodeToString(term), "hex"
+	}
+	if printable(term) {
+		return string(term), ""
+	}
+	return hex.EncodeToString(term), "hex"
+}
+
+// ---------- term enumeration: depth-first walk of the .tim blocks ----------
+
+// termsEnum iterates the terms of one field in order. Blocks are reached
+// from the root block through their sub-block entries and the floor blocks
+// of a block directly follow it in .tim, so the terms index is only needed
+// to locate the root block.
+type termsEnum struct {
+	tim      *DataInput
+	field    string
+	hasFreqs bool
+	numTerms int64
+	prefix   []byte // only terms starting with prefix are returned
+	frames   []termsFrame
+	count    int64 // terms visited, checked against numTerms at the end
+
+	term          []byte
+	docFreq       int64
+	totalTermFreq int64
+}
+
+// termsFrame is a block being walked; loading its next floor block reuses the frame.
+type termsFrame struct {
+	prefixLen   int   // length of the term prefix shared by the block's entries
+	fp          int64 // current floor block
+	fpEnd       int64
+	lastInFloor bool
+	leaf        bool
+	entries     int
+	next        int
+	suffixes    *DataInput
+	lengths     *DataInput
+	stats       *DataInput
+	meta        *DataInput // postings file pointers of the block's terms
+	singletons  int        // remaining terms of a docFreq == 1 run
+}
+
+// openTermsEnum opens the terms of fi, whose postings were read by parsePostings.
+func openTermsEnum(segDir Directory, s *SegInfoSummary, fi *FieldInfo, prefix []byte) (*termsEnum, error) {
+	p := fi.Postings
+	if p == nil {
+		return nil, fmt.Errorf("field %s has no terms in segment %s", fi.Name, s.SegName)
+	}
+	base := s.SegName + "_" + fi.Attributes[PER_FIELD_POSTINGS_FORMAT_ATTR] + "_" + fi.Attributes[PER_FIELD_POSTINGS_SUFFIX_ATTR]
+	root := p.rootBlockFP
+	if p.rootNodeFP >= 0 {
+		var err error
+		if root, err = readTrieRoot(segDir, base+".tip", p.rootNodeFP); err != nil {
+			return nil, err
+		}
+	}
+	tim, err := segDir.OpenInput(base + ".tim")
+	if err != nil {
+		return nil, err
+	}
+	e := &termsEnum{tim: tim, field: fi.Name, hasFreqs: fi.IndexOptions != "DOCS", numTerms: p.NumTerms, prefix: prefix}
+	e.frames = append(e.frames, termsFrame{})
+	if err := e.load(&e.frames[0], root); err != nil {
+		tim.Close()
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *termsEnum) Close() error { return e.tim.Close() }
+
+// readTrieRoot returns the root block of a Lucene103 trie terms index.
+// Node: Header, [Label, ChildFP], [OutputFP], ...; the root always has an
+// output, stored as the plain fp by leaf nodes and as fp << 2 | flags otherwise.
+func readTrieRoot(segDir Directory, name string, fp int64) (int64, error) {
+	in, err := segDir.OpenInput(name)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+	if err := in.SeekTo(fp); err != nil {
+		return 0, in.fail("rootNode", err)
+	}
+	header, err := in.ReadByte()
+	if err != nil {
+		return 0, in.fail("rootNode", err)
+	}
+	var skip, outputBytes int
+	shift := BLOCK_OUTPUT_FLAGS_BITS
+	switch header & 0x03 {
+	case TRIE_SIGN_NO_CHILDREN:
+		outputBytes, shift = int(header>>2&0x07)+1, 0
+	case TRIE_SIGN_SINGLE_OUTPUT:
+		skip, outputBytes = 1+int(header>>2&0x07)+1, int(header>>5&0x07)+1
+	case TRIE_SIGN_MULTI_CHILD:
+		// 三字节头部：sign, childrenFpBytes-1, hasOutput, outputFpBytes-1, ...
+		if header&0x20 == 0 {
+			return 0, in.fail("rootNode", fmt.Errorf("root node has no output"))
+		}
+		b, err := in.ReadByte()
+		if err != nil {
+			return 0, in.fail("rootNode", err)
+		}
+		skip, outputBytes = 1, int((uint16(header)|uint16(b)<<8)>>6&0x07)+1
+	default:
+		return 0, in.fail("rootNode", fmt.Errorf("root node has no output"))
+	}
+	if err := in.SkipBytes(int64(skip)); err != nil {
+		return 0, in.fail("rootNode", err)
+	}
+	b, err := in.next(outputBytes)
+	if err != nil {
+		return 0, in.fail("rootNode.output", err)
+	}
+	var output uint64
+	for i := len(b) - 1; i >= 0; i-- {
+		output = output<<8 | uint64(b[i])
+	}
+	return int64(output >> shift), nil
+}
+
+// load reads the block at fp into f.
+// Block: EntryCount<<1|IsLastInFloor, SuffixToken, Suffixes, SuffixLengths, StatsLength, Stats, MetaLength, Meta
+// SuffixToken: NumSuffixBytes<<3 | IsLeaf<<2 | CompressionAlg
+func (e *termsEnum) load(f *termsFrame, fp int64) error {
+	in := e.tim
+	if err := in.SeekTo(fp); err != nil {
+		return in.fail("block", err)
+	}
+	code, err := in.ReadVInt()
+	if err != nil {
+		return in.fail("block.entryCount", err)
+	}
+	if code>>1 <= 0 {
+		return in.fail("block.entryCount", fmt.Errorf("invalid entry count %d in block at %d", code>>1, fp))
+	}
+	token, err := in.ReadVLong()
+	if err != nil {
+		return in.fail("block.suffixToken", err)
+	}
+	f.fp, f.entries, f.next, f.singletons = fp, int(code>>1), 0, 0
+	f.lastInFloor, f.leaf = code&1 == 1, token&0x04 != 0
+
+	suffixes := make([]byte, token>>3)
+	switch token & 0x03 {
+	case SUFFIX_NO_COMPRESSION:
+		err = in.ReadBytes(suffixes)
+	case SUFFIX_LOWERCASE_ASCII:
+		err = lowercaseAsciiDecompress(in, suffixes)
+	case SUFFIX_LZ4:
+		err = lz4Decompress(in, len(suffixes), suffixes, 0)
+	default:
+		err = fmt.Errorf("unknown suffix compression %d", token&0x03)
+	}
+	if err != nil {
+		return in.fail("block.suffixes", err)
+	}
+
+	n, err := in.ReadVInt()
+	if err != nil {
+		return in.fail("block.suffixLengths", err)
+	}
+	lengths := make([]byte, n>>1)
+	if n&1 == 1 { // 所有长度相同
+		b, err := in.ReadByte()
+		if err != nil {
+			return in.fail("block.suffixLengths", err)
+		}
+		for i := range lengths {
+			lengths[i] = b
+		}
+	} else if err := in.ReadBytes(lengths); err != nil {
+		return in.fail("block.suffixLengths", err)
+	}
+
+	n, err = in.ReadVInt()
+	if err != nil {
+		return in.fail("block.statsLength", err)
+	}
+	stats := make([]byte, max(n, 0))
+	if err := in.ReadBytes(stats); err != nil {
+		return in.fail("block.stats", err)
+	}
+	if n, err = in.ReadVInt(); err != nil {
+		return in.fail("block.metaLength", err)
+	}
+	meta := make([]byte, max(n, 0))
+	if err := in.ReadBytes(meta); err != nil {
+		return in.fail("block.meta", err)
+	}
+	f.fpEnd = in.Pos()
+
+	name := fmt.Sprintf("%s@%d", in.Name(), fp)
+	f.suffixes = NewDataInput(name+".suffixes", bytes.NewReader(suffixes), int64(len(suffixes)))
+	f.lengths = NewDataInput(name+".suffixLengths", bytes.NewReader(lengths), int64(len(lengths)))
+	f.stats = NewDataInput(name+".stats", bytes.NewReader(stats), int64(len(stats)))
+	f.meta = NewDataInput(name+".meta", bytes.NewReader(meta), int64(len(meta)))
+	return nil
+}
+
+// next advances to the next term starting with the prefix; it returns
+// false once the terms are exhausted.
+func (e *termsEnum) next() (bool, error) {
+	for len(e.frames) > 0 {
+		f := &e.frames[len(e.frames)-1]
+		if f.next == f.entries {
+			if f.lastInFloor {
+				e.frames = e.frames[:len(e.frames)-1]
+			} else if err := e.load(f, f.fpEnd); err != nil {
+				return false, err
+			}
+			continue
+		}
+		f.next++
+
+		// 非叶子块的条目：SuffixLength<<1 | IsSubBlock，子块后接 fp 差值
+		code, err := f.lengths.ReadVInt()
+		if err != nil {
+			return false, f.lengths.fail("suffixLength", err)
+		}
+		suffixLen, subBlock := int(code), false
+		if !f.leaf {
+			suffixLen, subBlock = int(code>>1), code&1 == 1
+		}
+		if suffixLen < 0 {
+			return false, f.lengths.fail("suffixLength", fmt.Errorf("invalid suffix length %d", suffixLen))
+		}
+		e.term = append(e.term[:f.prefixLen], make([]byte, suffixLen)...)
+		if err := f.suffixes.ReadBytes(e.term[f.prefixLen:]); err != nil {
+			return false, f.suffixes.fail("suffix", err)
+		}
+
+		if subBlock {
+			delta, err := f.lengths.ReadVLong()
+			if err != nil {
+				return false, f.lengths.fail("subBlockDelta", err)
+			}
+			switch {
+			case bytes.HasPrefix(e.term, e.prefix) || bytes.HasPrefix(e.prefix, e.term):
+				e.frames = append(e.frames, termsFrame{prefixLen: len(e.term)})
+				if err := e.load(&e.frames[len(e.frames)-1], f.fp-delta); err != nil {
+					return false, err
+				}
+			case bytes.Compare(e.term, e.prefix) > 0:
+				e.frames = nil
+			}
+			continue
+		}
+
+		if err := e.readStats(f); err != nil {
+			return false, err
+		}
+		e.count++
+		if bytes.HasPrefix(e.term, e.prefix) {
+			return true, nil
+		}
+		if bytes.Compare(e.term, e.prefix) > 0 {
+			e.frames = nil
+		}
+	}
+	if len(e.prefix) == 0 && e.count != e.numTerms {
+		return false, e.tim.fail("terms", fmt.Errorf("field %s has %d terms, expected %d", e.field, e.count, e.numTerms))
+	}
+	return false, nil
+}
+
+// readStats reads the docFreq and totalTermFreq of the current term; runs
+// of terms with docFreq == totalTermFreq == 1 are stored as a single count.
+func (e *termsEnum) readStats(f *termsFrame) error {
+	if f.singletons > 0 {
+		f.singletons--
+		e.docFreq, e.totalTermFreq = 1, 1
+		return nil
+	}
+	token, err := f.stats.ReadVInt()
+	if err != nil {
+		return f.stats.fail("docFreq", err)
+	}
+	if token&1 == 1 {
+		f.singletons = int(token >> 1)
+		e.docFreq, e.totalTermFreq = 1, 1
+		return nil
+	}
+	e.docFreq, e.totalTermFreq = int64(token>>1), int64(token>>1)
+	if e.hasFreqs {
+		delta, err := f.stats.ReadVLong()
+		if err != nil {
+			return f.stats.fail("totalTermFreq", err)
+		}
+		e.totalTermFreq += delta
+	}
+	return nil
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTermsMeta writes _0_<format>_0.tmd with one field, plus .tip/.tim files of matching length
+func writeTermsMeta(t *testing.T, dir, format string, version int32, rootCode string, docCount int) {
+	t.Helper()
+	suffix := format + "_0"
+	var buf bytes.Buffer
+	writeIndexHeader(&buf, TERMS_META_CODEC, version, suffix)
+	writeIndexHeader(&buf, postingsFormats[format], 0, suffix)
+	writeVIntBytes(&buf, POSTINGS_BLOCK_SIZE)
+	writeVIntBytes(&buf, 1)  // numFields
+	writeVIntBytes(&buf, 0)  // field
+	writeVLongBytes(&buf, 3) // numTerms
+	if format != "Lucene103" {
+		writeString(&buf, rootCode)
+	}
+	writeVLongBytes(&buf, 12) // sumTotalTermFreq
+	writeVLongBytes(&buf, 5)  // sumDocFreq
+	writeVIntBytes(&buf, docCount)
+	writeString(&buf, "apple")
+	writeString(&buf, "cherry")
+	writeVLongBytes(&buf, 60) // indexStartFP
+	if format == "Lucene103" {
+		writeVLongBytes(&buf, 30) // rootFP
+		writeVLongBytes(&buf, 100)
+	} else {
+		binary.Write(&buf, binary.BigEndian, int32(CODEC_MAGIC))
+		writeString(&buf, FST_CODEC)
+		binary.Write(&buf, binary.BigEndian, int32(FST_VERSION_CURRENT))
+		buf.WriteByte(1) // has empty output
+		writeString(&buf, "\x07\x01")
+		buf.WriteByte(0)          // BYTE1
+		writeVLongBytes(&buf, 17) // startNode
+		writeVLongBytes(&buf, 40) // numBytes
+	}
+	binary.Write(&buf, binary.LittleEndian, []int64{120, 500})
+
+	files := map[string][]byte{".tmd": withFooter(buf.Bytes()), ".tip": make([]byte, 120), ".tim": make([]byte, 500)}
+	for ext, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, "_0_"+suffix+ext), content, 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+}
+
+// TestParsePostings tests term statistics with both the FST and the trie terms index layouts
+func TestParsePostings(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		format      string
+		version     int32
+		rootCode    string
+		indexType   string
+		rootBlockFP int64
+		rootNodeFP  int64
+	}{
+		{"Lucene99", "Lucene99", TERMS_VERSION, "\x07\x01", TERMS_INDEX_FST, 7 >> 2, -1}, // root code 7: fp 1, has terms, floor
+		// root code 503: fp 125, has terms, floor；9.10 前为 vLong，之后为 MSB vLong
+		{"Lucene99 vLong", "Lucene99", TERMS_VERSION, "\xf7\x03\x01", TERMS_INDEX_FST, 125, -1},
+		{"Lucene99 MSB vLong", "Lucene99", TERMS_VERSION_MSB_VLONG_OUTPUT, "\x83\x77\x01", TERMS_INDEX_FST, 125, -1},
+		{"Lucene103", "Lucene103", TERMS_VERSION_CURR, "", TERMS_INDEX_TRIE, 0, 60 + 30},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			writeTermsMeta(t, tempDir, tt.format, tt.version, tt.rootCode, 4)
+			attrs := map[string]string{PER_FIELD_POSTINGS_FORMAT_ATTR: tt.format, PER_FIELD_POSTINGS_SUFFIX_ATTR: "0"}
+			s := &SegInfoSummary{SegName: "_0", MaxDoc: 10, Fields: []FieldInfo{
+				{Name: "tag", Number: 0, IndexOptions: "DOCS_AND_FREQS", Attributes: attrs},
+				{Name: "price", Number: 1, IndexOptions: "NONE"},
+			}}
+			if err := parsePostings(FSDirectory{tempDir}, s); err != nil {
+				t.Fatalf("parsePostings() error = %v", err)
+			}
+			p := s.Fields[0].Postings
+			if p == nil {
+				t.Fatalf("parsePostings() left fields[0].postings empty")
+			}
+			want := FieldPostings{NumTerms: 3, SumTotalTermFreq: 12, SumDocFreq: 5, DocCount: 4, MinTerm: "apple", MaxTerm: "cherry", IndexType: tt.indexType, IndexSizeBytes: 40,
+				rootBlockFP: tt.rootBlockFP, rootNodeFP: tt.rootNodeFP}
+			if *p != want {
+				t.Errorf("parsePostings() = %+v, want %+v", *p, want)
+			}
+			if s.Fields[1].Postings != nil {
+				t.Errorf("parsePostings() filled postings of an unindexed field")
+			}
+		})
+	}
+
+	// docCount 大于 maxDoc
+	tempDir := t.TempDir()
+	writeTermsMeta(t, tempDir, "Lucene103", TERMS_VERSION_CURR, "", 11)
+	attrs := map[string]string{PER_FIELD_POSTINGS_FORMAT_ATTR: "Lucene103", PER_FIELD_POSTINGS_SUFFIX_ATTR: "0"}
+	s := &SegInfoSummary{SegName: "_0", MaxDoc: 10, Fields: []FieldInfo{{Name: "tag", Number: 0, IndexOptions: "DOCS_AND_FREQS", Attributes: attrs}}}
+	if err := parsePostings(FSDirectory{tempDir}, s); err == nil || !strings.Contains(err.Error(), "docCount") {
+		t.Errorf("parsePostings() error = %v, want a docCount error", err)
+	}
+}
+
+// TestTermStrings tests how min/max terms are rendered
+func TestTermStrings(t *testing.T) {
+	tests := []struct {
+		field      string
+		min, max   []byte
+		wantMin    string
+		wantMax    string
+		wantFormat string
+	}{
+		{"tag", []byte("a"), []byte("zürich"), "a", "zürich", ""},
+		{"count", []byte{0x80, 0x00, 0x00, 0x01}, []byte("b"), "80000001", "62", "hex"},
+		{ES_ID_FIELD, []byte{UID_NUMERIC, 0x1F}, []byte{UID_UTF8, 'x'}, "1", "x", ""},
+	}
+	for _, tt := range tests {
+		gotMin, gotMax, gotFormat := termStrings(tt.field, tt.min, tt.max)
+		if gotMin != tt.wantMin || gotMax != tt.wantMax || gotFormat != tt.wantFormat {
+			t.Errorf("termStrings(%s) = %q, %q, %q, want %q, %q, %q", tt.field, gotMin, gotMax, gotFormat, tt.wantMin, tt.wantMax, tt.wantFormat)
+		}
+	}
+}
+
+// termsBlockEntry is a term (stats in stats) or a sub-block (subFP > 0) of a synthetic .tim block
+type termsBlockEntry struct {
+	suffix string
+	subFP  int64
+}
+
+// writeTermsBlock writes an uncompressed block and returns its fp
+func writeTermsBlock(buf *bytes.Buffer, entries []termsBlockEntry, stats, meta []byte, lastInFloor bool) int64 {
+	fp := int64(buf.Len())
+	leaf := true
+	for _, e := range entries {
+		leaf = leaf && e.subFP == 0
+	}
+	var suffixes, lengths bytes.Buffer
+	for _, e := range entries {
+		suffixes.WriteString(e.suffix)
+		switch {
+		case leaf:
+			writeVIntBytes(&lengths, len(e.suffix))
+		case e.subFP > 0:
+			writeVIntBytes(&lengths, len(e.suffix)<<1|1)
+			writeVLongBytes(&lengths, fp-e.subFP)
+		default:
+			writeVIntBytes(&lengths, len(e.suffix)<<1)
+		}
+	}
+	code := len(entries) << 1
+	if lastInFloor {
+		code |= 1
+	}
+	writeVIntBytes(buf, code)
+	token := int64(suffixes.Len()) << 3
+	if leaf {
+		token |= 0x04
+	}
+	writeVLongBytes(buf, token)
+	buf.Write(suffixes.Bytes())
+	writeVIntBytes(buf, lengths.Len()<<1)
+	buf.Write(lengths.Bytes())
+	writeVIntBytes(buf, len(stats))
+	buf.Write(stats)
+	writeVIntBytes(buf, len(meta))
+	buf.Write(meta)
+	return fp
+}
+
+// TestTermsEnum tests walking sub-blocks and floor blocks with and without a prefix
+func TestTermsEnum(t *testing.T) {
+	// 根块分为两个 floor 块：[a, ab*], [b]；子块 ab 为叶子块 [1, 2, 3]
+	var tim bytes.Buffer
+	writeIndexHeader(&tim, "BlockTreeTermsDict", TERMS_VERSION, "Lucene99_0")
+	sub := writeTermsBlock(&tim, []termsBlockEntry{{suffix: "1"}, {suffix: "2"}, {suffix: "3"}}, []byte{2<<1 | 1}, nil, true)
+	root := writeTermsBlock(&tim, []termsBlockEntry{{suffix: "a"}, {suffix: "ab", subFP: sub}}, []byte{2 << 1, 3}, nil, false)
+	writeTermsBlock(&tim, []termsBlockEntry{{suffix: "b"}}, []byte{4 << 1, 0}, nil, true)
+
+	tempDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tempDir, "_0_Lucene99_0.tim"), withFooter(tim.Bytes()), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	attrs := map[string]string{PER_FIELD_POSTINGS_FORMAT_ATTR: "Lucene99", PER_FIELD_POSTINGS_SUFFIX_ATTR: "0"}
+	fi := &FieldInfo{Name: "tag", IndexOptions: "DOCS_AND_FREQS", Attributes: attrs,
+		Postings: &FieldPostings{NumTerms: 5, rootBlockFP: root, rootNodeFP: -1}}
+	s := &SegInfoSummary{SegName: "_0", MaxDoc: 10, Fields: []FieldInfo{*fi}}
+
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"", []string{"a:2/5", "ab1:1/1", "ab2:1/1", "ab3:1/1", "b:4/4"}},
+		{"ab", []string{"ab1:1/1", "ab2:1/1", "ab3:1/1"}},
+		{"ab3", []string{"ab3:1/1"}},
+		{"b", []string{"b:4/4"}},
+		{"c", nil},
+	}
+	for _, tt := range tests {
+		e, err := openTermsEnum(FSDirectory{tempDir}, s, fi, []byte(tt.prefix))
+		if err != nil {
+			t.Fatalf("openTermsEnum() error = %v", err)
+		}
+		var got []string
+		for {
+			ok, err := e.next()
+			if err != nil {
+				t.Fatalf("next() error = %v", err)
+			}
+			if !ok {
+				break
+			}
+			got = append(got, fmt.Sprintf("%s:%d/%d", e.term, e.docFreq, e.totalTermFreq))
+		}
+		e.Close()
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("terms with prefix %q = %v, want %v", tt.prefix, got, tt.want)
+		}
+	}
+
+	// 词项数与 .tmd 不一致
+	fi.Postings.NumTerms = 6
+	e, err := openTermsEnum(FSDirectory{tempDir}, s, fi, nil)
+	if err != nil {
+		t.Fatalf("openTermsEnum() error = %v", err)
+	}
+	defer e.Close()
+	for ok := true; ok && err == nil; ok, err = e.next() {
+	}
+	if err == nil {
+		t.Errorf("next() with a wrong term count succeeded")
+	}
+}
+
+// TestReadTrieRoot tests locating the root block from leaf, single child and multi children root nodes
+func TestReadTrieRoot(t *testing.T) {
+	tests := []struct {
+		name string
+		node []byte
+		want int64
+	}{
+		{"leaf", []byte{0x28, 0x24, 0x7e, 0x02}, 0x027e24},
+		{"single child", []byte{0x21, 'a', 0x05, 0x13, 0x01}, 0x113 >> 2},
+		{"multi children", []byte{0xa7, 0x00, 0x00, 0x07, 0x8b, 0x07}, 0x078b07 >> 2},
+	}
+	for _, tt := range tests {
+		tempDir := t.TempDir()
+		content := append([]byte("pad"), tt.node...)
+		if err := os.WriteFile(filepath.Join(tempDir, "_0.tip"), content, 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+		got, err := readTrieRoot(FSDirectory{tempDir}, "_0.tip", 3)
+		if err != nil || got != tt.want {
+			t.Errorf("readTrieRoot(%s) = %d, %v, want %d", tt.name, got, err, tt.want)
+		}
+	}
+}
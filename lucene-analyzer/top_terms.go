@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"container/heap"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// ---------- top terms and term listing across the segments of a commit ----------
+
+const (
+	TOP_TERMS_DEFAULT_SIZE = 10
+	TOP_TERMS_MAX_SIZE     = 1000
+)
+
+// TermStats is a term with its statistics summed over the segments.
+type TermStats struct {
+	Term          string `json:"term"`
+	TermEncoding  string `json:"term_encoding,omitempty"` // "hex" when the term is not printable text
+	DocFreq       int64  `json:"doc_freq"`                // like Lucene's docFreq, includes deleted documents
+	TotalTermFreq int64  `json:"total_term_freq"`
+}
+
+// TopTerms lists the terms of one field with the highest document frequency.
+type TopTerms struct {
+	Field       string      `json:"field"`
+	Size        int         `json:"size"`
+	Segments    int         `json:"segments"`     // segments in which the field has terms
+	UniqueTerms int64       `json:"unique_terms"` // distinct terms over those segments
+	Terms       []TermStats `json:"terms"`
+}
+
+// multiTermsEnum merges the terms of one field from several segments in
+// term order, summing the statistics of equal terms.
+type multiTermsEnum struct {
+	field    string
+	segments int
+	enums    termsEnumHeap
+	dirs     []Directory // 复合段的目录在枚举结束前保持打开
+
+	term          []byte
+	docFreq       int64
+	totalTermFreq int64
+}
+
+type termsEnumHeap []*termsEnum
+
+func (h termsEnumHeap) Len() int           { return len(h) }
+func (h termsEnumHeap) Less(i, j int) bool { return bytes.Compare(h[i].term, h[j].term) < 0 }
+func (h termsEnumHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *termsEnumHeap) Push(x any)        { *h = append(*h, x.(*termsEnum)) }
+func (h *termsEnumHeap) Pop() any {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
+
+// openFieldTerms opens the terms of field starting with prefix in every
+// segment of the latest commit. Only .fnm and the terms dictionary are read.
+func openFieldTerms(indexDir, field string, prefix []byte) (*multiTermsEnum, error) {
+	segFile, err := findLatestSegmentsFile(indexDir)
+	if err != nil {
+		return nil, err
+	}
+	infos, err := parseSegmentsFile(indexDir, segFile)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &multiTermsEnum{field: field}
+	dir := FSDirectory{indexDir}
+	for i := range infos.Segments {
+		s := &infos.Segments[i]
+		if err := m.add(dir, s, prefix); err != nil {
+			m.Close()
+			return nil, fmt.Errorf("segment %s: %w", s.SegName, err)
+		}
+	}
+	if m.segments == 0 {
+		m.Close()
+		return nil, fmt.Errorf("field %s has no terms in any segment", field)
+	}
+	heap.Init(&m.enums)
+	return m, nil
+}
+
+func (m *multiTermsEnum) add(dir Directory, s *SegInfoSummary, prefix []byte) error {
+	segDir, err := openSegmentDirectory(dir, s)
+	if err != nil {
+		return err
+	}
+	m.dirs = append(m.dirs, segDir)
+	if err := loadFieldInfos(dir, segDir, s); err != nil {
+		return err
+	}
+	if err := parsePostings(segDir, s); err != nil {
+		return err
+	}
+	for i := range s.Fields {
+		fi := &s.Fields[i]
+		if fi.Name != m.field || fi.Postings == nil {
+			continue
+		}
+		e, err := openTermsEnum(segDir, s, fi, prefix)
+		if err != nil {
+			return err
+		}
+		m.segments++
+		ok, err := e.next()
+		if !ok {
+			e.Close()
+			return err
+		}
+		m.enums = append(m.enums, e)
+	}
+	return nil
+}
+
+// next advances to the next distinct term; it returns false once every
+// segment is exhausted.
+func (m *multiTermsEnum) next() (bool, error) {
+	if len(m.enums) == 0 {
+		return false, nil
+	}
+	m.term = append(m.term[:0], m.enums[0].term...)
+	m.docFreq, m.totalTermFreq = 0, 0
+	for len(m.enums) > 0 && bytes.Equal(m.enums[0].term, m.term) {
+		e := m.enums[0]
+		m.docFreq += e.docFreq
+		m.totalTermFreq += e.totalTermFreq
+		ok, err := e.next()
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			heap.Fix(&m.enums, 0)
+		} else {
+			heap.Pop(&m.enums)
+			e.Close()
+		}
+	}
+	return true, nil
+}
+
+func (m *multiTermsEnum) stats() TermStats {
+	term, encoding := termString(m.field, m.term)
+	return TermStats{Term: term, TermEncoding: encoding, DocFreq: m.docFreq, TotalTermFreq: m.totalTermFreq}
+}
+
+func (m *multiTermsEnum) Close() error {
+	for _, e := range m.enums {
+		e.Close()
+	}
+	for _, d := range m.dirs {
+		d.Close()
+	}
+	return nil
+}
+
+// topTerms returns the size terms of field with the highest docFreq over
+// all segments; ties keep the smaller term.
+func topTerms(indexDir, field string, size int) (*TopTerms, error) {
+	if size <= 0 || size > TOP_TERMS_MAX_SIZE {
+		return nil, fmt.Errorf("invalid size %d, must be in [1, %d]", size, TOP_TERMS_MAX_SIZE)
+	}
+	m, err := openFieldTerms(indexDir, field, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer m.Close()
+
+	top := &TopTerms{Field: field, Size: size, Segments: m.segments}
+	// 按 docFreq 的小顶堆，只保留 size 个
+	h := &termStatsHeap{}
+	for {
+		ok, err := m.next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		top.UniqueTerms++
+		if h.Len() < size {
+			heap.Push(h, m.stats())
+		} else if m.docFreq > (*h)[0].DocFreq {
+			(*h)[0] = m.stats()
+			heap.Fix(h, 0)
+		}
+	}
+	top.Terms = *h
+	sort.Slice(top.Terms, func(i, j int) bool {
+		a, b := top.Terms[i], top.Terms[j]
+		return a.DocFreq > b.DocFreq || a.DocFreq == b.DocFreq && a.Term < b.Term
+	})
+	return top, nil
+}
+
+type termStatsHeap []TermStats
+
+func (h termStatsHeap) Len() int { return len(h) }
+func (h termStatsHeap) Less(i, j int) bool {
+	return h[i].DocFreq < h[j].DocFreq || h[i].DocFreq == h[j].DocFreq && h[i].Term > h[j].Term
+}
+func (h termStatsHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *termStatsHeap) Push(x any)   { *h = append(*h, x.(TermStats)) }
+func (h *termStatsHeap) Pop() any {
+	old := *h
+	s := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return s
+}
+
+// writeTerms writes up to limit merged terms (all when limit is 0) to w as
+// NDJSON and returns how many were written.
+func writeTerms(m *multiTermsEnum, limit int, w io.Writer) (int64, error) {
+	enc := json.NewEncoder(w)
+	var n int64
+	for limit == 0 || n < int64(limit) {
+		ok, err := m.next()
+		if err != nil || !ok {
+			return n, err
+		}
+		if err := enc.Encode(m.stats()); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// ---------- KNN vectors (.vemf/.vemq/.vem) per the Lucene99 flat, scalar quantized and HNSW formats ----------
+
+const (
+	// PerFieldKnnVectorsFormat 写入 .fnm 的属性，决定文件名后缀
+	PER_FIELD_KNN_FORMAT_ATTR = "PerFieldKnnVectorsFormat.format"
+	PER_FIELD_KNN_SUFFIX_ATTR = "PerFieldKnnVectorsFormat.suffix"
+
+	FLAT_VECTORS_META_CODEC = "Lucene99FlatVectorsFormatMeta"
+	FLAT_VECTORS_VERSION    = 0
+
+	SQ_VECTORS_META_CODEC       = "Lucene99ScalarQuantizedVectorsFormatMeta"
+	SQ_VECTORS_VERSION_START    = 0 // always 7 bits
+	SQ_VECTORS_VERSION_ADD_BITS = 1
+
+	HNSW_META_CODEC          = "Lucene99HnswVectorsFormatMeta"
+	HNSW_INDEX_CODEC         = "Lucene99HnswVectorsFormatIndex"
+	HNSW_VERSION_START       = 0
+	HNSW_VERSION_GROUPVARINT = 1 // neighbor lists written as group-varint
+)
+
+// knnVectorsParts lists which Lucene99 components a vectors format writes
+// next to the raw vectors of Lucene99FlatVectorsFormat.
+type knnVectorsParts struct {
+	quantized bool // Lucene99ScalarQuantizedVectorsFormat: .vemq/.veq
+	hnsw      bool // Lucene99HnswVectorsFormat graph: .vem/.vex
+}
+
+var knnVectorsFormats = map[string]knnVectorsParts{
+	"Lucene99FlatVectorsFormat":                {},
+	"Lucene99ScalarQuantizedVectorsFormat":     {quantized: true},
+	"Lucene99HnswVectorsFormat":                {hnsw: true},
+	"Lucene99HnswScalarQuantizedVectorsFormat": {quantized: true, hnsw: true},
+	"NativeEngines990KnnVectorsFormat":         {}, // OpenSearch k-NN faiss/nmslib，图在引擎自己的文件中
+}
+
+// FieldVectors describes the KNN vectors of one field.
+type FieldVectors struct {
+	Format       string              `json:"format"`       // per-field vectors format
+	Count        int32               `json:"count"`        // documents with a vector
+	VectorBytes  int64               `json:"vector_bytes"` // raw vectors and their doc IDs in .vec
+	Quantization *VectorQuantization `json:"quantization,omitempty"`
+	HNSW         *HNSWGraph          `json:"hnsw,omitempty"`
+}
+
+// VectorQuantization describes the scalar quantized copy of the vectors.
+type VectorQuantization struct {
+	Bits               int8     `json:"bits"`
+	Compress           bool     `json:"compress"`                      // two 4-bit values per byte
+	ConfidenceInterval *float32 `json:"confidence_interval,omitempty"` // 0 means dynamic, absent means the default 1-1/(dim+1)
+	LowerQuantile      float32  `json:"lower_quantile"`
+	UpperQuantile      float32  `json:"upper_quantile"`
+	SizeBytes          int64    `json:"size_bytes"` // quantized vectors and their doc IDs in .veq
+}
+
+// HNSWGraph describes the HNSW graph of one field. The beam width used at
+// index time is not written to the index.
+type HNSWGraph struct {
+	M             int32     `json:"m"`
+	NumLevels     int32     `json:"num_levels"`
+	NodesPerLevel []int32   `json:"nodes_per_level"`
+	AvgDegree     []float64 `json:"avg_degree"` // neighbors per node on each level
+	SizeBytes     int64     `json:"size_bytes"` // neighbor lists and their offsets in .vex
+}
+
+// parseVectors reads the metadata of every Lucene99 based vectors format
+// used by the segment's fields and fills fields[].vectors. Fields of other
+// formats are left out.
+func parseVectors(segDir Directory, s *SegInfoSummary) error {
+	byNumber := make(map[int32]int, len(s.Fields))
+	for i, fi := range s.Fields {
+		byNumber[fi.Number] = i
+	}
+	done := map[string]bool{}
+	for _, fi := range s.Fields {
+		format, suffix := fi.Attributes[PER_FIELD_KNN_FORMAT_ATTR], fi.Attributes[PER_FIELD_KNN_SUFFIX_ATTR]
+		parts, ok := knnVectorsFormats[format]
+		if fi.VectorDimension == 0 || !ok || suffix == "" || done[format+"_"+suffix] {
+			continue
+		}
+		done[format+"_"+suffix] = true
+		base := s.SegName + "_" + format + "_" + suffix
+		if err := readFlatVectorsMeta(segDir, s, byNumber, base, format); err != nil {
+			return err
+		}
+		if parts.quantized {
+			if err := readQuantizedVectorsMeta(segDir, s, byNumber, base); err != nil {
+				return err
+			}
+		}
+		if parts.hnsw {
+			if err := readHNSWMeta(segDir, s, byNumber, base); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// vectorsEntry is the start every Lucene99 vectors meta entry shares.
+type vectorsEntry struct {
+	fi         *FieldInfo
+	dataOffset int64
+	dataLength int64
+	count      int32
+}
+
+// readVectorsEntry reads the field number and, unless it is the -1 end
+// marker (fi is nil then), checks the entry against the field infos.
+// Entry: FieldNumber(int), Encoding(int), Similarity(int), DataOffset(vLong), DataLength(vLong), Dimension(vInt), Count(int)
+func readVectorsEntry(m *metaReader, s *SegInfoSummary, byNumber map[int32]int) (vectorsEntry, error) {
+	var e vectorsEntry
+	number := m.int("fieldNumber")
+	if m.err != nil || number == -1 {
+		return e, m.err
+	}
+	idx, ok := byNumber[number]
+	if !ok {
+		return e, m.in.fail(m.prefix+"fieldNumber", fmt.Errorf("unknown field number %d", number))
+	}
+	fi := &s.Fields[idx]
+	m.prefix = fi.Name + "."
+	encoding, similarity := m.int("encoding"), m.int("similarity")
+	e.dataOffset = m.vLong("dataOffset")
+	e.dataLength = m.vLong("dataLength")
+	dimension := m.vInt("dimension")
+	e.count = m.int("count")
+	if m.err != nil {
+		return e, m.err
+	}
+	if encoding < 0 || int(encoding) >= len(vectorEncNames) || vectorEncNames[encoding] != fi.VectorEncoding {
+		return e, m.in.fail(m.prefix+"encoding", fmt.Errorf("encoding %d does not match %s in field infos", encoding, fi.VectorEncoding))
+	}
+	if similarity < 0 || int(similarity) >= len(vectorSimNames) || vectorSimNames[similarity] != fi.VectorSimilarity {
+		return e, m.in.fail(m.prefix+"similarity", fmt.Errorf("similarity %d does not match %s in field infos", similarity, fi.VectorSimilarity))
+	}
+	if dimension != fi.VectorDimension {
+		return e, m.in.fail(m.prefix+"dimension", fmt.Errorf("dimension %d does not match %d in field infos", dimension, fi.VectorDimension))
+	}
+	if e.count < 0 || e.count > s.MaxDoc {
+		return e, m.in.fail(m.prefix+"count", fmt.Errorf("invalid vector count %d (maxDoc %d)", e.count, s.MaxDoc))
+	}
+	e.fi = fi
+	return e, nil
+}
+
+// ordToDoc reads OrdToDocDISIReaderConfiguration and returns the bytes it
+// takes in the data file: nothing when every or no document has a vector,
+// otherwise an IndexedDISI plus a DirectMonotonic ord to doc mapping.
+func (m *metaReader) ordToDoc(count int32) int64 {
+	e := docValuesEntry{}
+	m.docsWithField(&e)
+	if m.err != nil || e.docs.offset < 0 {
+		return e.dataBytes
+	}
+	m.long("ordToDocOffset")
+	blockShift := m.vInt("ordToDocBlockShift")
+	m.monotonic("ordToDoc", int64(count), blockShift)
+	return e.dataBytes + m.long("ordToDocLength")
+}
+
+// openVectorsMeta opens base+ext and checks its index header.
+func openVectorsMeta(segDir Directory, base, ext string, formats ...HeaderFormat) (*DataInput, *IndexHeader, error) {
+	in, err := segDir.OpenInput(base + ext)
+	if err != nil {
+		return nil, nil, err
+	}
+	hdr, err := checkIndexHeaderOf(in, formats...)
+	if err != nil {
+		in.Close()
+		return nil, nil, err
+	}
+	return in, hdr, nil
+}
+
+// readFlatVectorsMeta reads the raw vectors of base.vemf.
+// Entry: VectorsEntry, OrdToDoc
+func readFlatVectorsMeta(segDir Directory, s *SegInfoSummary, byNumber map[int32]int, base, format string) error {
+	in, _, err := openVectorsMeta(segDir, base, ".vemf",
+		HeaderFormat{Codec: FLAT_VECTORS_META_CODEC, MinVersion: FLAT_VECTORS_VERSION, MaxVersion: FLAT_VECTORS_VERSION})
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	for i := 0; ; i++ {
+		m := &metaReader{in: in, prefix: fmt.Sprintf("fields[%d].", i)}
+		e, err := readVectorsEntry(m, s, byNumber)
+		if err != nil || e.fi == nil {
+			return err
+		}
+		v := &FieldVectors{Format: format, Count: e.count}
+		v.VectorBytes = e.dataLength + m.ordToDoc(e.count)
+		if m.err != nil {
+			return m.err
+		}
+		bytesPerDim := int64(4)
+		if e.fi.VectorEncoding == "BYTE" {
+			bytesPerDim = 1
+		}
+		if want := int64(e.count) * int64(e.fi.VectorDimension) * bytesPerDim; e.dataLength != want {
+			return in.fail(m.prefix+"dataLength", fmt.Errorf("%d bytes of vectors, expected %d for %d vectors", e.dataLength, want, e.count))
+		}
+		e.fi.Vectors = v
+	}
+}
+
+// readQuantizedVectorsMeta reads the scalar quantized vectors of base.vemq.
+// Entry: VectorsEntry, [ConfidenceInterval(int float bits), Bits(byte), Compress(byte), LowerQuantile(int), UpperQuantile(int)], OrdToDoc
+func readQuantizedVectorsMeta(segDir Directory, s *SegInfoSummary, byNumber map[int32]int, base string) error {
+	in, hdr, err := openVectorsMeta(segDir, base, ".vemq",
+		HeaderFormat{Codec: SQ_VECTORS_META_CODEC, MinVersion: SQ_VECTORS_VERSION_START, MaxVersion: SQ_VECTORS_VERSION_ADD_BITS})
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	for i := 0; ; i++ {
+		m := &metaReader{in: in, prefix: fmt.Sprintf("fields[%d].", i)}
+		e, err := readVectorsEntry(m, s, byNumber)
+		if err != nil || e.fi == nil {
+			return err
+		}
+		if e.fi.Vectors == nil || e.fi.Vectors.Count != e.count {
+			return in.fail(m.prefix+"count", fmt.Errorf("%d quantized vectors without as many raw vectors", e.count))
+		}
+		q := &VectorQuantization{Bits: 7}
+		if e.count > 0 {
+			if ci := m.int("confidenceInterval"); ci != -1 {
+				f := math.Float32frombits(uint32(ci))
+				q.ConfidenceInterval = &f
+			}
+			if hdr.Version >= SQ_VECTORS_VERSION_ADD_BITS {
+				q.Bits = int8(m.byte("bits"))
+				q.Compress = m.byte("compress") == 1
+			}
+			q.LowerQuantile = math.Float32frombits(uint32(m.int("lowerQuantile")))
+			q.UpperQuantile = math.Float32frombits(uint32(m.int("upperQuantile")))
+		}
+		q.SizeBytes = e.dataLength + m.ordToDoc(e.count)
+		if m.err != nil {
+			return m.err
+		}
+		if q.Bits < 1 || q.Bits > 8 {
+			return in.fail(m.prefix+"bits", fmt.Errorf("invalid bits %d", q.Bits))
+		}
+		// 每个向量后附一个 float 修正值
+		vectorBytes := int64(e.fi.VectorDimension) + 4
+		if q.Bits <= 4 && q.Compress {
+			vectorBytes = int64(e.fi.VectorDimension+1)>>1 + 4
+		}
+		if want := int64(e.count) * vectorBytes; e.dataLength != want {
+			return in.fail(m.prefix+"dataLength", fmt.Errorf("%d bytes of quantized vectors, expected %d for %d vectors", e.dataLength, want, e.count))
+		}
+		e.fi.Vectors.Quantization = q
+	}
+}
+
+// readHNSWMeta reads the graphs of base.vem and counts the neighbors of
+// every node in base.vex.
+// Entry: VectorsEntry (graph offset and length in .vex), M(vInt), NumLevels(vInt),
+// <NumNodes(vInt), NodeDeltas(vInt)*>NumLevels-1, [OffsetsOffset(long), BlockShift(vInt), Offsets, OffsetsLength(long)]
+func readHNSWMeta(segDir Directory, s *SegInfoSummary, byNumber map[int32]int, base string) error {
+	in, hdr, err := openVectorsMeta(segDir, base, ".vem",
+		HeaderFormat{Codec: HNSW_META_CODEC, MinVersion: HNSW_VERSION_START, MaxVersion: HNSW_VERSION_GROUPVARINT})
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	var index *DataInput
+	defer func() {
+		if index != nil {
+			index.Close()
+		}
+	}()
+	for i := 0; ; i++ {
+		m := &metaReader{in: in, prefix: fmt.Sprintf("fields[%d].", i)}
+		e, err := readVectorsEntry(m, s, byNumber)
+		if err != nil || e.fi == nil {
+			return err
+		}
+		if e.fi.Vectors == nil || e.fi.Vectors.Count != e.count {
+			return in.fail(m.prefix+"count", fmt.Errorf("graph of %d vectors without as many raw vectors", e.count))
+		}
+		g := &HNSWGraph{M: m.vInt("M"), NumLevels: m.vInt("numLevels")}
+		if m.err == nil && (g.NumLevels < 0 || g.NumLevels > 0 && e.count == 0) {
+			return in.fail(m.prefix+"numLevels", fmt.Errorf("invalid %d levels for %d vectors", g.NumLevels, e.count))
+		}
+		var numOffsets int64
+		for level := int32(0); level < g.NumLevels && m.err == nil; level++ {
+			n := e.count
+			if level > 0 {
+				n = m.vInt(fmt.Sprintf("levels[%d].numNodes", level))
+				if m.err == nil && (n <= 0 || n > e.count) {
+					return in.fail(fmt.Sprintf("%slevels[%d].numNodes", m.prefix, level), fmt.Errorf("invalid %d nodes for %d vectors", n, e.count))
+				}
+				for j := int32(0); j < n; j++ {
+					m.vInt(fmt.Sprintf("levels[%d].nodes", level))
+				}
+			}
+			g.NodesPerLevel = append(g.NodesPerLevel, n)
+			numOffsets += int64(n)
+		}
+		g.SizeBytes = e.dataLength
+		if numOffsets > 0 {
+			m.long("offsetsOffset")
+			blockShift := m.vInt("offsetsBlockShift")
+			m.monotonic("offsets", numOffsets, blockShift)
+			g.SizeBytes += m.long("offsetsLength")
+		}
+		if m.err != nil {
+			return m.err
+		}
+
+		if g.NumLevels > 0 && index == nil {
+			if index, err = segDir.OpenInput(base + ".vex"); err != nil {
+				return err
+			}
+			if _, err := checkIndexHeader(index, HNSW_INDEX_CODEC, hdr.Version, hdr.Version); err != nil {
+				return err
+			}
+		}
+		if g.AvgDegree, err = readHNSWDegrees(index, e, g.NodesPerLevel, hdr.Version); err != nil {
+			return err
+		}
+		e.fi.Vectors.HNSW = g
+	}
+}
+
+// readHNSWDegrees walks the neighbor lists of a graph, level by level and
+// node by node, and returns the average number of neighbors per level.
+// NeighborList: NumNeighbors(vInt), Deltas(group-varint, vInt before VERSION_GROUPVARINT)
+func readHNSWDegrees(index *DataInput, e vectorsEntry, nodesPerLevel []int32, version int32) ([]float64, error) {
+	degrees := make([]float64, 0, len(nodesPerLevel))
+	if len(nodesPerLevel) == 0 {
+		return degrees, nil
+	}
+	if err := index.SeekTo(e.dataOffset); err != nil {
+		return nil, index.fail(e.fi.Name+".graph", err)
+	}
+	var scratch []int64
+	for level, n := range nodesPerLevel {
+		field := fmt.Sprintf("%s.levels[%d].neighbors", e.fi.Name, level)
+		var total int64
+		for j := int32(0); j < n; j++ {
+			size, err := index.ReadVInt()
+			if err != nil {
+				return nil, index.fail(field, err)
+			}
+			if size < 0 || size > e.count {
+				return nil, index.fail(field, fmt.Errorf("invalid neighbor count %d", size))
+			}
+			if int(size) > len(scratch) {
+				scratch = make([]int64, size)
+			}
+			if version >= HNSW_VERSION_GROUPVARINT {
+				err = index.ReadGroupVInts(scratch, int(size))
+			} else {
+				for k := int32(0); k < size && err == nil; k++ {
+					_, err = index.ReadVInt()
+				}
+			}
+			if err != nil {
+				return nil, index.fail(field, err)
+			}
+			total += int64(size)
+		}
+		degrees = append(degrees, math.Round(float64(total)/float64(n)*100)/100)
+	}
+	if end := e.dataOffset + e.dataLength; index.Pos() != end {
+		return nil, index.fail(e.fi.Name+".graph", fmt.Errorf("graph ends at %d, expected %d", index.Pos(), end))
+	}
+	return degrees, nil
+}
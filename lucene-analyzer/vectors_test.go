@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// writeVectorsEntry writes the start of a Lucene99 vectors meta entry for a FLOAT32 COSINE field
+func writeVectorsEntry(buf *bytes.Buffer, number int32, offset, length int64, dimension int, count int32) {
+	le := binary.LittleEndian
+	binary.Write(buf, le, []int32{number, 1, 2})
+	writeVLongBytes(buf, offset)
+	writeVLongBytes(buf, length)
+	writeVIntBytes(buf, dimension)
+	binary.Write(buf, le, count)
+}
+
+// writeSparseOrdToDoc writes OrdToDoc metadata for vectors in some documents only
+func writeSparseOrdToDoc(buf *bytes.Buffer, docsOffset, docsLength, ordToDocLength int64) {
+	le := binary.LittleEndian
+	binary.Write(buf, le, []int64{docsOffset, docsLength})
+	binary.Write(buf, le, int16(-1))
+	buf.WriteByte(9)
+	binary.Write(buf, le, docsOffset+docsLength)
+	writeVIntBytes(buf, 16)
+	writeLinearMonotonic(buf, 0, 1)
+	binary.Write(buf, le, ordToDocLength)
+}
+
+// TestParseVectors tests raw, scalar quantized and HNSW metadata of one field
+func TestParseVectors(t *testing.T) {
+	le := binary.LittleEndian
+	const format = "Lucene99HnswScalarQuantizedVectorsFormat"
+	const suffix = format + "_0"
+	fields := []FieldInfo{
+		{Name: "title", Number: 0},
+		{Name: "emb", Number: 1, VectorDimension: 4, VectorEncoding: "FLOAT32", VectorSimilarity: "COSINE",
+			Attributes: map[string]string{PER_FIELD_KNN_FORMAT_ATTR: format, PER_FIELD_KNN_SUFFIX_ATTR: "0"}},
+	}
+
+	var flat bytes.Buffer
+	writeIndexHeader(&flat, FLAT_VECTORS_META_CODEC, FLAT_VECTORS_VERSION, suffix)
+	writeVectorsEntry(&flat, 1, 0, 5*4*4, 4, 5)
+	writeSparseOrdToDoc(&flat, 80, 10, 5)
+	binary.Write(&flat, le, int32(-1))
+
+	var sq bytes.Buffer
+	writeIndexHeader(&sq, SQ_VECTORS_META_CODEC, SQ_VECTORS_VERSION_ADD_BITS, suffix)
+	writeVectorsEntry(&sq, 1, 0, 5*(4+4), 4, 5)
+	binary.Write(&sq, le, math.Float32bits(0.9))
+	sq.Write([]byte{7, 0})
+	binary.Write(&sq, le, []uint32{math.Float32bits(-1), math.Float32bits(1)})
+	writeSparseOrdToDoc(&sq, 40, 10, 5)
+	binary.Write(&sq, le, int32(-1))
+
+	// level 0: 0->1,2,3,4 1->0,2 2->0,1 3->0 4->0；level 1: 0->3 3->0
+	var vex bytes.Buffer
+	writeIndexHeader(&vex, HNSW_INDEX_CODEC, HNSW_VERSION_GROUPVARINT, suffix)
+	graphOffset := int64(vex.Len())
+	vex.Write([]byte{4, 0x00, 1, 1, 1, 1, 2, 0, 2, 2, 0, 1, 1, 0, 1, 0})
+	vex.Write([]byte{1, 3, 1, 0})
+	graphLength := int64(vex.Len()) - graphOffset
+
+	var vem bytes.Buffer
+	writeIndexHeader(&vem, HNSW_META_CODEC, HNSW_VERSION_GROUPVARINT, suffix)
+	writeVectorsEntry(&vem, 1, graphOffset, graphLength, 4, 5)
+	writeVIntBytes(&vem, 16) // M
+	writeVIntBytes(&vem, 2)  // numLevels
+	vem.Write([]byte{2, 0, 3})
+	binary.Write(&vem, le, graphOffset+graphLength)
+	writeVIntBytes(&vem, 16)
+	writeLinearMonotonic(&vem, 0, 1)
+	binary.Write(&vem, le, int64(7))
+	binary.Write(&vem, le, int32(-1))
+
+	tempDir := t.TempDir()
+	base := "_0_" + suffix
+	files := map[string][]byte{
+		base + ".vemf": withFooter(flat.Bytes()),
+		base + ".vemq": withFooter(sq.Bytes()),
+		base + ".vem":  withFooter(vem.Bytes()),
+		base + ".vex":  withFooter(vex.Bytes()),
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tempDir, name), content, 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+	s := &SegInfoSummary{SegName: "_0", MaxDoc: 8, Fields: fields}
+	if err := parseVectors(FSDirectory{tempDir}, s); err != nil {
+		t.Fatalf("parseVectors() error = %v", err)
+	}
+
+	if s.Fields[0].Vectors != nil {
+		t.Errorf("title vectors = %+v, want none", s.Fields[0].Vectors)
+	}
+	v := s.Fields[1].Vectors
+	if v == nil || v.Format != format || v.Count != 5 || v.VectorBytes != 80+10+5 {
+		t.Fatalf("emb vectors = %+v", v)
+	}
+	if q := v.Quantization; q == nil || q.Bits != 7 || q.Compress || q.ConfidenceInterval == nil || *q.ConfidenceInterval != 0.9 ||
+		q.LowerQuantile != -1 || q.UpperQuantile != 1 || q.SizeBytes != 40+10+5 {
+		t.Errorf("emb quantization = %+v", q)
+	}
+	if g := v.HNSW; g == nil || g.M != 16 || g.NumLevels != 2 || !slices.Equal(g.NodesPerLevel, []int32{5, 2}) ||
+		!slices.Equal(g.AvgDegree, []float64{2, 1}) || g.SizeBytes != graphLength+7 {
+		t.Errorf("emb hnsw = %+v", g)
+	}
+
+	// 维度与 .fnm 不一致
+	s.Fields[1].VectorDimension = 8
+	if err := parseVectors(FSDirectory{tempDir}, s); err == nil || !strings.Contains(err.Error(), "dimension") {
+		t.Errorf("parseVectors() error = %v, want a dimension mismatch", err)
+	}
+}
@@ -0,0 +1,612 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"unicode/utf8"
+)
+
+// ---------- XContent bodies of Elasticsearch/OpenSearch state files ----------
+
+const (
+	// XContentType 的序号，写在 state 文件头部之后
+	XCONTENT_JSON  = 0
+	XCONTENT_SMILE = 1
+	XCONTENT_YAML  = 2
+	XCONTENT_CBOR  = 3
+
+	XCONTENT_MAX_DEPTH = 512
+)
+
+var xcontentTypeNames = []string{"JSON", "SMILE", "YAML", "CBOR"}
+
+// decodeXContent decodes the whole of in as a document of the given XContentType
+// into map[string]any, []any, string, int64, float64, bool, []byte or nil;
+// integers out of the int64 range become *big.Int and BigDecimals json.Number.
+func decodeXContent(in *DataInput, typ int32) (any, error) {
+	switch typ {
+	case XCONTENT_JSON:
+		b := make([]byte, in.Length()-in.Pos())
+		if err := in.ReadBytes(b); err != nil {
+			return nil, in.fail("json", err)
+		}
+		var v any
+		if err := json.Unmarshal(b, &v); err != nil {
+			return nil, in.fail("json", err)
+		}
+		return v, nil
+	case XCONTENT_SMILE:
+		return decodeSmile(in)
+	case XCONTENT_CBOR:
+		d := &cborDecoder{in: in}
+		return d.value()
+	}
+	if typ >= 0 && int(typ) < len(xcontentTypeNames) {
+		return nil, in.fail("xContentType", fmt.Errorf("%w: %s content", ErrUnsupportedFormat, xcontentTypeNames[typ]))
+	}
+	return nil, in.fail("xContentType", fmt.Errorf("unknown XContentType %d", typ))
+}
+
+// ---------- SMILE (jackson-dataformat-smile) ----------
+
+const (
+	SMILE_HEADER = ":)\n"
+
+	// 共享的字段名和字符串值表满 1024 项后清空重新开始
+	SMILE_MAX_SHARED = 1024
+
+	SMILE_END_OF_STRING = 0xFC
+)
+
+// smileDecoder keeps the back-reference tables of one SMILE document.
+type smileDecoder struct {
+	in           *DataInput
+	sharedValues bool
+	names        []string
+	values       []string
+	depth        int
+}
+
+// decodeSmile reads the 4-byte header ":)\n" + VersionAndFlags, then one value.
+// Flags: bit 0 shared names, bit 1 shared string values, bit 2 raw binary.
+func decodeSmile(in *DataInput) (any, error) {
+	hdr := make([]byte, 4)
+	if err := in.ReadBytes(hdr); err != nil {
+		return nil, in.fail("smile.header", err)
+	}
+	if string(hdr[:3]) != SMILE_HEADER {
+		return nil, in.fail("smile.header", fmt.Errorf("not a SMILE document: % x", hdr[:3]))
+	}
+	if hdr[3]>>4 != 0 {
+		return nil, in.fail("smile.header", fmt.Errorf("%w: SMILE version %d", ErrUnsupportedFormat, hdr[3]>>4))
+	}
+	d := &smileDecoder{in: in, sharedValues: hdr[3]&0x02 != 0}
+	return d.value()
+}
+
+func (d *smileDecoder) value() (any, error) {
+	in := d.in
+	b, err := in.ReadByte()
+	if err != nil {
+		return nil, in.fail("smile.value", err)
+	}
+	switch {
+	case b >= 0x01 && b <= 0x1F: // 短共享字符串值引用
+		return d.sharedValue(int(b) - 1)
+	case b == 0x20:
+		return "", nil
+	case b == 0x21:
+		return nil, nil
+	case b == 0x22:
+		return false, nil
+	case b == 0x23:
+		return true, nil
+	case b == 0x24, b == 0x25:
+		v, err := d.vInt()
+		if err != nil {
+			return nil, in.fail("smile.int", err)
+		}
+		return zigZagDecode(int64(v)), nil
+	case b == 0x26:
+		raw, err := d.binary7()
+		if err != nil {
+			return nil, in.fail("smile.bigInteger", err)
+		}
+		return signedBigInt(raw), nil
+	case b == 0x28:
+		v, err := d.bits7(5)
+		if err != nil {
+			return nil, in.fail("smile.float", err)
+		}
+		return float64(math.Float32frombits(uint32(v))), nil
+	case b == 0x29:
+		v, err := d.bits7(10)
+		if err != nil {
+			return nil, in.fail("smile.double", err)
+		}
+		return math.Float64frombits(v), nil
+	case b == 0x2A:
+		scale, err := d.vInt()
+		if err != nil {
+			return nil, in.fail("smile.bigDecimal", err)
+		}
+		raw, err := d.binary7()
+		if err != nil {
+			return nil, in.fail("smile.bigDecimal", err)
+		}
+		return json.Number(fmt.Sprintf("%sE%d", signedBigInt(raw), -zigZagDecode(int64(scale)))), nil
+	case b >= 0x40 && b <= 0xBF: // tiny/short ASCII 与 Unicode
+		n := int(b&0x1F) + 1
+		switch b >> 5 {
+		case 3:
+			n += 32
+		case 4:
+			n++
+		case 5:
+			n += 33
+		}
+		s, err := d.text(n)
+		if err != nil {
+			return nil, in.fail("smile.string", err)
+		}
+		if d.sharedValues {
+			d.values = addShared(d.values, s)
+		}
+		return s, nil
+	case b >= 0xC0 && b <= 0xDF:
+		return zigZagDecode(int64(b & 0x1F)), nil
+	case b == 0xE0, b == 0xE4:
+		s, err := d.longText()
+		if err != nil {
+			return nil, in.fail("smile.string", err)
+		}
+		return s, nil
+	case b == 0xE8:
+		raw, err := d.binary7()
+		if err != nil {
+			return nil, in.fail("smile.binary", err)
+		}
+		return raw, nil
+	case b >= 0xEC && b <= 0xEF:
+		low, err := in.ReadByte()
+		if err != nil {
+			return nil, in.fail("smile.sharedValue", err)
+		}
+		return d.sharedValue(int(b&0x03)<<8 | int(low))
+	case b == 0xF8:
+		return d.array()
+	case b == 0xFA:
+		return d.object()
+	case b == 0xFD:
+		n, err := d.vInt()
+		if err != nil {
+			return nil, in.fail("smile.rawBinary", err)
+		}
+		raw, err := d.bytes(int64(n))
+		if err != nil {
+			return nil, in.fail("smile.rawBinary", err)
+		}
+		return raw, nil
+	}
+	return nil, in.fail("smile.value", fmt.Errorf("unexpected token %#02x", b))
+}
+
+func (d *smileDecoder) array() (any, error) {
+	if d.depth++; d.depth > XCONTENT_MAX_DEPTH {
+		return nil, d.in.fail("smile.array", fmt.Errorf("nesting deeper than %d", XCONTENT_MAX_DEPTH))
+	}
+	defer func() { d.depth-- }()
+	values := []any{}
+	for {
+		b, err := d.in.ReadByte()
+		if err != nil {
+			return nil, d.in.fail("smile.array", err)
+		}
+		if b == 0xF9 {
+			return values, nil
+		}
+		if err := d.in.SeekTo(d.in.Pos() - 1); err != nil {
+			return nil, err
+		}
+		v, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+}
+
+// object reads field names, which use their own token set, and values until 0xFB.
+func (d *smileDecoder) object() (any, error) {
+	if d.depth++; d.depth > XCONTENT_MAX_DEPTH {
+		return nil, d.in.fail("smile.object", fmt.Errorf("nesting deeper than %d", XCONTENT_MAX_DEPTH))
+	}
+	defer func() { d.depth-- }()
+	in := d.in
+	obj := map[string]any{}
+	for {
+		b, err := in.ReadByte()
+		if err != nil {
+			return nil, in.fail("smile.key", err)
+		}
+		var name string
+		switch {
+		case b == 0xFB:
+			return obj, nil
+		case b == 0x20:
+			name = ""
+		case b >= 0x30 && b <= 0x33:
+			low, err := in.ReadByte()
+			if err != nil {
+				return nil, in.fail("smile.key", err)
+			}
+			if name, err = d.sharedName(int(b&0x03)<<8 | int(low)); err != nil {
+				return nil, err
+			}
+		case b == 0x34:
+			if name, err = d.longText(); err != nil {
+				return nil, in.fail("smile.key", err)
+			}
+			d.names = addShared(d.names, name)
+		case b >= 0x40 && b <= 0x7F:
+			if name, err = d.sharedName(int(b & 0x3F)); err != nil {
+				return nil, err
+			}
+		case b >= 0x80 && b <= 0xF7:
+			n := int(b&0x3F) + 1
+			if b >= 0xC0 {
+				n++
+			}
+			if name, err = d.text(n); err != nil {
+				return nil, in.fail("smile.key", err)
+			}
+			d.names = addShared(d.names, name)
+		default:
+			return nil, in.fail("smile.key", fmt.Errorf("unexpected key token %#02x", b))
+		}
+		if obj[name], err = d.value(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func addShared(table []string, s string) []string {
+	if len(table) == SMILE_MAX_SHARED {
+		table = table[:0]
+	}
+	return append(table, s)
+}
+
+func (d *smileDecoder) sharedName(i int) (string, error) {
+	if i >= len(d.names) {
+		return "", d.in.fail("smile.key", fmt.Errorf("shared name %d out of range [0, %d)", i, len(d.names)))
+	}
+	return d.names[i], nil
+}
+
+func (d *smileDecoder) sharedValue(i int) (any, error) {
+	if i >= len(d.values) {
+		return nil, d.in.fail("smile.value", fmt.Errorf("shared string value %d out of range [0, %d)", i, len(d.values)))
+	}
+	return d.values[i], nil
+}
+
+func (d *smileDecoder) bytes(n int64) ([]byte, error) {
+	if n < 0 || n > d.in.Length()-d.in.Pos() {
+		return nil, fmt.Errorf("invalid length %d", n)
+	}
+	b := make([]byte, n)
+	return b, d.in.ReadBytes(b)
+}
+
+func (d *smileDecoder) text(n int) (string, error) {
+	b, err := d.bytes(int64(n))
+	if err != nil {
+		return "", err
+	}
+	if !utf8.Valid(b) {
+		return "", fmt.Errorf("invalid UTF-8 % x", b)
+	}
+	return string(b), nil
+}
+
+// longText reads a string terminated by 0xFC, which never occurs in UTF-8.
+func (d *smileDecoder) longText() (string, error) {
+	var b []byte
+	for {
+		c, err := d.in.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		if c == SMILE_END_OF_STRING {
+			return string(b), nil
+		}
+		b = append(b, c)
+	}
+}
+
+// vInt: 7 bits per byte, most significant first; the last byte has the high
+// bit set and carries 6 bits.
+func (d *smileDecoder) vInt() (uint64, error) {
+	var v uint64
+	for i := 0; i < 10; i++ {
+		b, err := d.in.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if b&0x80 != 0 {
+			return v<<6 | uint64(b&0x3F), nil
+		}
+		v = v<<7 | uint64(b)
+	}
+	return 0, fmt.Errorf("vInt longer than 10 bytes")
+}
+
+// bits7 reads n bytes of 7 bits each, as floats and doubles are written.
+func (d *smileDecoder) bits7(n int) (uint64, error) {
+	var v uint64
+	for i := 0; i < n; i++ {
+		b, err := d.in.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		v = v<<7 | uint64(b&0x7F)
+	}
+	return v, nil
+}
+
+// binary7 reads the 7-bit encoding of binary data: the raw length, then each
+// group of up to 7 bytes as one 7-bit byte per raw byte plus a last byte that
+// holds the remaining low bits.
+func (d *smileDecoder) binary7() ([]byte, error) {
+	n, err := d.vInt()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(d.in.Length()-d.in.Pos()) {
+		return nil, fmt.Errorf("invalid length %d", n)
+	}
+	out := make([]byte, 0, n)
+	for left := int(n); left > 0; left -= 7 {
+		k := min(left, 7)
+		v, err := d.bits7(k)
+		if err != nil {
+			return nil, err
+		}
+		last, err := d.in.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		v = v<<k | uint64(last)&(1<<k-1)
+		for i := k - 1; i >= 0; i-- {
+			out = append(out, byte(v>>(8*i)))
+		}
+	}
+	return out, nil
+}
+
+// signedBigInt decodes a big-endian two's complement integer.
+func signedBigInt(b []byte) *big.Int {
+	v := new(big.Int).SetBytes(b)
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		v.Sub(v, new(big.Int).Lsh(big.NewInt(1), uint(8*len(b))))
+	}
+	return v
+}
+
+// ---------- CBOR (RFC 8949) ----------
+
+const CBOR_BREAK = 0xFF
+
+type cborDecoder struct {
+	in    *DataInput
+	depth int
+}
+
+// head reads the initial byte and the argument that follows it; info is
+// the additional information, 31 for indefinite lengths and the break byte.
+func (d *cborDecoder) head() (major, info byte, arg uint64, err error) {
+	b, err := d.in.ReadByte()
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	major, info = b>>5, b&0x1F
+	switch {
+	case info < 24:
+		return major, info, uint64(info), nil
+	case info <= 27:
+		buf, err := d.in.next(1 << (info - 24))
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		for _, c := range buf {
+			arg = arg<<8 | uint64(c)
+		}
+		return major, info, arg, nil
+	case info == 31 && major >= 2 && major <= 5 || b == CBOR_BREAK:
+		return major, info, 0, nil
+	}
+	return 0, 0, 0, fmt.Errorf("invalid initial byte %#02x", b)
+}
+
+func (d *cborDecoder) value() (any, error) {
+	in := d.in
+	major, info, arg, err := d.head()
+	if err != nil {
+		return nil, in.fail("cbor.value", err)
+	}
+	indefinite := info == 31
+	switch major {
+	case 0:
+		if arg > math.MaxInt64 {
+			return new(big.Int).SetUint64(arg), nil
+		}
+		return int64(arg), nil
+	case 1:
+		if arg > math.MaxInt64 {
+			return new(big.Int).Sub(big.NewInt(-1), new(big.Int).SetUint64(arg)), nil
+		}
+		return -1 - int64(arg), nil
+	case 2, 3:
+		b, err := d.chunks(major, arg, indefinite)
+		if err != nil {
+			return nil, in.fail("cbor.string", err)
+		}
+		if major == 2 {
+			return b, nil
+		}
+		if !utf8.Valid(b) {
+			return nil, in.fail("cbor.string", fmt.Errorf("invalid UTF-8 % x", b))
+		}
+		return string(b), nil
+	case 4, 5:
+		if d.depth++; d.depth > XCONTENT_MAX_DEPTH {
+			return nil, in.fail("cbor.value", fmt.Errorf("nesting deeper than %d", XCONTENT_MAX_DEPTH))
+		}
+		defer func() { d.depth-- }()
+		if major == 4 {
+			return d.array(arg, indefinite)
+		}
+		return d.object(arg, indefinite)
+	case 6:
+		// tag 2/3: 正负大整数，其余 tag 只取其内容
+		v, err := d.value()
+		if b, ok := v.([]byte); ok && err == nil && (arg == 2 || arg == 3) {
+			n := new(big.Int).SetBytes(b)
+			if arg == 3 {
+				n.Sub(big.NewInt(-1), n)
+			}
+			return n, nil
+		}
+		return v, err
+	}
+	switch info {
+	case 20:
+		return false, nil
+	case 21:
+		return true, nil
+	case 22, 23:
+		return nil, nil
+	case 25:
+		return halfToFloat(uint16(arg)), nil
+	case 26:
+		return float64(math.Float32frombits(uint32(arg))), nil
+	case 27:
+		return math.Float64frombits(arg), nil
+	case 31:
+		return nil, in.fail("cbor.value", fmt.Errorf("unexpected break"))
+	}
+	return int64(arg), nil // 其他简单值
+}
+
+// chunks reads a definite string, or the definite chunks of an indefinite one.
+func (d *cborDecoder) chunks(major byte, n uint64, indefinite bool) ([]byte, error) {
+	if !indefinite {
+		if n > uint64(d.in.Length()-d.in.Pos()) {
+			return nil, fmt.Errorf("invalid length %d", n)
+		}
+		b := make([]byte, n)
+		return b, d.in.ReadBytes(b)
+	}
+	var out []byte
+	for {
+		m, info, arg, err := d.head()
+		if err != nil {
+			return nil, err
+		}
+		if m == 7 && info == 31 {
+			return out, nil
+		}
+		if m != major || info == 31 {
+			return nil, fmt.Errorf("invalid chunk of major type %d in an indefinite string of major type %d", m, major)
+		}
+		b, err := d.chunks(major, arg, false)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, b...)
+	}
+}
+
+// atBreak consumes the break byte that ends an indefinite array or map.
+func (d *cborDecoder) atBreak() (bool, error) {
+	b, err := d.in.ReadByte()
+	if err != nil {
+		return false, err
+	}
+	if b == CBOR_BREAK {
+		return true, nil
+	}
+	return false, d.in.SeekTo(d.in.Pos() - 1)
+}
+
+func (d *cborDecoder) array(n uint64, indefinite bool) (any, error) {
+	values := []any{}
+	for i := uint64(0); indefinite || i < n; i++ {
+		if indefinite {
+			end, err := d.atBreak()
+			if err != nil {
+				return nil, d.in.fail("cbor.array", err)
+			}
+			if end {
+				break
+			}
+		}
+		v, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+func (d *cborDecoder) object(n uint64, indefinite bool) (any, error) {
+	obj := map[string]any{}
+	for i := uint64(0); indefinite || i < n; i++ {
+		if indefinite {
+			end, err := d.atBreak()
+			if err != nil {
+				return nil, d.in.fail("cbor.map", err)
+			}
+			if end {
+				break
+			}
+		}
+		k, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		name, ok := k.(string)
+		if !ok {
+			name = fmt.Sprint(k)
+		}
+		if obj[name], err = d.value(); err != nil {
+			return nil, err
+		}
+	}
+	return obj, nil
+}
+
+// halfToFloat converts an IEEE 754 half-precision float.
+func halfToFloat(h uint16) float64 {
+	exp, frac := int(h>>10&0x1F), float64(h&0x3FF)
+	var v float64
+	switch exp {
+	case 0:
+		v = math.Ldexp(frac, -24)
+	case 0x1F:
+		v = math.Inf(1)
+		if frac != 0 {
+			v = math.NaN()
+		}
+	default:
+		v = math.Ldexp(frac+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		v = -v
+	}
+	return v
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestDecodeSmile tests the value and key tokens of SMILE, including back-references to shared names and values
+func TestDecodeSmile(t *testing.T) {
+	doc := []byte{':', ')', '\n', 0x03, 0xFA,
+		0x80, 'a', 0xC5, // a: -3
+		0x83, 'n', 'a', 'm', 'e', 0x42, 'a', 'b', 'c', // name: "abc"
+		0x83, 'l', 'i', 's', 't', 0xF8, 0x01, 0x23, 0x21, 0x24, 0x0E, 0xBE, 0x25, 0x81, 0xF9, // list: ["abc", true, null, 479, -1]
+		0x82, 'o', 'b', 'j', 0xFA, 0x40, 0x20, 0x41, 0x22, 0xFB, // obj: {a: "", name: false}
+		0x80, 'd', 0x29, 0x00, 0x3F, 0x7C, 0, 0, 0, 0, 0, 0, 0, // d: 1.5
+		0x80, 'f', 0x28, 0x03, 0x74, 0, 0, 0, // f: 0.25
+		0x82, 'b', 'i', 'n', 0xE8, 0x83, 0x7F, 0x40, 0x10, 0x01, // bin: 7-bit encoded ff 00 81
+		0x82, 'r', 'a', 'w', 0xFD, 0x82, 0x01, 0x02, // raw: 01 02
+		0x83, 'l', 'o', 'n', 'g', 0xE0, 'h', 'e', 'l', 'l', 'o', ' ', 'w', 'o', 'r', 'l', 'd', 0xFC,
+		0x82, 'u', 'n', 'i', 0x80, 0xC3, 0xA9, // uni: "é"
+		0xFB}
+	got, err := decodeXContent(newTestInput(doc), XCONTENT_SMILE)
+	if err != nil {
+		t.Fatalf("decodeXContent() error = %v", err)
+	}
+	want := map[string]any{
+		"a":    int64(-3),
+		"name": "abc",
+		"list": []any{"abc", true, nil, int64(479), int64(-1)},
+		"obj":  map[string]any{"a": "", "name": false},
+		"d":    1.5,
+		"f":    0.25,
+		"bin":  []byte{0xFF, 0x00, 0x81},
+		"raw":  []byte{0x01, 0x02},
+		"long": "hello world",
+		"uni":  "é",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("decodeXContent() = %#v, want %#v", got, want)
+	}
+
+	tests := []struct {
+		name string
+		doc  []byte
+		want string
+	}{
+		{"not smile", []byte("{\"a\":1}"), "not a SMILE document"},
+		{"unknown shared name", []byte{':', ')', '\n', 0x01, 0xFA, 0x45, 0x21, 0xFB}, "shared name 5 out of range"},
+		{"truncated", []byte{':', ')', '\n', 0x01, 0xFA, 0x80, 'a'}, "smile.value at offset 7"},
+		{"bad token", []byte{':', ')', '\n', 0x01, 0xF9}, "unexpected token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeSmile(newTestInput(tt.doc)); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("decodeSmile() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// TestDecodeCBOR tests definite and indefinite lengths, floats, negative integers and tags
+func TestDecodeCBOR(t *testing.T) {
+	doc := []byte{0xBF, // 不定长 map
+		0x61, 'a', 0x01, // a: 1
+		0x61, 'b', 0x85, 0x29, 0x61, 'x', 0xF5, 0xF6, 0xF9, 0x3E, 0x00, // b: [-10, "x", true, null, 1.5]
+		0x61, 'c', 0x42, 0x01, 0x02, // c: h'0102'
+		0x63, 'b', 'i', 'g', 0x1A, 0x00, 0x0F, 0x42, 0x40, // big: 1000000
+		0x7F, 0x61, 'i', 0x62, 'n', 'd', 0xFF, 0x9F, 0x01, 0xFF, // ind: [1]
+		0x61, 'f', 0xFA, 0x3E, 0x80, 0x00, 0x00, // f: 0.25
+		0x61, 'n', 0x3B, 0x7F, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, // n: math.MinInt64
+		0x61, 't', 0xC1, 0x1A, 0x69, 0x5B, 0x6B, 0x3A, // t: 1(1767598906)
+		0x62, 'b', 'n', 0xC2, 0x42, 0x01, 0x00, // bn: 2(h'0100')
+		0xFF}
+	got, err := decodeXContent(newTestInput(doc), XCONTENT_CBOR)
+	if err != nil {
+		t.Fatalf("decodeXContent() error = %v", err)
+	}
+	want := map[string]any{
+		"a":   int64(1),
+		"b":   []any{int64(-10), "x", true, nil, 1.5},
+		"c":   []byte{0x01, 0x02},
+		"big": int64(1000000),
+		"ind": []any{int64(1)},
+		"f":   0.25,
+		"n":   int64(-1 << 63),
+		"t":   int64(1767598906),
+		"bn":  big.NewInt(256),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("decodeXContent() = %#v, want %#v", got, want)
+	}
+
+	if _, err := decodeXContent(newTestInput([]byte{0xA1, 0x61}), XCONTENT_CBOR); err == nil {
+		t.Errorf("decodeXContent() error = nil, want a truncated map")
+	}
+	if _, err := decodeXContent(newTestInput([]byte("a: 1")), XCONTENT_YAML); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("decodeXContent() error = %v, want ErrUnsupportedFormat", err)
+	}
+}